@@ -0,0 +1,332 @@
+package tinydom
+
+import (
+    "encoding"
+    "encoding/xml"
+    "fmt"
+    "reflect"
+    "strings"
+    "sync"
+    "time"
+)
+
+//  BindError   是结构体与XML相互绑定时返回的错误
+//
+//  Path是出错位置的路径，元素之间用>分隔，属性用@连接，比如books>book@id；结构体的tag有误时Path是类型名和字段名。
+type BindError struct {
+    Path string
+    Msg  string
+}
+
+func (this *BindError) Error() string {
+    if "" == this.Path {
+        return "bind: " + this.Msg
+    }
+    return "bind " + this.Path + ": " + this.Msg
+}
+
+//  字段在XML中的映射方式，与encoding/xml的tag选项一一对应
+const (
+    fElement = 1 << iota
+    fAttr
+    fCDATA
+    fCharData
+    fInnerXML
+    fComment
+    fAny
+
+    fOmitEmpty
+
+    fMode = fElement | fAttr | fCDATA | fCharData | fInnerXML | fComment | fAny
+)
+
+var (
+    nameType        = reflect.TypeOf(xml.Name{})
+    attrType        = reflect.TypeOf(xml.Attr{})
+    durationType    = reflect.TypeOf(time.Duration(0))
+    xmlElementType  = reflect.TypeOf((*XMLElement)(nil)).Elem()
+    unmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+    marshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+//  fieldInfo   描述结构体的一个字段如何映射到XML
+//
+//  idx是reflect.Value.FieldByIndex使用的下标，嵌入的结构体会让它包含多级；parents是a>b>c形式的tag中的前面几级元素名。
+type fieldInfo struct {
+    idx     []int
+    name    string
+    xmlns   string
+    flags   int
+    parents []string
+}
+
+//  typeInfo    是结构体类型的映射信息，xmlname对应XMLName字段，可能为nil
+type typeInfo struct {
+    xmlname *fieldInfo
+    fields  []fieldInfo
+}
+
+var (
+    typeInfoLock  sync.RWMutex
+    typeInfoCache = make(map[reflect.Type]*typeInfo)
+)
+
+//	getTypeInfo	返回结构体类型的映射信息，结果会被缓存
+func getTypeInfo(typ reflect.Type) (*typeInfo, error) {
+    typeInfoLock.RLock()
+    tinfo, ok := typeInfoCache[typ]
+    typeInfoLock.RUnlock()
+    if ok {
+        return tinfo, nil
+    }
+
+    tinfo = new(typeInfo)
+    for i := 0; i < typ.NumField(); i++ {
+        f := typ.Field(i)
+        tag := f.Tag.Get("xml")
+        if ("" != f.PkgPath && !f.Anonymous) || "-" == tag {
+            continue
+        }
+
+        //  没有tag的嵌入结构体，它的字段被当作外层结构体的字段
+        if f.Anonymous {
+            t := f.Type
+            if reflect.Ptr == t.Kind() {
+                t = t.Elem()
+            }
+            if "" == tag && reflect.Struct == t.Kind() {
+                inner, err := getTypeInfo(t)
+                if nil != err {
+                    return nil, err
+                }
+                if nil == tinfo.xmlname && nil != inner.xmlname {
+                    tinfo.xmlname = inner.xmlname.embed(i)
+                }
+                for j := range inner.fields {
+                    if err := tinfo.addField(typ, inner.fields[j].embed(i)); nil != err {
+                        return nil, err
+                    }
+                }
+                continue
+            }
+            if "" != f.PkgPath {
+                continue
+            }
+        }
+
+        finfo, err := structFieldInfo(typ, &f)
+        if nil != err {
+            return nil, err
+        }
+        if "XMLName" == f.Name {
+            tinfo.xmlname = finfo
+            continue
+        }
+        if err := tinfo.addField(typ, finfo); nil != err {
+            return nil, err
+        }
+    }
+
+    typeInfoLock.Lock()
+    typeInfoCache[typ] = tinfo
+    typeInfoLock.Unlock()
+    return tinfo, nil
+}
+
+//	structFieldInfo	解析字段的xml tag，格式为"名字空间 名字,选项,选项"
+func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, error) {
+    finfo := &fieldInfo{idx: f.Index}
+    tag := f.Tag.Get("xml")
+    if i := strings.Index(tag, " "); i >= 0 {
+        finfo.xmlns, tag = tag[:i], tag[i+1:]
+    }
+
+    tokens := strings.Split(tag, ",")
+    tag = tokens[0]
+    for _, flag := range tokens[1:] {
+        switch flag {
+        case "attr":
+            finfo.flags |= fAttr
+        case "cdata":
+            finfo.flags |= fCDATA
+        case "chardata":
+            finfo.flags |= fCharData
+        case "innerxml":
+            finfo.flags |= fInnerXML
+        case "comment":
+            finfo.flags |= fComment
+        case "any":
+            finfo.flags |= fAny
+        case "omitempty":
+            finfo.flags |= fOmitEmpty
+        }
+    }
+
+    invalid := func() (*fieldInfo, error) {
+        return nil, &BindError{Path: typ.String() + "." + f.Name, Msg: fmt.Sprintf("invalid tag %q", f.Tag.Get("xml"))}
+    }
+
+    switch mode := finfo.flags & fMode; mode {
+    case 0:
+        finfo.flags |= fElement
+    case fAttr, fCDATA, fCharData, fInnerXML, fComment, fAny, fAny | fAttr:
+        if "XMLName" == f.Name || ("" != tag && fAttr != mode) {
+            return invalid()
+        }
+    default:
+        //  同时指定了多种映射方式
+        return invalid()
+    }
+    if fAny == finfo.flags&fMode {
+        finfo.flags |= fElement
+    }
+    if 0 != finfo.flags&fOmitEmpty && 0 == finfo.flags&(fElement|fAttr) {
+        return invalid()
+    }
+
+    if "XMLName" == f.Name {
+        finfo.name = tag
+        return finfo, nil
+    }
+
+    if "" == tag {
+        //  元素的名字默认取字段类型的XMLName，然后才是字段名
+        if xmlname := lookupXMLName(f.Type); nil != xmlname && 0 != finfo.flags&fElement && "" != xmlname.name {
+            finfo.xmlns, finfo.name = xmlname.xmlns, xmlname.name
+        } else {
+            finfo.name = f.Name
+        }
+        return finfo, nil
+    }
+
+    parents := strings.Split(tag, ">")
+    if "" == parents[0] {
+        parents[0] = f.Name
+    }
+    if "" == parents[len(parents)-1] {
+        return invalid()
+    }
+    finfo.name = parents[len(parents)-1]
+    if len(parents) > 1 {
+        if 0 == finfo.flags&fElement {
+            return invalid()
+        }
+        finfo.parents = parents[:len(parents)-1]
+    }
+
+    //  字段的tag与字段类型的XMLName指定了不同的名字时，编码和解码的结果会不一致
+    if 0 != finfo.flags&fElement {
+        if xmlname := lookupXMLName(f.Type); nil != xmlname && "" != xmlname.name && xmlname.name != finfo.name {
+            return nil, &BindError{Path: typ.String() + "." + f.Name, Msg: fmt.Sprintf("name %q conflicts with name %q in XMLName", finfo.name, xmlname.name)}
+        }
+    }
+    return finfo, nil
+}
+
+//	lookupXMLName	返回类型(忽略指针和切片)的XMLName字段的映射信息
+func lookupXMLName(typ reflect.Type) *fieldInfo {
+    for reflect.Ptr == typ.Kind() || (reflect.Slice == typ.Kind() && reflect.Uint8 != typ.Elem().Kind()) {
+        typ = typ.Elem()
+    }
+    if reflect.Struct != typ.Kind() {
+        return nil
+    }
+    f, ok := typ.FieldByName("XMLName")
+    if !ok || "-" == f.Tag.Get("xml") {
+        return nil
+    }
+    finfo, err := structFieldInfo(typ, &f)
+    if nil != err {
+        return nil
+    }
+    return finfo
+}
+
+//	embed	返回嵌入到外层结构体第index个字段之后的映射信息
+func (this *fieldInfo) embed(index int) *fieldInfo {
+    finfo := *this
+    finfo.idx = append([]int{index}, this.idx...)
+    return &finfo
+}
+
+//	addField	添加一个字段，同名的字段中层次浅的优先，层次相同时返回错误
+func (this *typeInfo) addField(typ reflect.Type, finfo *fieldInfo) error {
+    for i := range this.fields {
+        old := &this.fields[i]
+        if !old.conflicts(finfo) {
+            continue
+        }
+        if len(old.idx) < len(finfo.idx) {
+            return nil
+        }
+        if len(old.idx) > len(finfo.idx) {
+            this.fields[i] = *finfo
+            return nil
+        }
+        return &BindError{Path: typ.String(), Msg: fmt.Sprintf("%s conflicts with another field", strings.Join(append(finfo.parents, finfo.name), ">"))}
+    }
+    this.fields = append(this.fields, *finfo)
+    return nil
+}
+
+//	conflicts	判断两个字段是否映射到了同一个元素或者属性上，或者一个字段的元素是另一个字段的路径
+func (this *fieldInfo) conflicts(other *fieldInfo) bool {
+    if (this.flags&fMode != other.flags&fMode) || this.xmlns != other.xmlns {
+        return false
+    }
+    if 0 == this.flags&(fElement|fAttr) || 0 != this.flags&fAny {
+        //  chardata等字段每个结构体只能有一个
+        return true
+    }
+
+    a := append(append([]string{}, this.parents...), this.name)
+    b := append(append([]string{}, other.parents...), other.name)
+    if len(a) > len(b) {
+        a, b = b, a
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return len(a) == len(b) || 0 != this.flags&fElement
+}
+
+//	matchElement	判断元素的名字是否与字段匹配，名字可以是本地名或者限定名
+func (this *fieldInfo) matchElement(elem XMLElement) bool {
+    if elem.LocalName() != this.name && elem.Name() != this.name {
+        return false
+    }
+    return "" == this.xmlns || elem.NamespaceURI() == this.xmlns
+}
+
+//	matchAttribute	判断属性的名字是否与字段匹配，xmlns声明只能通过限定名匹配
+func (this *fieldInfo) matchAttribute(attr XMLAttribute) bool {
+    if attr.Name() == this.name {
+        return "" == this.xmlns || attr.NamespaceURI() == this.xmlns
+    }
+    if attr.LocalName() != this.name {
+        return false
+    }
+    if "" == this.xmlns {
+        return XMLNSNamespace != attr.NamespaceURI()
+    }
+    return attr.NamespaceURI() == this.xmlns
+}
+
+//	fieldValue	取得结构体中idx对应的字段，alloc为true时为途经的nil指针分配内存，否则遇到nil指针时返回无效的reflect.Value
+func fieldValue(v reflect.Value, idx []int, alloc bool) reflect.Value {
+    for i, x := range idx {
+        if i > 0 && reflect.Ptr == v.Kind() {
+            if v.IsNil() {
+                if !alloc {
+                    return reflect.Value{}
+                }
+                v.Set(reflect.New(v.Type().Elem()))
+            }
+            v = v.Elem()
+        }
+        v = v.Field(x)
+    }
+    return v
+}
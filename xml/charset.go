@@ -0,0 +1,453 @@
+package tinydom
+
+import (
+    "bufio"
+    "bytes"
+    "errors"
+    "io"
+    "strings"
+    "sync"
+    "unicode/utf16"
+    "unicode/utf8"
+)
+
+var (
+    //  ErrUnsupportedCharset   文档使用的字符集没有注册
+    ErrUnsupportedCharset = errors.New("unsupported charset")
+    //  ErrInvalidByte  输入中出现了文档的字符集中不存在的字节，比如US-ASCII中大于0x7F的字节
+    ErrInvalidByte = errors.New("byte is not valid in the document charset")
+)
+
+//  CharsetReaderFunc   把input中指定字符集的内容转换为UTF-8
+type CharsetReaderFunc func(input io.Reader) (io.Reader, error)
+
+var (
+    charsetLock sync.RWMutex
+    charsets    = map[string]CharsetReaderFunc{
+        "utf-8":      newUTF8Reader,
+        "utf8":       newUTF8Reader,
+        "utf-16":     newUTF16Reader(false, false),
+        "utf16":      newUTF16Reader(false, false),
+        "utf-16be":   newUTF16Reader(true, true),
+        "utf-16le":   newUTF16Reader(true, false),
+        "iso-8859-1": newLatin1Reader,
+        "iso8859-1":  newLatin1Reader,
+        "iso_8859-1": newLatin1Reader,
+        "latin1":     newLatin1Reader,
+        "l1":         newLatin1Reader,
+        "us-ascii":   newASCIIReader,
+        "ascii":      newASCIIReader,
+    }
+)
+
+//	RegisterCharset	注册一个字符集，name不区分大小写，已经存在的字符集会被替换
+//
+//	内置了UTF-8、UTF-16(包括UTF-16BE、UTF-16LE)、ISO-8859-1和US-ASCII，其他字符集可以借助golang.org/x/text注册，比如：
+//	    tinydom.RegisterCharset("GBK", func(input io.Reader) (io.Reader, error) {
+//	        return simplifiedchinese.GBK.NewDecoder().Reader(input), nil
+//	    })
+func RegisterCharset(name string, reader CharsetReaderFunc) {
+    charsetLock.Lock()
+    defer charsetLock.Unlock()
+    charsets[strings.ToLower(strings.TrimSpace(name))] = reader
+}
+
+//	lookupCharset	查找已经注册的字符集
+func lookupCharset(name string) CharsetReaderFunc {
+    charsetLock.RLock()
+    defer charsetLock.RUnlock()
+    return charsets[strings.ToLower(strings.TrimSpace(name))]
+}
+
+//	newDecodingReader	识别rd使用的字符集并转换为UTF-8，同时返回识别出的字符集
+//
+//	forced不为空时总是使用它；否则依次根据BOM、前四个字节的特征(没有BOM的UTF-16)以及XML声明中的encoding确定字符集，
+//	都没有时按UTF-8处理。UTF-8的BOM会被丢弃。
+func newDecodingReader(rd io.Reader, forced string) (io.Reader, string, error) {
+    input := bufio.NewReader(rd)
+    head, _ := input.Peek(4)
+
+    charset := forced
+    if bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}) {
+        input.Discard(3)
+        if "" == charset {
+            charset = "utf-8"
+        }
+    }
+
+    if "" == charset {
+        switch {
+        case bytes.HasPrefix(head, []byte{0xFE, 0xFF}), bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+            charset = "utf-16"
+        case bytes.HasPrefix(head, []byte{0x00, '<', 0x00, '?'}):
+            charset = "utf-16be"
+        case bytes.HasPrefix(head, []byte{'<', 0x00, '?', 0x00}):
+            charset = "utf-16le"
+        default:
+            charset = declaredCharset(input)
+        }
+    }
+
+    if "" == charset {
+        return input, "utf-8", nil
+    }
+
+    reader := lookupCharset(charset)
+    if nil == reader {
+        return nil, charset, ErrUnsupportedCharset
+    }
+    decoded, err := reader(input)
+    return decoded, charset, err
+}
+
+//	declaredCharset	读取XML声明中的encoding，不会消耗输入
+func declaredCharset(input *bufio.Reader) string {
+    head, _ := input.Peek(1024)
+    if !bytes.HasPrefix(head, []byte("<?xml")) {
+        return ""
+    }
+    if end := bytes.Index(head, []byte("?>")); end >= 0 {
+        head = head[:end]
+    }
+
+    index := bytes.Index(head, []byte("encoding"))
+    if index < 0 {
+        return ""
+    }
+    rest := bytes.TrimLeft(head[index+len("encoding"):], " \t\r\n")
+    if !bytes.HasPrefix(rest, []byte("=")) {
+        return ""
+    }
+    rest = bytes.TrimLeft(rest[1:], " \t\r\n")
+    if 0 == len(rest) || ('"' != rest[0] && '\'' != rest[0]) {
+        return ""
+    }
+    end := bytes.IndexByte(rest[1:], rest[0])
+    if end < 0 {
+        return ""
+    }
+    return string(rest[1 : end+1])
+}
+
+//	identityCharsetReader	交给encoding/xml使用，输入在此之前已经被转换为UTF-8了
+func identityCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+    return input, nil
+}
+
+func newUTF8Reader(input io.Reader) (io.Reader, error) {
+    return input, nil
+}
+
+//------------------------------------------------------------------
+
+//  latin1Reader    把ISO-8859-1转换为UTF-8，每个字节就是一个Unicode码点
+type latin1Reader struct {
+    input io.Reader
+    out   []byte
+}
+
+func newLatin1Reader(input io.Reader) (io.Reader, error) {
+    return &latin1Reader{input: input}, nil
+}
+
+func (this *latin1Reader) Read(data []byte) (int, error) {
+    if 0 == len(this.out) {
+        var buf [4096]byte
+        n, err := this.input.Read(buf[:])
+        for _, b := range buf[:n] {
+            this.out = appendRune(this.out, rune(b))
+        }
+        if 0 == len(this.out) {
+            return 0, err
+        }
+    }
+
+    n := copy(data, this.out)
+    this.out = this.out[n:]
+    return n, nil
+}
+
+//------------------------------------------------------------------
+
+//  asciiReader 检查输入是否都是ASCII字符，ASCII本身就是合法的UTF-8，因此不需要转换.
+//  遇到大于0x7F的字节时先返回它之前的内容，下一次读取再返回ErrInvalidByte，这样错误的位置就是这个字节的位置
+type asciiReader struct {
+    input io.Reader
+    err   error
+}
+
+func newASCIIReader(input io.Reader) (io.Reader, error) {
+    return &asciiReader{input: input}, nil
+}
+
+func (this *asciiReader) Read(data []byte) (int, error) {
+    if nil != this.err {
+        return 0, this.err
+    }
+
+    n, err := this.input.Read(data)
+    for i, b := range data[:n] {
+        if b >= 0x80 {
+            this.err = ErrInvalidByte
+            return i, nil
+        }
+    }
+    return n, err
+}
+
+//------------------------------------------------------------------
+
+//  utf16Reader 把UTF-16转换为UTF-8，不成对的代理项被替换为U+FFFD
+type utf16Reader struct {
+    input     io.Reader
+    bigEndian bool
+    //  还没有确定字节序，需要根据BOM判断，没有BOM时按照大端处理
+    detect bool
+    start  bool
+
+    in  []byte
+    out []byte
+    err error
+}
+
+func newUTF16Reader(fixed bool, bigEndian bool) CharsetReaderFunc {
+    return func(input io.Reader) (io.Reader, error) {
+        return &utf16Reader{input: input, bigEndian: bigEndian || !fixed, detect: !fixed, start: true}, nil
+    }
+}
+
+func (this *utf16Reader) Read(data []byte) (int, error) {
+    for 0 == len(this.out) {
+        if nil != this.err {
+            return 0, this.err
+        }
+
+        var buf [4096]byte
+        n, err := this.input.Read(buf[:])
+        this.in = append(this.in, buf[:n]...)
+        this.err = err
+        this.decode()
+    }
+
+    n := copy(data, this.out)
+    this.out = this.out[n:]
+    return n, nil
+}
+
+//	decode	转换in中所有完整的码元，输入结束时把剩余的半个码元当作错误
+func (this *utf16Reader) decode() {
+    in := this.in
+    if this.start && len(in) >= 2 {
+        if this.detect {
+            if 0xFF == in[0] && 0xFE == in[1] {
+                this.bigEndian = false
+            } else if 0xFE == in[0] && 0xFF == in[1] {
+                this.bigEndian = true
+            }
+        }
+        if 0xFEFF == this.unit(in) {
+            in = in[2:]
+        }
+        this.start = false
+    }
+
+    for len(in) >= 2 {
+        r := rune(this.unit(in))
+        size := 2
+        if utf16.IsSurrogate(r) {
+            if len(in) < 4 && nil == this.err {
+                break
+            }
+            r = utf8.RuneError
+            if len(in) >= 4 {
+                if pair := utf16.DecodeRune(rune(this.unit(in)), rune(this.unit(in[2:]))); utf8.RuneError != pair {
+                    r, size = pair, 4
+                }
+            }
+        }
+        this.out = appendRune(this.out, r)
+        in = in[size:]
+    }
+
+    if nil != this.err && len(in) > 0 {
+        this.out = appendRune(this.out, utf8.RuneError)
+        in = nil
+    }
+    this.in = append(this.in[:0], in...)
+}
+
+func (this *utf16Reader) unit(in []byte) uint16 {
+    if this.bigEndian {
+        return uint16(in[0])<<8 | uint16(in[1])
+    }
+    return uint16(in[1])<<8 | uint16(in[0])
+}
+
+//	appendRune	把r按UTF-8编码追加到buf的末尾
+func appendRune(buf []byte, r rune) []byte {
+    var tmp [utf8.UTFMax]byte
+    n := utf8.EncodeRune(tmp[:], r)
+    return append(buf, tmp[:n]...)
+}
+
+//------------------------------------------------------------------
+
+//  ErrUnrepresentableChar  输出的字符无法用指定的字符集表示，并且不能使用字符引用，比如出现在元素名或者注释中
+var ErrUnrepresentableChar = errors.New("character cannot be represented in the output charset")
+
+//  CharsetWriterFunc   返回一个把UTF-8转换为指定字符集后写入output的输出流，Close时输出缓存的内容，但是不关闭output
+type CharsetWriterFunc func(output io.Writer) io.WriteCloser
+
+//  charsetEncoder  输出时使用的字符集，canEncode为nil表示可以表示所有字符，requireBOM为true时总是输出BOM
+type charsetEncoder struct {
+    canEncode  func(r rune) bool
+    newWriter  CharsetWriterFunc
+    bom        []byte
+    requireBOM bool
+}
+
+var (
+    utf8Encoder    = &charsetEncoder{newWriter: newUTF8Writer, bom: []byte{0xEF, 0xBB, 0xBF}}
+    utf16BEEncoder = &charsetEncoder{newWriter: newRuneWriter(encodeUTF16BE), bom: []byte{0xFE, 0xFF}}
+    //  XML规范要求声明为UTF-16的文档以BOM开头，只有明确了字节序的UTF-16BE和UTF-16LE可以省略
+    utf16Encoder   = &charsetEncoder{newWriter: newRuneWriter(encodeUTF16BE), bom: []byte{0xFE, 0xFF}, requireBOM: true}
+    utf16LEEncoder = &charsetEncoder{newWriter: newRuneWriter(encodeUTF16LE), bom: []byte{0xFF, 0xFE}}
+    latin1Encoder  = &charsetEncoder{canEncode: isLatin1, newWriter: newRuneWriter(encodeLatin1)}
+    asciiEncoder   = &charsetEncoder{canEncode: isASCII, newWriter: newRuneWriter(encodeASCII)}
+
+    charsetEncoders = map[string]*charsetEncoder{
+        "utf-8":      utf8Encoder,
+        "utf8":       utf8Encoder,
+        "utf-16":     utf16Encoder,
+        "utf16":      utf16Encoder,
+        "utf-16be":   utf16BEEncoder,
+        "utf-16le":   utf16LEEncoder,
+        "iso-8859-1": latin1Encoder,
+        "iso8859-1":  latin1Encoder,
+        "iso_8859-1": latin1Encoder,
+        "latin1":     latin1Encoder,
+        "l1":         latin1Encoder,
+        "us-ascii":   asciiEncoder,
+        "ascii":      asciiEncoder,
+    }
+)
+
+//	RegisterCharsetEncoder	注册一个输出时使用的字符集，name不区分大小写，已经存在的字符集会被替换
+//
+//	canEncode判断字符能否用该字符集表示，为nil时表示所有字符都可以表示；不能表示的字符在文本和属性值中会被输出为字符引用。
+//	借助golang.org/x/text可以这样注册GBK：
+//	    encoder := simplifiedchinese.GBK.NewEncoder()
+//	    tinydom.RegisterCharsetEncoder("GBK", func(r rune) bool {
+//	        _, err := encoder.String(string(r))
+//	        return nil == err
+//	    }, func(output io.Writer) io.WriteCloser {
+//	        return transform.NewWriter(output, simplifiedchinese.GBK.NewEncoder())
+//	    })
+func RegisterCharsetEncoder(name string, canEncode func(r rune) bool, newWriter CharsetWriterFunc) {
+    charsetLock.Lock()
+    defer charsetLock.Unlock()
+    charsetEncoders[strings.ToLower(strings.TrimSpace(name))] = &charsetEncoder{canEncode: canEncode, newWriter: newWriter}
+}
+
+//	lookupCharsetEncoder	查找输出时使用的字符集
+func lookupCharsetEncoder(name string) *charsetEncoder {
+    charsetLock.RLock()
+    defer charsetLock.RUnlock()
+    return charsetEncoders[strings.ToLower(strings.TrimSpace(name))]
+}
+
+//	isUTF8Charset	判断字符集的名字是否表示UTF-8
+func isUTF8Charset(name string) bool {
+    name = strings.ToLower(strings.TrimSpace(name))
+    return "utf-8" == name || "utf8" == name
+}
+
+func isLatin1(r rune) bool {
+    return r < 0x100
+}
+
+func isASCII(r rune) bool {
+    return r < utf8.RuneSelf
+}
+
+//  nopWriteCloser  UTF-8不需要转换，直接写入output
+type nopWriteCloser struct {
+    io.Writer
+}
+
+func (this nopWriteCloser) Close() error {
+    return nil
+}
+
+func newUTF8Writer(output io.Writer) io.WriteCloser {
+    return nopWriteCloser{output}
+}
+
+//  runeWriter  逐个字符地转换UTF-8，被拆分到两次写入中的字符会被缓存起来
+type runeWriter struct {
+    output  io.Writer
+    encode  func(buf []byte, r rune) ([]byte, bool)
+    pending []byte
+    buf     []byte
+}
+
+func newRuneWriter(encode func(buf []byte, r rune) ([]byte, bool)) CharsetWriterFunc {
+    return func(output io.Writer) io.WriteCloser {
+        return &runeWriter{output: output, encode: encode}
+    }
+}
+
+func (this *runeWriter) Write(data []byte) (int, error) {
+    input := append(this.pending, data...)
+    this.buf = this.buf[:0]
+    i := 0
+    for i < len(input) && utf8.FullRune(input[i:]) {
+        r, width := utf8.DecodeRune(input[i:])
+        var ok bool
+        if this.buf, ok = this.encode(this.buf, r); !ok {
+            return 0, ErrUnrepresentableChar
+        }
+        i += width
+    }
+    this.pending = append(this.pending[:0], input[i:]...)
+
+    if _, err := this.output.Write(this.buf); nil != err {
+        return 0, err
+    }
+    return len(data), nil
+}
+
+func (this *runeWriter) Close() error {
+    if len(this.pending) > 0 {
+        return ErrUnrepresentableChar
+    }
+    return nil
+}
+
+func encodeLatin1(buf []byte, r rune) ([]byte, bool) {
+    if r >= 0x100 {
+        return buf, false
+    }
+    return append(buf, byte(r)), true
+}
+
+func encodeASCII(buf []byte, r rune) ([]byte, bool) {
+    if r >= 0x80 {
+        return buf, false
+    }
+    return append(buf, byte(r)), true
+}
+
+func encodeUTF16BE(buf []byte, r rune) ([]byte, bool) {
+    for _, unit := range utf16.Encode([]rune{r}) {
+        buf = append(buf, byte(unit>>8), byte(unit))
+    }
+    return buf, true
+}
+
+func encodeUTF16LE(buf []byte, r rune) ([]byte, bool) {
+    for _, unit := range utf16.Encode([]rune{r}) {
+        buf = append(buf, byte(unit), byte(unit>>8))
+    }
+    return buf, true
+}
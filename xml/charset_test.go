@@ -0,0 +1,104 @@
+package tinydom_test
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "io/ioutil"
+    "strings"
+    "testing"
+    "unicode/utf16"
+    "tinydom/xml"
+)
+
+//  encodeUTF16 把字符串编码为UTF-16，bom为true时在开头加上BOM
+func encodeUTF16(str string, bigEndian bool, bom bool) []byte {
+    units := utf16.Encode([]rune(str))
+    if bom {
+        units = append([]uint16{0xFEFF}, units...)
+    }
+
+    var buf bytes.Buffer
+    for _, unit := range units {
+        if bigEndian {
+            buf.WriteByte(byte(unit >> 8))
+            buf.WriteByte(byte(unit))
+        } else {
+            buf.WriteByte(byte(unit))
+            buf.WriteByte(byte(unit >> 8))
+        }
+    }
+    return buf.Bytes()
+}
+
+func Test_Charset_BOM和UTF16(t *testing.T) {
+    doc, err := tinydom.LoadDocument(bytes.NewReader(append([]byte{0xEF, 0xBB, 0xBF}, `<a>中文</a>`...)))
+    expect(t, "UTF-8的BOM", nil == err && "中文" == doc.FirstChildElement("a").Text())
+
+    source := `<?xml version="1.0" encoding="UTF-16"?><a x="é"><![CDATA[<中>]]>𝄞</a>`
+    for _, input := range [][]byte{encodeUTF16(source, false, true), encodeUTF16(source, true, true), encodeUTF16(source, true, false), encodeUTF16(source, false, false)} {
+        doc, err = tinydom.LoadDocument(bytes.NewReader(input))
+        expect(t, "返回值检测", nil == err)
+        a := doc.FirstChildElement("a")
+        expect(t, "UTF-16的内容", "é" == a.Attribute("x", "") && "<中>" == a.FirstChild().Value() && a.FirstChild().ToText().CDATA() && "𝄞" == a.LastChild().Value())
+    }
+
+    doc, err = tinydom.LoadDocument(bytes.NewReader(encodeUTF16(`<a>x</a>`, false, true)))
+    expect(t, "没有声明的UTF-16", nil == err && "x" == doc.FirstChildElement("a").Text())
+}
+
+func Test_Charset_声明的字符集(t *testing.T) {
+    latin1 := append([]byte(`<?xml version='1.0' encoding='ISO-8859-1'?><a>caf`), 0xE9, '<', '/', 'a', '>')
+    doc, err := tinydom.LoadDocument(bytes.NewReader(latin1))
+    expect(t, "ISO-8859-1", nil == err && "café" == doc.FirstChildElement("a").Text())
+
+    _, err = tinydom.LoadDocument(strings.NewReader(`<?xml version="1.0" encoding="x-unknown"?><a/>`))
+    parseErr, ok := err.(*tinydom.ParseError)
+    expect(t, "未注册的字符集", ok && errors.Is(err, tinydom.ErrUnsupportedCharset) && strings.Contains(parseErr.Msg, "x-unknown"))
+
+    doc, err = tinydom.LoadDocument(strings.NewReader(`<?xml version="1.0" encoding="utf-8"?><a>中文</a>`))
+    expect(t, "UTF-8", nil == err && "中文" == doc.FirstChildElement("a").Text())
+
+    //  把#替换为中，用于模拟一个需要注册的字符集
+    tinydom.RegisterCharset("X-Test", func(input io.Reader) (io.Reader, error) {
+        data, err := ioutil.ReadAll(input)
+        return strings.NewReader(strings.Replace(string(data), "#", "中", -1)), err
+    })
+    doc, err = tinydom.LoadDocument(strings.NewReader(`<?xml version="1.0" encoding="x-test"?><a><![CDATA[#]]>#</a>`))
+    expect(t, "注册的字符集", nil == err && "中中" == doc.FirstChildElement("a").InnerText())
+    expect(t, "CDATA", doc.FirstChildElement("a").FirstChild().ToText().CDATA())
+}
+
+func Test_Charset_US_ASCII(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<?xml version="1.0" encoding="US-ASCII"?><a>cafe</a>`))
+    expect(t, "ASCII的文档", nil == err && "cafe" == doc.FirstChildElement("a").Text())
+
+    ascii := append([]byte("<?xml version=\"1.0\" encoding=\"US-ASCII\"?>\n<a>caf"), 0xE9, '<', '/', 'a', '>')
+    _, err = tinydom.LoadDocument(bytes.NewReader(ascii))
+    parseErr, ok := err.(*tinydom.ParseError)
+    expect(t, "大于0x7F的字节", ok && errors.Is(err, tinydom.ErrInvalidByte))
+    expect(t, "错误的位置", ok && 2 == parseErr.Line && 7 == parseErr.Column)
+
+    _, err = tinydom.LoadDocumentWithOptions(bytes.NewReader(ascii[len("<?xml version=\"1.0\" encoding=\"US-ASCII\"?>\n"):]), &tinydom.LoadOptions{Charset: "ascii"})
+    expect(t, "指定ASCII字符集", errors.Is(err, tinydom.ErrInvalidByte))
+}
+
+func Test_Charset_指定字符集(t *testing.T) {
+    latin1 := []byte{'<', 'a', '>', 0xE9, '<', '/', 'a', '>'}
+    doc, err := tinydom.LoadDocumentWithOptions(bytes.NewReader(latin1), &tinydom.LoadOptions{Charset: "latin1"})
+    expect(t, "没有声明时指定字符集", nil == err && "é" == doc.FirstChildElement("a").Text())
+
+    declared := append([]byte(`<?xml version="1.0" encoding="GBK"?>`), latin1...)
+    doc, err = tinydom.LoadDocumentWithOptions(bytes.NewReader(declared), &tinydom.LoadOptions{Charset: "ISO-8859-1"})
+    expect(t, "覆盖声明的字符集", nil == err && "é" == doc.FirstChildElement("a").Text())
+
+    _, err = tinydom.LoadDocumentWithOptions(strings.NewReader(`<a/>`), &tinydom.LoadOptions{Charset: "none"})
+    expect(t, "指定的字符集不存在", errors.Is(err, tinydom.ErrUnsupportedCharset))
+
+    var names []string
+    err = tinydom.StreamElements(bytes.NewReader(encodeUTF16(`<list><item>一</item><item>二</item></list>`, false, true)), "/list/item", func(elem tinydom.XMLElement) error {
+        names = append(names, elem.Text())
+        return nil
+    })
+    expect(t, "流式解析", nil == err && "一,二" == strings.Join(names, ","))
+}
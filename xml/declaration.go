@@ -0,0 +1,140 @@
+package tinydom
+
+import (
+    "strings"
+)
+
+//  XMLDeclaration  是文档开头的XML声明，也就是目标为xml的处理指令
+//
+//  Version、Encoding和Standalone从处理指令的内容中解析，不存在时返回空字符串。
+//  对应的Set方法会重新生成处理指令的内容，参数为空字符串时去掉这一项，version为空时使用1.0。
+//  输出文档时encoding会被改写为实际使用的字符集，参考WithEncoding。
+type XMLDeclaration interface {
+    XMLProcInst
+
+    Version() string
+    SetVersion(version string)
+    Encoding() string
+    SetEncoding(encoding string)
+    Standalone() string
+    SetStandalone(standalone string)
+}
+
+//  xmlDeclarationImpl  目标为xml的处理指令，只有它实现了XMLDeclaration，其他处理指令不能被当作XML声明修改
+type xmlDeclarationImpl struct {
+    xmlProcInstImpl
+}
+
+func (this *xmlDeclarationImpl) ToProcInst() XMLProcInst {
+    return this
+}
+
+func (this *xmlDeclarationImpl) Accept(visitor XMLVisitor) bool {
+    return visitor.VisitProcInst(this)
+}
+
+func (this *xmlDeclarationImpl) Version() string {
+    version, _, _ := parseDeclaration(this.instruction)
+    return version
+}
+
+func (this *xmlDeclarationImpl) SetVersion(version string) {
+    _, encoding, standalone := parseDeclaration(this.instruction)
+    this.setDeclaration(version, encoding, standalone)
+}
+
+func (this *xmlDeclarationImpl) Encoding() string {
+    _, encoding, _ := parseDeclaration(this.instruction)
+    return encoding
+}
+
+func (this *xmlDeclarationImpl) SetEncoding(encoding string) {
+    version, _, standalone := parseDeclaration(this.instruction)
+    this.setDeclaration(version, encoding, standalone)
+}
+
+func (this *xmlDeclarationImpl) Standalone() string {
+    _, _, standalone := parseDeclaration(this.instruction)
+    return standalone
+}
+
+func (this *xmlDeclarationImpl) SetStandalone(standalone string) {
+    version, encoding, _ := parseDeclaration(this.instruction)
+    this.setDeclaration(version, encoding, standalone)
+}
+
+func (this *xmlDeclarationImpl) setDeclaration(version string, encoding string, standalone string) {
+    if "" == version {
+        version = "1.0"
+    }
+    this.instruction = formatDeclaration(version, encoding, standalone)
+}
+
+//------------------------------------------------------------------
+
+//	Declaration	返回文档的XML声明，也就是文档级别的第一个xml处理指令，没有时返回nil
+func (this *xmlDocumentImpl) Declaration() XMLDeclaration {
+    for child := this.FirstChild(); nil != child; child = child.NextSibling() {
+        if declaration, ok := child.(*xmlDeclarationImpl); ok && ("xml" == declaration.Target()) {
+            return declaration
+        }
+    }
+    return nil
+}
+
+//	SetDeclaration	设置文档的XML声明，没有声明时在文档的最前面插入一个
+func (this *xmlDocumentImpl) SetDeclaration(version string, encoding string, standalone string) XMLDeclaration {
+    declaration := this.Declaration()
+    if nil == declaration {
+        declaration = NewProcInst(this, "xml", "").(*xmlDeclarationImpl)
+        this.InsertFirstChild(declaration)
+    }
+
+    declaration.(*xmlDeclarationImpl).setDeclaration(version, encoding, standalone)
+    return declaration
+}
+
+//	parseDeclaration	解析XML声明中的version、encoding和standalone，不存在的项返回空字符串
+func parseDeclaration(inst string) (version string, encoding string, standalone string) {
+    for rest := inst; ; {
+        rest = strings.TrimLeft(rest, " \t\r\n")
+        eq := strings.IndexByte(rest, '=')
+        if eq < 0 {
+            return
+        }
+
+        name := strings.TrimSpace(rest[:eq])
+        rest = strings.TrimLeft(rest[eq+1:], " \t\r\n")
+        if ("" == rest) || (('"' != rest[0]) && ('\'' != rest[0])) {
+            return
+        }
+
+        end := strings.IndexByte(rest[1:], rest[0])
+        if end < 0 {
+            return
+        }
+
+        value := rest[1 : end+1]
+        rest = rest[end+2:]
+        switch name {
+        case "version":
+            version = value
+        case "encoding":
+            encoding = value
+        case "standalone":
+            standalone = value
+        }
+    }
+}
+
+//	formatDeclaration	生成XML声明的内容，encoding和standalone为空时省略
+func formatDeclaration(version string, encoding string, standalone string) string {
+    inst := `version="` + version + `"`
+    if "" != encoding {
+        inst += ` encoding="` + encoding + `"`
+    }
+    if "" != standalone {
+        inst += ` standalone="` + standalone + `"`
+    }
+    return inst
+}
@@ -0,0 +1,56 @@
+package tinydom_test
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "tinydom/xml"
+)
+
+func Test_Declaration_加载的声明(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<?xml version='1.0' encoding = "ISO-8859-1" standalone='yes'?><a/>`))
+    expect(t, "返回值检测", nil == err)
+
+    declaration := doc.Declaration()
+    expect(t, "声明就是第一个处理指令", nil != declaration && doc.FirstChild() == declaration)
+    expect(t, "解析声明", "1.0" == declaration.Version() && "ISO-8859-1" == declaration.Encoding() && "yes" == declaration.Standalone())
+
+    declaration.SetStandalone("")
+    declaration.SetEncoding("UTF-8")
+    expect(t, "修改声明", `version="1.0" encoding="UTF-8"` == declaration.Instruction())
+
+    doc, err = tinydom.LoadDocument(strings.NewReader(`<?xml-stylesheet href="a.xsl"?><a/>`))
+    expect(t, "没有声明", nil == err && nil == doc.Declaration())
+
+    procInst := doc.FirstChild().ToProcInst()
+    _, ok := procInst.(tinydom.XMLDeclaration)
+    expect(t, "其他处理指令不是XML声明", !ok)
+    _, ok = tinydom.NewProcInst(doc, "xml", "").(tinydom.XMLDeclaration)
+    expect(t, "新建的xml处理指令是XML声明", ok)
+
+    procInst.SetInstruction(`href="b.xsl"`)
+    expect(t, "修改处理指令", `<?xml-stylesheet href="b.xsl"?>`+"\n"+`<a/>` == doc.String())
+}
+
+func Test_Declaration_新建的声明(t *testing.T) {
+    doc := tinydom.NewDocument()
+    doc.InsertEndChild(tinydom.NewComment(doc, "c"))
+    doc.SetRootElement(tinydom.NewElement(doc, "root"))
+
+    declaration := doc.SetDeclaration("", "UTF-8", "")
+    expect(t, "插入到最前面", doc.FirstChild() == declaration && doc.Declaration() == declaration)
+    expect(t, "version默认为1.0", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!--c--><root/>" == doc.String())
+
+    expect(t, "修改已有的声明", declaration == doc.SetDeclaration("1.0", "", "no") && `version="1.0" standalone="no"` == declaration.Instruction())
+    expect(t, "不会插入第二个声明", doc.FirstChild().NextSibling().ToComment() != nil)
+
+    declaration.SetVersion("")
+    expect(t, "SetVersion", "1.0" == declaration.Version())
+
+    declaration.SetEncoding("ISO-8859-1")
+    buf := bytes.NewBufferString("")
+    expect(t, "输出时改写encoding", nil == doc.SaveTo(buf) && strings.HasPrefix(buf.String(), `<?xml version="1.0" encoding="UTF-8" standalone="no"?>`))
+
+    loaded, err := tinydom.LoadDocument(bytes.NewReader(buf.Bytes()))
+    expect(t, "重新加载", nil == err && "no" == loaded.Declaration().Standalone() && "root" == loaded.RootElement().Name())
+}
@@ -0,0 +1,350 @@
+package tinydom
+
+import (
+    "encoding"
+    "encoding/xml"
+    "fmt"
+    "reflect"
+    "strings"
+    "time"
+)
+
+//	Decode	把elem及其子树绑定到v指向的Go值上，v必须是非nil的指针
+//
+//	映射规则与encoding/xml.Unmarshal相同：
+//	    XMLName字段            接收元素的名字，tag中指定了名字时元素的名字必须与之相符
+//	    `xml:"name,attr"`       同名的属性，`xml:",any,attr"`接收没有被其他字段匹配的属性
+//	    `xml:",chardata"`       元素直接包含的文本(包括CDATA)，`xml:",cdata"`与之相同
+//	    `xml:",innerxml"`       元素内容的XML文本，字段必须是string或者[]byte
+//	    `xml:",comment"`        元素直接包含的注释
+//	    `xml:"a>b"`             子元素a中的子元素b
+//	    `xml:",any"`            没有被其他字段匹配的子元素
+//	    其他字段               与字段名(或者tag中的名字)相同的子元素，tag可以写成"名字空间 名字"来要求元素属于指定的名字空间
+//
+//	名字可以是本地名或者限定名；切片字段会依次追加每一个匹配的元素；字段是XMLElement时直接得到匹配的元素本身。
+//	实现了encoding.TextUnmarshaler的类型通过UnmarshalText解析文本，time.Duration按照time.ParseDuration的格式解析，
+//	整数和布尔值的格式与IntAttribute等方法相同。
+func Decode(elem XMLElement, v interface{}) error {
+    if nil == elem {
+        return &BindError{Msg: "nil element"}
+    }
+
+    val := reflect.ValueOf(v)
+    if reflect.Ptr != val.Kind() || val.IsNil() {
+        return &BindError{Msg: fmt.Sprintf("non-nil pointer required, got %T", v)}
+    }
+    return decodeElement(elem, val.Elem(), elem.Name())
+}
+
+//	decodeElement	把elem绑定到val上
+func decodeElement(elem XMLElement, val reflect.Value, path string) error {
+    if xmlElementType == val.Type() {
+        val.Set(reflect.ValueOf(elem))
+        return nil
+    }
+
+    switch val.Kind() {
+    case reflect.Ptr:
+        if val.IsNil() {
+            val.Set(reflect.New(val.Type().Elem()))
+        }
+        return decodeElement(elem, val.Elem(), path)
+
+    case reflect.Interface:
+        //  无法确定接口的具体类型，与encoding/xml一样忽略
+        return nil
+
+    case reflect.Slice:
+        if reflect.Uint8 != val.Type().Elem().Kind() {
+            n := val.Len()
+            val.Set(reflect.Append(val, reflect.Zero(val.Type().Elem())))
+            if err := decodeElement(elem, val.Index(n), path); nil != err {
+                val.SetLen(n)
+                return err
+            }
+            return nil
+        }
+
+    case reflect.Struct:
+        if nameType != val.Type() && !val.Addr().Type().Implements(unmarshalerType) {
+            return decodeStruct(elem, val, path)
+        }
+    }
+
+    return decodeText(val, charData(elem), path)
+}
+
+//	decodeStruct	按照结构体的映射信息绑定elem的名字、属性、文本和子元素
+func decodeStruct(elem XMLElement, val reflect.Value, path string) error {
+    tinfo, err := getTypeInfo(val.Type())
+    if nil != err {
+        return err
+    }
+
+    if nil != tinfo.xmlname {
+        finfo := tinfo.xmlname
+        if "" != finfo.name && !finfo.matchElement(elem) {
+            expected := finfo.name
+            if "" != finfo.xmlns {
+                expected = finfo.xmlns + " " + expected
+            }
+            return &BindError{Path: path, Msg: fmt.Sprintf("expected element <%s> but have <%s>", expected, elem.Name())}
+        }
+        if field := fieldValue(val, finfo.idx, true); nameType == field.Type() {
+            field.Set(reflect.ValueOf(xml.Name{Space: elem.NamespaceURI(), Local: elem.LocalName()}))
+        }
+    }
+
+    var anyAttr *fieldInfo
+    for i := range tinfo.fields {
+        finfo := &tinfo.fields[i]
+        switch finfo.flags & fMode {
+        case fAttr:
+            for attr := elem.FirstAttribute(); nil != attr; attr = attr.NextAttribute() {
+                if finfo.matchAttribute(attr) {
+                    if err := decodeAttr(attr, fieldValue(val, finfo.idx, true), path+"@"+attr.Name()); nil != err {
+                        return err
+                    }
+                    break
+                }
+            }
+        case fAny | fAttr:
+            anyAttr = finfo
+        case fCharData, fCDATA:
+            if err := decodeText(fieldValue(val, finfo.idx, true), charData(elem), path); nil != err {
+                return err
+            }
+        case fInnerXML:
+            if err := decodeText(fieldValue(val, finfo.idx, true), innerXML(elem), path); nil != err {
+                return err
+            }
+        case fComment:
+            if err := decodeText(fieldValue(val, finfo.idx, true), comments(elem), path); nil != err {
+                return err
+            }
+        }
+    }
+
+    if nil != anyAttr {
+        for attr := elem.FirstAttribute(); nil != attr; attr = attr.NextAttribute() {
+            if tinfo.hasAttribute(attr) {
+                continue
+            }
+            if err := decodeAttr(attr, fieldValue(val, anyAttr.idx, true), path+"@"+attr.Name()); nil != err {
+                return err
+            }
+        }
+    }
+
+    return decodeChildren(elem, val, tinfo, nil, path)
+}
+
+//	decodeChildren	绑定elem的子元素，parents是a>b形式的路径中已经进入的元素
+func decodeChildren(elem XMLElement, val reflect.Value, tinfo *typeInfo, parents []string, path string) error {
+    for child := elem.FirstChildElement(""); nil != child; child = child.NextSiblingElement("") {
+        childPath := path + ">" + child.Name()
+        finfo, descend := tinfo.findElement(child, parents)
+        if descend {
+            next := append(append([]string{}, parents...), finfo.parents[len(parents)])
+            if err := decodeChildren(child, val, tinfo, next, childPath); nil != err {
+                return err
+            }
+        } else if nil != finfo {
+            if err := decodeElement(child, fieldValue(val, finfo.idx, true), childPath); nil != err {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+//	findElement	查找与子元素匹配的字段，descend为true时表示子元素是字段路径中的一级
+func (this *typeInfo) findElement(child XMLElement, parents []string) (finfo *fieldInfo, descend bool) {
+    var anyElem *fieldInfo
+    for i := range this.fields {
+        finfo := &this.fields[i]
+        if 0 == finfo.flags&fElement || len(finfo.parents) < len(parents) {
+            continue
+        }
+
+        matched := true
+        for j := range parents {
+            if finfo.parents[j] != parents[j] {
+                matched = false
+                break
+            }
+        }
+        if !matched {
+            continue
+        }
+
+        if len(finfo.parents) > len(parents) {
+            name := finfo.parents[len(parents)]
+            if child.LocalName() == name || child.Name() == name {
+                return finfo, true
+            }
+            continue
+        }
+        if 0 != finfo.flags&fAny {
+            if nil == anyElem && 0 == len(parents) {
+                anyElem = finfo
+            }
+            continue
+        }
+        if finfo.matchElement(child) {
+            return finfo, false
+        }
+    }
+    return anyElem, false
+}
+
+//	hasAttribute	判断属性是否已经被某个attr字段匹配
+func (this *typeInfo) hasAttribute(attr XMLAttribute) bool {
+    for i := range this.fields {
+        if fAttr == this.fields[i].flags&fMode && this.fields[i].matchAttribute(attr) {
+            return true
+        }
+    }
+    return false
+}
+
+//	decodeAttr	把属性绑定到val上，val可以是xml.Attr、切片或者能够从文本解析的类型
+func decodeAttr(attr XMLAttribute, val reflect.Value, path string) error {
+    if attrType == val.Type() {
+        val.Set(reflect.ValueOf(xml.Attr{Name: xml.Name{Space: attr.NamespaceURI(), Local: attr.LocalName()}, Value: attr.Value()}))
+        return nil
+    }
+
+    switch val.Kind() {
+    case reflect.Ptr:
+        if val.IsNil() {
+            val.Set(reflect.New(val.Type().Elem()))
+        }
+        return decodeAttr(attr, val.Elem(), path)
+
+    case reflect.Slice:
+        if reflect.Uint8 != val.Type().Elem().Kind() {
+            n := val.Len()
+            val.Set(reflect.Append(val, reflect.Zero(val.Type().Elem())))
+            if err := decodeAttr(attr, val.Index(n), path); nil != err {
+                val.SetLen(n)
+                return err
+            }
+            return nil
+        }
+    }
+
+    return decodeText(val, attr.Value(), path)
+}
+
+//	decodeText	把文本解析到val上
+func decodeText(val reflect.Value, text string, path string) error {
+    if reflect.Ptr == val.Kind() {
+        if val.IsNil() {
+            val.Set(reflect.New(val.Type().Elem()))
+        }
+        val = val.Elem()
+    }
+
+    if val.CanAddr() && val.Addr().Type().Implements(unmarshalerType) {
+        if err := val.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); nil != err {
+            return &BindError{Path: path, Msg: err.Error()}
+        }
+        return nil
+    }
+
+    blank := "" == strings.TrimFunc(text, isXMLSpace)
+    var err error
+    switch val.Kind() {
+    case reflect.String:
+        val.SetString(text)
+        return nil
+
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        var value int64
+        if !blank && durationType == val.Type() {
+            var duration time.Duration
+            duration, err = parseDuration(text)
+            value = int64(duration)
+        } else if !blank {
+            value, err = parseInt(text, val.Type().Bits())
+        }
+        if nil == err {
+            val.SetInt(value)
+            return nil
+        }
+
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        var value uint64
+        if !blank {
+            value, err = parseUint(text, val.Type().Bits())
+        }
+        if nil == err {
+            val.SetUint(value)
+            return nil
+        }
+
+    case reflect.Float32, reflect.Float64:
+        var value float64
+        if !blank {
+            value, err = parseFloat(text)
+        }
+        if nil == err {
+            val.SetFloat(value)
+            return nil
+        }
+
+    case reflect.Bool:
+        var value bool
+        if !blank {
+            value, err = parseBool(text)
+        }
+        if nil == err {
+            val.SetBool(value)
+            return nil
+        }
+
+    case reflect.Slice:
+        if reflect.Uint8 == val.Type().Elem().Kind() {
+            val.SetBytes([]byte(text))
+            return nil
+        }
+        return &BindError{Path: path, Msg: "cannot bind text into " + val.Type().String()}
+
+    default:
+        return &BindError{Path: path, Msg: "cannot bind text into " + val.Type().String()}
+    }
+
+    return &BindError{Path: path, Msg: fmt.Sprintf("cannot parse %q as %s", text, val.Type())}
+}
+
+//	charData	拼接元素直接包含的文本节点(包括CDATA)
+func charData(elem XMLElement) string {
+    var buf strings.Builder
+    for child := elem.FirstChild(); nil != child; child = child.NextSibling() {
+        if nil != child.ToText() {
+            buf.WriteString(child.Value())
+        }
+    }
+    return buf.String()
+}
+
+//	innerXML	返回元素内容的XML文本
+func innerXML(elem XMLElement) string {
+    var buf strings.Builder
+    for child := elem.FirstChild(); nil != child; child = child.NextSibling() {
+        buf.WriteString(child.String())
+    }
+    return buf.String()
+}
+
+//	comments	拼接元素直接包含的注释
+func comments(elem XMLElement) string {
+    var buf strings.Builder
+    for child := elem.FirstChild(); nil != child; child = child.NextSibling() {
+        if comment := child.ToComment(); nil != comment {
+            buf.WriteString(comment.Comment())
+        }
+    }
+    return buf.String()
+}
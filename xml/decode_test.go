@@ -0,0 +1,192 @@
+package tinydom_test
+
+import (
+    "encoding/xml"
+    "strings"
+    "testing"
+    "time"
+    "tinydom/xml"
+)
+
+type decodeAuthor struct {
+    Name  string `xml:",chardata"`
+    Email string `xml:"email,attr"`
+}
+
+type decodeBook struct {
+    XMLName  xml.Name       `xml:"book"`
+    ID       string         `xml:"id,attr"`
+    Year     int            `xml:"year,attr"`
+    Price    *float64       `xml:"price"`
+    Title    string         `xml:"title"`
+    Authors  []decodeAuthor `xml:"authors>author"`
+    Tags     []string       `xml:"meta>tags>tag"`
+    Loan     time.Duration  `xml:"loan"`
+    Note     string         `xml:",comment"`
+    Hidden   string         `xml:"-"`
+    internal string
+}
+
+const decodeBookXML = `<library>
+    <book id="b1" year="2001">
+        <!--classic-->
+        <title>The Moon</title>
+        <price> 12.5 </price>
+        <authors><author email="tom@example.com">Tom</author><author>Ann</author></authors>
+        <meta><tags><tag>space</tag><tag>night</tag></tags></meta>
+        <loan>72h</loan>
+        <Hidden>x</Hidden>
+    </book>
+    <book id="b2"><title><![CDATA[Stars & <Sky>]]></title></book>
+</library>`
+
+func Test_Decode_绑定结构体(t *testing.T) {
+    doc := loadString(t, decodeBookXML)
+
+    var book decodeBook
+    err := tinydom.Decode(doc.QuerySelector("#b1"), &book)
+    expect(t, "返回值检测", nil == err)
+    expect(t, "XMLName", "book" == book.XMLName.Local)
+    expect(t, "属性", "b1" == book.ID && 2001 == book.Year)
+    expect(t, "子元素", "The Moon" == book.Title && nil != book.Price && 12.5 == *book.Price)
+    expect(t, "a>b路径", 2 == len(book.Authors) && "Tom" == book.Authors[0].Name && "tom@example.com" == book.Authors[0].Email && "Ann" == book.Authors[1].Name)
+    expect(t, "多级路径", "space,night" == strings.Join(book.Tags, ","))
+    expect(t, "time.Duration", 72*time.Hour == book.Loan)
+    expect(t, "注释", "classic" == book.Note)
+    expect(t, "忽略的字段", "" == book.Hidden && "" == book.internal)
+
+    var other decodeBook
+    err = tinydom.Decode(doc.QuerySelector("#b2"), &other)
+    expect(t, "CDATA", nil == err && "Stars & <Sky>" == other.Title && nil == other.Price && 0 == other.Year)
+
+    var books struct {
+        Books []decodeBook `xml:"book"`
+        Count int          `xml:"count,attr"`
+    }
+    err = tinydom.Decode(doc.FirstChildElement("library"), &books)
+    expect(t, "切片", nil == err && 2 == len(books.Books) && "b2" == books.Books[1].ID)
+}
+
+func Test_Decode_绑定子树(t *testing.T) {
+    doc := loadString(t, decodeBookXML)
+
+    var authors []decodeAuthor
+    for _, elem := range doc.QuerySelectorAll("author") {
+        expect(t, "返回值检测", nil == tinydom.Decode(elem, &authors))
+    }
+    expect(t, "追加到切片", 2 == len(authors) && "Ann" == authors[1].Name)
+
+    var title string
+    expect(t, "简单类型", nil == tinydom.Decode(doc.QuerySelector("title"), &title) && "The Moon" == title)
+
+    var inner struct {
+        XML  string `xml:",innerxml"`
+        Text string `xml:",chardata"`
+    }
+    expect(t, "innerxml", nil == tinydom.Decode(doc.QuerySelector("authors"), &inner))
+    expect(t, "innerxml的内容", `<author email="tom@example.com">Tom</author><author>Ann</author>` == inner.XML && "" == inner.Text)
+
+    var nodes struct {
+        Title tinydom.XMLElement   `xml:"title"`
+        Rest  []tinydom.XMLElement `xml:",any"`
+        Attrs []xml.Attr           `xml:",any,attr"`
+        ID    string               `xml:"id,attr"`
+    }
+    expect(t, "返回值检测", nil == tinydom.Decode(doc.QuerySelector("#b1"), &nodes))
+    expect(t, "XMLElement字段", nil != nodes.Title && "The Moon" == nodes.Title.Text())
+    expect(t, "any元素", 5 == len(nodes.Rest) && "price" == nodes.Rest[0].Name())
+    expect(t, "any属性", 1 == len(nodes.Attrs) && "year" == nodes.Attrs[0].Name.Local && "2001" == nodes.Attrs[0].Value)
+}
+
+func Test_Decode_名字空间(t *testing.T) {
+    source := `<feed xmlns="urn:feed" xmlns:m="urn:media" m:lang="en"><m:thumb url="a"/><thumb url="b"/><title>T</title></feed>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(source))
+    expect(t, "返回值检测", nil == err)
+
+    type thumb struct {
+        URL string `xml:"url,attr"`
+    }
+    var feed struct {
+        XMLName xml.Name `xml:"urn:feed feed"`
+        Media   []thumb  `xml:"urn:media thumb"`
+        Plain   []thumb  `xml:"urn:feed thumb"`
+        Lang    string   `xml:"urn:media lang,attr"`
+        Title   string   `xml:"title"`
+    }
+    err = tinydom.Decode(doc.FirstChildElement("feed"), &feed)
+    expect(t, "返回值检测", nil == err)
+    expect(t, "XMLName", "urn:feed" == feed.XMLName.Space && "feed" == feed.XMLName.Local)
+    expect(t, "按名字空间匹配", 1 == len(feed.Media) && "a" == feed.Media[0].URL && 1 == len(feed.Plain) && "b" == feed.Plain[0].URL)
+    expect(t, "名字空间的属性", "en" == feed.Lang && "T" == feed.Title)
+
+    var qualified struct {
+        Thumb thumb `xml:"m:thumb"`
+    }
+    expect(t, "限定名", nil == tinydom.Decode(doc.FirstChildElement("feed"), &qualified) && "a" == qualified.Thumb.URL)
+}
+
+type decodeBase struct {
+    ID   string `xml:"id,attr"`
+    Name string `xml:"name"`
+}
+
+type decodeDerived struct {
+    decodeBase
+    Name  string `xml:"title"`
+    Extra *decodeBase
+}
+
+func Test_Decode_嵌入结构体和自定义类型(t *testing.T) {
+    xml := `<item id="7" at="2020-01-02T03:04:05Z"><name>N</name><title>T</title><Extra id="8"><name>E</name></Extra></item>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+
+    var item decodeDerived
+    expect(t, "返回值检测", nil == tinydom.Decode(doc.FirstChildElement("item"), &item))
+    expect(t, "嵌入结构体的字段", "7" == item.ID && "N" == item.decodeBase.Name && "T" == item.Name)
+    expect(t, "指针字段", nil != item.Extra && "8" == item.Extra.ID && "E" == item.Extra.Name)
+
+    var stamped struct {
+        At time.Time `xml:"at,attr"`
+    }
+    expect(t, "TextUnmarshaler", nil == tinydom.Decode(doc.FirstChildElement("item"), &stamped) && 2020 == stamped.At.Year())
+}
+
+func Test_Decode_错误(t *testing.T) {
+    doc := loadString(t, decodeBookXML)
+    book := doc.QuerySelector("#b1")
+
+    var value decodeBook
+    expect(t, "非指针", nil != tinydom.Decode(book, value))
+    expect(t, "nil指针", nil != tinydom.Decode(book, (*decodeBook)(nil)))
+    expect(t, "nil元素", nil != tinydom.Decode(nil, &value))
+
+    err := tinydom.Decode(doc.FirstChildElement("library"), &value)
+    bindErr, ok := err.(*tinydom.BindError)
+    expect(t, "元素名字不符", ok && "library" == bindErr.Path && strings.Contains(bindErr.Msg, "<book>"))
+
+    var wrong struct {
+        Title int `xml:"title"`
+    }
+    err = tinydom.Decode(book, &wrong)
+    bindErr, ok = err.(*tinydom.BindError)
+    expect(t, "类型错误", ok && "book>title" == bindErr.Path)
+
+    var badYear struct {
+        ID uint8 `xml:"year,attr"`
+    }
+    err = tinydom.Decode(book, &badYear)
+    expect(t, "超出范围", nil != err && "bind book@year: cannot parse \"2001\" as uint8" == err.Error())
+
+    var badTag struct {
+        Text string `xml:"name,chardata"`
+    }
+    _, ok = tinydom.Decode(book, &badTag).(*tinydom.BindError)
+    expect(t, "错误的tag", ok)
+
+    var conflict struct {
+        A string `xml:"a"`
+        B string `xml:"a>b"`
+    }
+    expect(t, "冲突的字段", nil != tinydom.Decode(book, &conflict))
+}
@@ -0,0 +1,919 @@
+package tinydom
+
+import (
+    "errors"
+    "io"
+    "io/ioutil"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+var (
+    //  ErrNotDocType   指令不是DOCTYPE声明
+    ErrNotDocType = errors.New("directive is not a DOCTYPE declaration")
+    //  ErrInvalidDTD   DOCTYPE声明或者DTD不符合语法
+    ErrInvalidDTD = errors.New("invalid DTD syntax")
+)
+
+//  DocType 是解析后的DOCTYPE声明
+type DocType struct {
+    //  Name    根元素的名字
+    Name string
+    //  PublicID    PUBLIC标识符，没有时为空
+    PublicID string
+    //  SystemID    SYSTEM标识符，也就是外部DTD的位置，没有时为空
+    SystemID string
+    //  Subset  内部子集，没有内部子集时为nil
+    Subset *DTD
+}
+
+//  DTD 是按照出现的顺序保存的一组标记声明
+type DTD struct {
+    Decls []DTDDecl
+}
+
+//  DTDDecl 是DTD中的一项，可以是*ElementDecl、*AttlistDecl、*EntityDecl、*NotationDecl或者*RawDecl，
+//  String返回它在DTD中的文本形式
+type DTDDecl interface {
+    String() string
+}
+
+//  ContentKind 元素声明的内容类型
+type ContentKind int
+
+const (
+    //  EmptyContent    EMPTY，元素不能有任何内容
+    EmptyContent ContentKind = iota
+    //  AnyContent  ANY，元素可以有任何内容
+    AnyContent
+    //  MixedContent    (#PCDATA|a|b)*，文本与指定的元素混合
+    MixedContent
+    //  ElementContent  只能包含符合内容模型的子元素
+    ElementContent
+)
+
+//  ElementDecl 是<!ELEMENT>声明
+type ElementDecl struct {
+    Name string
+    Kind ContentKind
+    //  Mixed   混合内容中允许出现的元素，Kind为MixedContent时有效
+    Mixed []string
+    //  Model   子元素的内容模型，Kind为ElementContent时有效
+    Model *ContentParticle
+}
+
+//  ContentParticle 是内容模型中的一项：一个元素名，或者由子项组成的序列(a,b)、选择(a|b)
+type ContentParticle struct {
+    //  Name    元素名，为空时表示由Children组成的序列或者选择
+    Name string
+    //  Choice  为true时Children是选择，否则是序列
+    Choice   bool
+    Children []*ContentParticle
+    //  Occurs  出现的次数：'?'、'*'、'+'，为0时表示恰好出现一次
+    Occurs byte
+}
+
+//  AttlistDecl 是<!ATTLIST>声明
+type AttlistDecl struct {
+    Element    string
+    Attributes []*AttributeDecl
+}
+
+//  AttributeDecl   是ATTLIST中的一个属性定义
+type AttributeDecl struct {
+    Name string
+    //  Type    CDATA、ID、IDREF、IDREFS、ENTITY、ENTITIES、NMTOKEN、NMTOKENS或者NOTATION，枚举类型时为空
+    Type string
+    //  Enum    枚举类型以及NOTATION类型允许的值
+    Enum []string
+    //  Default #REQUIRED、#IMPLIED或者#FIXED，为空时表示Value是默认值
+    Default string
+    //  Value   默认值或者#FIXED的值
+    Value string
+}
+
+//  EntityDecl  是<!ENTITY>声明
+type EntityDecl struct {
+    Name string
+    //  Parameter   为true时是参数实体，也就是<!ENTITY % name ...>
+    Parameter bool
+    //  Value   内部实体的替换文本，原样保存，其中的引用不会被展开
+    Value    string
+    PublicID string
+    SystemID string
+    //  NData   外部未解析实体的NOTATION名字
+    NData string
+}
+
+//  NotationDecl    是<!NOTATION>声明
+type NotationDecl struct {
+    Name     string
+    PublicID string
+    SystemID string
+}
+
+//  RawDecl 是DTD中不需要解析的内容，比如注释、处理指令和参数实体引用，原样保存
+type RawDecl struct {
+    Text string
+}
+
+//	Element	返回元素的声明，没有时返回nil
+func (this *DTD) Element(name string) *ElementDecl {
+    for _, decl := range this.Decls {
+        if elem, ok := decl.(*ElementDecl); ok && (name == elem.Name) {
+            return elem
+        }
+    }
+    return nil
+}
+
+//	Attributes	返回元素的所有属性定义，多个ATTLIST中的定义会被合并，同名的属性以最先出现的为准
+func (this *DTD) Attributes(element string) []*AttributeDecl {
+    var attributes []*AttributeDecl
+    declared := make(map[string]bool)
+    for _, decl := range this.Decls {
+        attlist, ok := decl.(*AttlistDecl)
+        if !ok || (element != attlist.Element) {
+            continue
+        }
+
+        for _, attribute := range attlist.Attributes {
+            if !declared[attribute.Name] {
+                declared[attribute.Name] = true
+                attributes = append(attributes, attribute)
+            }
+        }
+    }
+    return attributes
+}
+
+//	Entity	返回一般实体的声明，同名的实体以最先出现的为准，没有时返回nil
+func (this *DTD) Entity(name string) *EntityDecl {
+    for _, decl := range this.Decls {
+        if entity, ok := decl.(*EntityDecl); ok && !entity.Parameter && (name == entity.Name) {
+            return entity
+        }
+    }
+    return nil
+}
+
+//	Notation	返回NOTATION的声明，没有时返回nil
+func (this *DTD) Notation(name string) *NotationDecl {
+    for _, decl := range this.Decls {
+        if notation, ok := decl.(*NotationDecl); ok && (name == notation.Name) {
+            return notation
+        }
+    }
+    return nil
+}
+
+//------------------------------------------------------------------
+
+//	String	返回DOCTYPE指令的内容，不包括两端的"<!"和">"
+func (this *DocType) String() string {
+    text := "DOCTYPE " + this.Name + formatExternalID(this.PublicID, this.SystemID)
+    if nil != this.Subset {
+        text += " [\n" + this.Subset.String() + "\n]"
+    }
+    return text
+}
+
+//	String	每个声明占一行
+func (this *DTD) String() string {
+    lines := make([]string, 0, len(this.Decls))
+    for _, decl := range this.Decls {
+        lines = append(lines, decl.String())
+    }
+    return strings.Join(lines, "\n")
+}
+
+func (this *ElementDecl) String() string {
+    spec := "EMPTY"
+    switch this.Kind {
+    case AnyContent:
+        spec = "ANY"
+    case MixedContent:
+        if 0 == len(this.Mixed) {
+            spec = "(#PCDATA)"
+        } else {
+            spec = "(#PCDATA|" + strings.Join(this.Mixed, "|") + ")*"
+        }
+    case ElementContent:
+        spec = "()"
+        if nil != this.Model {
+            spec = this.Model.String()
+        }
+        //  最外层的内容模型必须是一个括号
+        if (nil != this.Model) && ("" != this.Model.Name) {
+            spec = "(" + this.Model.Name + ")" + occursString(this.Model.Occurs)
+        }
+    }
+    return "<!ELEMENT " + this.Name + " " + spec + ">"
+}
+
+func (this *ContentParticle) String() string {
+    if "" != this.Name {
+        return this.Name + occursString(this.Occurs)
+    }
+
+    separator := ","
+    if this.Choice {
+        separator = "|"
+    }
+
+    children := make([]string, 0, len(this.Children))
+    for _, child := range this.Children {
+        children = append(children, child.String())
+    }
+    return "(" + strings.Join(children, separator) + ")" + occursString(this.Occurs)
+}
+
+func (this *AttlistDecl) String() string {
+    text := "<!ATTLIST " + this.Element
+    for _, attribute := range this.Attributes {
+        text += " " + attribute.String()
+    }
+    return text + ">"
+}
+
+//	String	返回属性定义的文本形式，比如id ID #REQUIRED
+func (this *AttributeDecl) String() string {
+    text := this.Name + " "
+    switch this.Type {
+    case "":
+        text += "(" + strings.Join(this.Enum, "|") + ")"
+    case "NOTATION":
+        text += "NOTATION (" + strings.Join(this.Enum, "|") + ")"
+    default:
+        text += this.Type
+    }
+
+    switch this.Default {
+    case "#REQUIRED", "#IMPLIED":
+        return text + " " + this.Default
+    case "#FIXED":
+        return text + " #FIXED " + quoteLiteral(this.Value)
+    }
+    return text + " " + quoteLiteral(this.Value)
+}
+
+func (this *EntityDecl) String() string {
+    text := "<!ENTITY "
+    if this.Parameter {
+        text += "% "
+    }
+    text += this.Name
+
+    if ("" == this.PublicID) && ("" == this.SystemID) {
+        return text + " " + quoteLiteral(this.Value) + ">"
+    }
+
+    text += formatExternalID(this.PublicID, this.SystemID)
+    if "" != this.NData {
+        text += " NDATA " + this.NData
+    }
+    return text + ">"
+}
+
+func (this *NotationDecl) String() string {
+    if "" == this.SystemID {
+        return "<!NOTATION " + this.Name + " PUBLIC " + quoteLiteral(this.PublicID) + ">"
+    }
+    return "<!NOTATION " + this.Name + formatExternalID(this.PublicID, this.SystemID) + ">"
+}
+
+func (this *RawDecl) String() string {
+    return this.Text
+}
+
+//	formatExternalID	返回以空格开头的PUBLIC或者SYSTEM标识符，两者都为空时返回空字符串
+func formatExternalID(publicID string, systemID string) string {
+    if "" != publicID {
+        return " PUBLIC " + quoteLiteral(publicID) + " " + quoteLiteral(systemID)
+    }
+    if "" != systemID {
+        return " SYSTEM " + quoteLiteral(systemID)
+    }
+    return ""
+}
+
+//	quoteLiteral	用双引号包围字面量，字面量中含有双引号时使用单引号
+func quoteLiteral(literal string) string {
+    if strings.Contains(literal, `"`) {
+        return "'" + literal + "'"
+    }
+    return `"` + literal + `"`
+}
+
+func occursString(occurs byte) string {
+    if 0 == occurs {
+        return ""
+    }
+    return string(occurs)
+}
+
+//------------------------------------------------------------------
+
+func (this *xmlDirectiveImpl) DocType() (*DocType, error) {
+    text := strings.TrimLeft(this.value, " \t\r\n")
+    if !strings.HasPrefix(text, "DOCTYPE") || ((len(text) > len("DOCTYPE")) && !isXMLSpace(rune(text[len("DOCTYPE")]))) {
+        return nil, ErrNotDocType
+    }
+
+    //  位置从指令开头的"<!"之后开始计算
+    start := this.Position()
+    if start.IsValid() {
+        start.Column += 2
+        start.Offset += 2
+    } else {
+        start = Position{Line: 1, Column: 1}
+    }
+
+    parser := &dtdParser{text: this.value, start: start}
+    return parser.parseDocType()
+}
+
+func (this *xmlDirectiveImpl) SetDocType(docType *DocType) {
+    this.value = docType.String()
+}
+
+//	NewDocType	用docType创建一个新的DOCTYPE指令
+func NewDocType(document XMLDocument, docType *DocType) XMLDirective {
+    return NewDirective(document, docType.String())
+}
+
+//	ParseDTD	从rd中读取外部DTD，比如DOCTYPE中SYSTEM标识符指向的文件
+//
+//	字符集的识别方式与LoadDocument相同，开头的<?xml ...?>文本声明作为RawDecl保存。解析失败时返回Err为ErrInvalidDTD的*ParseError
+func ParseDTD(rd io.Reader) (*DTD, error) {
+    decoded, charset, err := newDecodingReader(rd, "")
+    if nil != err {
+        return nil, &ParseError{Position: Position{Line: 1, Column: 1}, Msg: "unsupported charset: " + charset, Err: err}
+    }
+
+    data, err := ioutil.ReadAll(decoded)
+    if nil != err {
+        return nil, err
+    }
+
+    parser := &dtdParser{text: string(data), start: Position{Line: 1, Column: 1}}
+    return parser.parseDecls(false)
+}
+
+//------------------------------------------------------------------
+
+//  dtdParser   解析DOCTYPE声明以及DTD中的标记声明
+type dtdParser struct {
+    text string
+    pos  int
+    //  text开始处的位置
+    start Position
+}
+
+//	fail	返回当前位置上的语法错误
+func (this *dtdParser) fail(msg string) error {
+    position := this.start
+    for i := 0; i < this.pos; i++ {
+        position.Offset++
+        if '\n' == this.text[i] {
+            position.Line++
+            position.Column = 1
+        } else {
+            position.Column++
+        }
+    }
+    return &ParseError{Position: position, Msg: msg, Err: ErrInvalidDTD}
+}
+
+func (this *dtdParser) eof() bool {
+    return this.pos >= len(this.text)
+}
+
+func (this *dtdParser) peek() byte {
+    if this.eof() {
+        return 0
+    }
+    return this.text[this.pos]
+}
+
+//	consume	当前位置以prefix开头时跳过它并返回true
+func (this *dtdParser) consume(prefix string) bool {
+    if strings.HasPrefix(this.text[this.pos:], prefix) {
+        this.pos += len(prefix)
+        return true
+    }
+    return false
+}
+
+//	skipSpace	跳过空白，返回是否存在空白
+func (this *dtdParser) skipSpace() bool {
+    start := this.pos
+    for !this.eof() && isXMLSpace(rune(this.text[this.pos])) {
+        this.pos++
+    }
+    return this.pos > start
+}
+
+//	requireSpace	跳过必须存在的空白
+func (this *dtdParser) requireSpace() error {
+    if !this.skipSpace() {
+        return this.fail("expected whitespace")
+    }
+    return nil
+}
+
+//	expect	跳过必须出现的字符串
+func (this *dtdParser) expect(str string) error {
+    if !this.consume(str) {
+        return this.fail("expected " + str)
+    }
+    return nil
+}
+
+//	token	读取一个名字，first为true时第一个字符必须是名字的开头字符，否则读取的是NMTOKEN
+func (this *dtdParser) token(first bool) string {
+    start := this.pos
+    for !this.eof() {
+        r, width := utf8.DecodeRuneInString(this.text[this.pos:])
+        if !isNameChar(r) || (first && (this.pos == start) && !isNameStartChar(r)) {
+            break
+        }
+        this.pos += width
+    }
+    return this.text[start:this.pos]
+}
+
+func (this *dtdParser) name() (string, error) {
+    name := this.token(true)
+    if "" == name {
+        return "", this.fail("expected name")
+    }
+    return name, nil
+}
+
+//	literal	读取由单引号或者双引号包围的字面量
+func (this *dtdParser) literal() (string, error) {
+    quote := this.peek()
+    if ('"' != quote) && ('\'' != quote) {
+        return "", this.fail("expected quoted literal")
+    }
+
+    end := strings.IndexByte(this.text[this.pos+1:], quote)
+    if end < 0 {
+        return "", this.fail("unterminated literal")
+    }
+
+    literal := this.text[this.pos+1 : this.pos+1+end]
+    this.pos += end + 2
+    return literal, nil
+}
+
+//	externalID	解析SYSTEM "s"或者PUBLIC "p" "s"，publicOnly为true时PUBLIC之后的SYSTEM标识符可以省略
+func (this *dtdParser) externalID(publicOnly bool) (publicID string, systemID string, err error) {
+    switch {
+    case this.consume("SYSTEM"):
+        if err = this.requireSpace(); nil != err {
+            return
+        }
+        systemID, err = this.literal()
+        return
+    case this.consume("PUBLIC"):
+        if err = this.requireSpace(); nil != err {
+            return
+        }
+        if publicID, err = this.literal(); nil != err {
+            return
+        }
+
+        save := this.pos
+        hasSpace := this.skipSpace()
+        if publicOnly && (('"' != this.peek()) && ('\'' != this.peek())) {
+            this.pos = save
+            return
+        }
+        if !hasSpace {
+            err = this.fail("expected whitespace")
+            return
+        }
+        systemID, err = this.literal()
+        return
+    }
+    err = this.fail("expected SYSTEM or PUBLIC")
+    return
+}
+
+//	parseDocType	解析DOCTYPE指令的内容
+func (this *dtdParser) parseDocType() (*DocType, error) {
+    docType := new(DocType)
+    this.skipSpace()
+    if err := this.expect("DOCTYPE"); nil != err {
+        return nil, err
+    }
+    if err := this.requireSpace(); nil != err {
+        return nil, err
+    }
+
+    var err error
+    if docType.Name, err = this.name(); nil != err {
+        return nil, err
+    }
+
+    this.skipSpace()
+    if strings.HasPrefix(this.text[this.pos:], "SYSTEM") || strings.HasPrefix(this.text[this.pos:], "PUBLIC") {
+        if docType.PublicID, docType.SystemID, err = this.externalID(false); nil != err {
+            return nil, err
+        }
+        this.skipSpace()
+    }
+
+    if this.consume("[") {
+        if docType.Subset, err = this.parseDecls(true); nil != err {
+            return nil, err
+        }
+        this.skipSpace()
+    }
+
+    if !this.eof() {
+        return nil, this.fail("unexpected " + string(this.peek()) + " in DOCTYPE")
+    }
+    return docType, nil
+}
+
+//	parseDecls	解析一组标记声明，inSubset为true时解析的是内部子集，以"]"结束
+func (this *dtdParser) parseDecls(inSubset bool) (*DTD, error) {
+    dtd := new(DTD)
+    for {
+        this.skipSpace()
+        if this.eof() {
+            if inSubset {
+                return nil, this.fail("unterminated internal subset")
+            }
+            return dtd, nil
+        }
+
+        if inSubset && this.consume("]") {
+            return dtd, nil
+        }
+
+        decl, err := this.parseDecl()
+        if nil != err {
+            return nil, err
+        }
+        dtd.Decls = append(dtd.Decls, decl)
+    }
+}
+
+//	parseDecl	解析一个标记声明
+func (this *dtdParser) parseDecl() (DTDDecl, error) {
+    start := this.pos
+    switch {
+    case this.consume("<!ELEMENT"):
+        return this.parseElement()
+    case this.consume("<!ATTLIST"):
+        return this.parseAttlist()
+    case this.consume("<!ENTITY"):
+        return this.parseEntity()
+    case this.consume("<!NOTATION"):
+        return this.parseNotation()
+    case this.consume("<!--"):
+        return this.parseRaw(start, "-->")
+    case this.consume("<?"):
+        return this.parseRaw(start, "?>")
+    case this.consume("%"):
+        if _, err := this.name(); nil != err {
+            return nil, err
+        }
+        if err := this.expect(";"); nil != err {
+            return nil, err
+        }
+        return &RawDecl{Text: this.text[start:this.pos]}, nil
+    case this.consume("<!["):
+        this.pos = start
+        return nil, this.fail("conditional sections are not supported")
+    }
+    return nil, this.fail("unexpected " + string(this.peek()) + " in DTD")
+}
+
+//	parseRaw	原样保存直到end为止的内容
+func (this *dtdParser) parseRaw(start int, end string) (DTDDecl, error) {
+    i := strings.Index(this.text[this.pos:], end)
+    if i < 0 {
+        return nil, this.fail("expected " + end)
+    }
+
+    this.pos += i + len(end)
+    return &RawDecl{Text: this.text[start:this.pos]}, nil
+}
+
+//	endDecl	跳过声明结尾的">"
+func (this *dtdParser) endDecl() error {
+    this.skipSpace()
+    return this.expect(">")
+}
+
+func (this *dtdParser) parseElement() (DTDDecl, error) {
+    elem := new(ElementDecl)
+    var err error
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+    if elem.Name, err = this.name(); nil != err {
+        return nil, err
+    }
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+
+    switch {
+    case this.consume("EMPTY"):
+        elem.Kind = EmptyContent
+    case this.consume("ANY"):
+        elem.Kind = AnyContent
+    case this.consume("("):
+        this.skipSpace()
+        if this.consume("#PCDATA") {
+            elem.Kind = MixedContent
+            if elem.Mixed, err = this.parseMixed(); nil != err {
+                return nil, err
+            }
+            break
+        }
+
+        elem.Kind = ElementContent
+        if elem.Model, err = this.parseGroup(); nil != err {
+            return nil, err
+        }
+    default:
+        return nil, this.fail("expected EMPTY, ANY or content model")
+    }
+
+    if err = this.endDecl(); nil != err {
+        return nil, err
+    }
+    return elem, nil
+}
+
+//	parseMixed	解析#PCDATA之后的部分：(#PCDATA)、(#PCDATA)*或者(#PCDATA|a|b)*
+func (this *dtdParser) parseMixed() ([]string, error) {
+    var names []string
+    for {
+        this.skipSpace()
+        if this.consume(")") {
+            break
+        }
+        if err := this.expect("|"); nil != err {
+            return nil, err
+        }
+
+        this.skipSpace()
+        name, err := this.name()
+        if nil != err {
+            return nil, err
+        }
+        names = append(names, name)
+    }
+
+    if !this.consume("*") && (len(names) > 0) {
+        return nil, this.fail("mixed content with elements must end with )*")
+    }
+    return names, nil
+}
+
+//	parseGroup	解析"("之后的序列或者选择
+func (this *dtdParser) parseGroup() (*ContentParticle, error) {
+    group := new(ContentParticle)
+    var separator byte
+    for {
+        this.skipSpace()
+        child, err := this.parseParticle()
+        if nil != err {
+            return nil, err
+        }
+        group.Children = append(group.Children, child)
+
+        this.skipSpace()
+        if this.consume(")") {
+            break
+        }
+
+        next := this.peek()
+        if ((',' != next) && ('|' != next)) || ((0 != separator) && (separator != next)) {
+            return nil, this.fail("expected , or | or ) in content model")
+        }
+        separator = next
+        this.pos++
+    }
+
+    group.Choice = '|' == separator
+    group.Occurs = this.parseOccurs()
+    return group, nil
+}
+
+//	parseParticle	解析内容模型中的一项
+func (this *dtdParser) parseParticle() (*ContentParticle, error) {
+    if this.consume("(") {
+        return this.parseGroup()
+    }
+
+    name, err := this.name()
+    if nil != err {
+        return nil, err
+    }
+    return &ContentParticle{Name: name, Occurs: this.parseOccurs()}, nil
+}
+
+func (this *dtdParser) parseOccurs() byte {
+    switch occurs := this.peek(); occurs {
+    case '?', '*', '+':
+        this.pos++
+        return occurs
+    }
+    return 0
+}
+
+func (this *dtdParser) parseAttlist() (DTDDecl, error) {
+    attlist := new(AttlistDecl)
+    var err error
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+    if attlist.Element, err = this.name(); nil != err {
+        return nil, err
+    }
+
+    for {
+        hasSpace := this.skipSpace()
+        if this.consume(">") {
+            return attlist, nil
+        }
+        if !hasSpace {
+            return nil, this.fail("expected whitespace")
+        }
+
+        attribute, err := this.parseAttributeDecl()
+        if nil != err {
+            return nil, err
+        }
+        attlist.Attributes = append(attlist.Attributes, attribute)
+    }
+}
+
+func (this *dtdParser) parseAttributeDecl() (*AttributeDecl, error) {
+    attribute := new(AttributeDecl)
+    var err error
+    if attribute.Name, err = this.name(); nil != err {
+        return nil, err
+    }
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+
+    if this.consume("(") {
+        if attribute.Enum, err = this.parseEnum(false); nil != err {
+            return nil, err
+        }
+    } else {
+        attribute.Type = this.token(true)
+        switch attribute.Type {
+        case "CDATA", "ID", "IDREF", "IDREFS", "ENTITY", "ENTITIES", "NMTOKEN", "NMTOKENS":
+        case "NOTATION":
+            if err = this.requireSpace(); nil != err {
+                return nil, err
+            }
+            if err = this.expect("("); nil != err {
+                return nil, err
+            }
+            if attribute.Enum, err = this.parseEnum(true); nil != err {
+                return nil, err
+            }
+        default:
+            return nil, this.fail("unknown attribute type " + attribute.Type)
+        }
+    }
+
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+
+    switch {
+    case this.consume("#REQUIRED"):
+        attribute.Default = "#REQUIRED"
+        return attribute, nil
+    case this.consume("#IMPLIED"):
+        attribute.Default = "#IMPLIED"
+        return attribute, nil
+    case this.consume("#FIXED"):
+        attribute.Default = "#FIXED"
+        if err = this.requireSpace(); nil != err {
+            return nil, err
+        }
+    }
+
+    if attribute.Value, err = this.literal(); nil != err {
+        return nil, err
+    }
+    return attribute, nil
+}
+
+//	parseEnum	解析"("之后的(a|b)，names为true时每一项必须是名字，否则是NMTOKEN
+func (this *dtdParser) parseEnum(names bool) ([]string, error) {
+    var values []string
+    for {
+        this.skipSpace()
+        value := this.token(names)
+        if "" == value {
+            return nil, this.fail("expected name in enumeration")
+        }
+        values = append(values, value)
+
+        this.skipSpace()
+        if this.consume(")") {
+            return values, nil
+        }
+        if err := this.expect("|"); nil != err {
+            return nil, err
+        }
+    }
+}
+
+func (this *dtdParser) parseEntity() (DTDDecl, error) {
+    entity := new(EntityDecl)
+    var err error
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+    if this.consume("%") {
+        entity.Parameter = true
+        if err = this.requireSpace(); nil != err {
+            return nil, err
+        }
+    }
+    if entity.Name, err = this.name(); nil != err {
+        return nil, err
+    }
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+
+    if quote := this.peek(); ('"' == quote) || ('\'' == quote) {
+        if entity.Value, err = this.literal(); nil != err {
+            return nil, err
+        }
+    } else if entity.PublicID, entity.SystemID, err = this.externalID(false); nil != err {
+        return nil, err
+    } else if err = this.parseNData(entity); nil != err {
+        return nil, err
+    }
+
+    if err = this.endDecl(); nil != err {
+        return nil, err
+    }
+    return entity, nil
+}
+
+//	parseNData	解析外部一般实体之后可选的NDATA name
+func (this *dtdParser) parseNData(entity *EntityDecl) error {
+    save := this.pos
+    if !this.skipSpace() || entity.Parameter || !this.consume("NDATA") {
+        this.pos = save
+        return nil
+    }
+
+    if err := this.requireSpace(); nil != err {
+        return err
+    }
+
+    var err error
+    entity.NData, err = this.name()
+    return err
+}
+
+func (this *dtdParser) parseNotation() (DTDDecl, error) {
+    notation := new(NotationDecl)
+    var err error
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+    if notation.Name, err = this.name(); nil != err {
+        return nil, err
+    }
+    if err = this.requireSpace(); nil != err {
+        return nil, err
+    }
+    if notation.PublicID, notation.SystemID, err = this.externalID(true); nil != err {
+        return nil, err
+    }
+    if err = this.endDecl(); nil != err {
+        return nil, err
+    }
+    return notation, nil
+}
+
+func isNameStartChar(r rune) bool {
+    return unicode.IsLetter(r) || ('_' == r) || (':' == r)
+}
+
+func isNameChar(r rune) bool {
+    return isNameStartChar(r) || unicode.IsDigit(r) || ('-' == r) || ('.' == r) || (0xB7 == r) || unicode.Is(unicode.Mn, r)
+}
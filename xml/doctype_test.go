@@ -0,0 +1,149 @@
+package tinydom_test
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "tinydom/xml"
+)
+
+const doctypeNote = `<?xml version="1.0"?>
+<!DOCTYPE note PUBLIC "-//Example//DTD Note//EN" "note.dtd" [
+    <!ELEMENT note (to+,(from|sender)?,body*)>
+    <!ELEMENT to (#PCDATA)>
+    <!ELEMENT body (#PCDATA|b|i)*>
+    <!ELEMENT br EMPTY>
+    <!ELEMENT any ANY>
+    <!ATTLIST note id ID #REQUIRED
+                   lang (en|zh) "en"
+                   version CDATA #FIXED '1.0'>
+    <!ATTLIST note id CDATA #IMPLIED type NOTATION (gif) #IMPLIED>
+    <!ENTITY writer "Tom &amp; Ann">
+    <!ENTITY % common SYSTEM "common.ent">
+    <!ENTITY logo SYSTEM "logo.gif" NDATA gif>
+    <!NOTATION gif PUBLIC "image/gif">
+    %common;
+]>
+<note id="n1"><to>Ann</to></note>`
+
+func Test_DocType_解析(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(doctypeNote))
+    expect(t, "返回值检测", nil == err)
+
+    directive := doc.FirstChild().NextSibling().ToDirective()
+    docType, err := directive.DocType()
+    expect(t, "解析DOCTYPE", nil == err && "note" == docType.Name)
+    expect(t, "外部标识符", "-//Example//DTD Note//EN" == docType.PublicID && "note.dtd" == docType.SystemID)
+    expect(t, "声明的数量", nil != docType.Subset && 12 == len(docType.Subset.Decls))
+
+    subset := docType.Subset
+    note := subset.Element("note")
+    expect(t, "元素内容", tinydom.ElementContent == note.Kind && "(to+,(from|sender)?,body*)" == note.Model.String())
+    expect(t, "内容模型", 3 == len(note.Model.Children) && "to" == note.Model.Children[0].Name && '+' == note.Model.Children[0].Occurs && note.Model.Children[1].Choice)
+    expect(t, "只有文本", tinydom.MixedContent == subset.Element("to").Kind && 0 == len(subset.Element("to").Mixed))
+    expect(t, "混合内容", "b,i" == strings.Join(subset.Element("body").Mixed, ","))
+    expect(t, "EMPTY和ANY", tinydom.EmptyContent == subset.Element("br").Kind && tinydom.AnyContent == subset.Element("any").Kind)
+    expect(t, "没有声明的元素", nil == subset.Element("from"))
+
+    attributes := subset.Attributes("note")
+    expect(t, "合并ATTLIST", 4 == len(attributes) && "ID" == attributes[0].Type && "#REQUIRED" == attributes[0].Default)
+    expect(t, "枚举类型", "" == attributes[1].Type && "en,zh" == strings.Join(attributes[1].Enum, ",") && "" == attributes[1].Default && "en" == attributes[1].Value)
+    expect(t, "FIXED", "#FIXED" == attributes[2].Default && "1.0" == attributes[2].Value)
+    expect(t, "NOTATION类型", "NOTATION" == attributes[3].Type && "gif" == attributes[3].Enum[0])
+
+    expect(t, "内部实体", "Tom &amp; Ann" == subset.Entity("writer").Value)
+    expect(t, "参数实体不是一般实体", nil == subset.Entity("common"))
+    expect(t, "未解析实体", "logo.gif" == subset.Entity("logo").SystemID && "gif" == subset.Entity("logo").NData)
+    expect(t, "NOTATION", "image/gif" == subset.Notation("gif").PublicID && "" == subset.Notation("gif").SystemID)
+    expect(t, "参数实体引用", "%common;" == subset.Decls[11].String())
+
+    _, err = tinydom.NewDirective(doc, "ELEMENT a ANY").DocType()
+    expect(t, "不是DOCTYPE", tinydom.ErrNotDocType == err)
+
+    docType, err = tinydom.NewDirective(doc, "DOCTYPE html").DocType()
+    expect(t, "最简单的DOCTYPE", nil == err && "html" == docType.Name && nil == docType.Subset && "" == docType.SystemID)
+}
+
+func Test_DocType_原样输出(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(doctypeNote))
+    expect(t, "返回值检测", nil == err)
+    expect(t, "指令不会被转义", strings.Contains(doc.String(), `<!ELEMENT note (to+,(from|sender)?,body*)>`) && !strings.Contains(doc.String(), "&lt;"))
+
+    loaded, err := tinydom.LoadDocument(strings.NewReader(doc.String()))
+    expect(t, "重新加载", nil == err && doc.String() == loaded.String())
+
+    doc, err = tinydom.LoadDocument(strings.NewReader(`<!DOCTYPE a [<!ENTITY e "<x/>">]><a/>`))
+    expect(t, "实体中的标记", nil == err && `<!DOCTYPE a [<!ENTITY e "<x/>">]><a/>` == doc.String())
+}
+
+func Test_DocType_内部子集中的注释和处理指令(t *testing.T) {
+    xml := "<!DOCTYPE note [\n<!-- a comment -->\n<?tool data?>\n<!ELEMENT note (#PCDATA)>\n]><note>x</note>"
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+    expect(t, "原样输出", xml == doc.String())
+
+    docType, err := doc.FirstChild().ToDirective().DocType()
+    expect(t, "解析DOCTYPE", nil == err && 3 == len(docType.Subset.Decls))
+    expect(t, "注释", "<!-- a comment -->" == docType.Subset.Decls[0].String())
+    expect(t, "处理指令", "<?tool data?>" == docType.Subset.Decls[1].String())
+
+    loaded, err := tinydom.LoadDocument(strings.NewReader(doc.String()))
+    expect(t, "重新加载", nil == err && xml == loaded.String())
+}
+
+func Test_DocType_修改(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(doctypeNote))
+    expect(t, "返回值检测", nil == err)
+
+    directive := doc.FirstChild().NextSibling().ToDirective()
+    docType, _ := directive.DocType()
+    docType.PublicID = ""
+    docType.Subset.Decls = docType.Subset.Decls[:2]
+    docType.Subset.Decls = append(docType.Subset.Decls, &tinydom.EntityDecl{Name: "q", Value: `say "hi"`})
+    directive.SetDocType(docType)
+
+    expected := "DOCTYPE note SYSTEM \"note.dtd\" [\n<!ELEMENT note (to+,(from|sender)?,body*)>\n<!ELEMENT to (#PCDATA)>\n<!ENTITY q 'say \"hi\"'>\n]"
+    expect(t, "重新生成指令", expected == directive.Value())
+
+    created := tinydom.NewDocument()
+    created.InsertEndChild(tinydom.NewDocType(created, &tinydom.DocType{Name: "root", Subset: &tinydom.DTD{Decls: []tinydom.DTDDecl{
+        &tinydom.ElementDecl{Name: "root", Kind: tinydom.ElementContent, Model: &tinydom.ContentParticle{Name: "item", Occurs: '*'}},
+        &tinydom.AttlistDecl{Element: "item", Attributes: []*tinydom.AttributeDecl{{Name: "kind", Enum: []string{"a", "b"}, Default: "#REQUIRED"}}},
+        &tinydom.NotationDecl{Name: "png", SystemID: "image/png"},
+    }}}))
+    created.SetRootElement(tinydom.NewElement(created, "root"))
+    expect(t, "新建DOCTYPE", "<!DOCTYPE root [\n<!ELEMENT root (item)*>\n<!ATTLIST item kind (a|b) #REQUIRED>\n<!NOTATION png SYSTEM \"image/png\">\n]><root/>" == created.String())
+
+    loaded, err := tinydom.LoadDocument(strings.NewReader(created.String()))
+    expect(t, "重新加载", nil == err)
+    docType, err = loaded.FirstChild().ToDirective().DocType()
+    expect(t, "重新解析", nil == err && "(item)*" == docType.Subset.Element("root").Model.String() && "png" == docType.Subset.Notation("png").Name)
+}
+
+func Test_DocType_语法错误(t *testing.T) {
+    doc := tinydom.NewDocument()
+    for _, text := range []string{
+        "DOCTYPE",
+        "DOCTYPE a SYSTEM",
+        "DOCTYPE a PUBLIC \"p\"",
+        "DOCTYPE a [",
+        "DOCTYPE a [<!ELEMENT a>]",
+        "DOCTYPE a [<!ELEMENT a (b,c|d)>]",
+        "DOCTYPE a [<!ELEMENT a (#PCDATA|b)>]",
+        "DOCTYPE a [<!ATTLIST a x STRING #IMPLIED>]",
+        "DOCTYPE a [<!ATTLIST a x CDATA>]",
+        "DOCTYPE a [<!ENTITY e 'x>]",
+        "DOCTYPE a [<![INCLUDE[<!ELEMENT a ANY>]]>]",
+        "DOCTYPE a [<!ELEMENT a ANY>] x",
+    } {
+        _, err := tinydom.NewDirective(doc, text).DocType()
+        _, ok := err.(*tinydom.ParseError)
+        expect(t, text+"是错误的语法", ok && errors.Is(err, tinydom.ErrInvalidDTD))
+    }
+
+    loaded, err := tinydom.LoadDocument(strings.NewReader("<?xml version=\"1.0\"?>\n  <!DOCTYPE a [\n<!ELEMENT a>]>\n<a/>"))
+    expect(t, "返回值检测", nil == err)
+    _, err = loaded.FirstChild().NextSibling().ToDirective().DocType()
+    parseErr, ok := err.(*tinydom.ParseError)
+    expect(t, "错误的位置", ok && 3 == parseErr.Line && 12 == parseErr.Column)
+}
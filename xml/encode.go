@@ -0,0 +1,411 @@
+package tinydom
+
+import (
+    "encoding"
+    "encoding/xml"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+    "unicode"
+)
+
+//	Encode	根据v构建一棵属于doc的XML子树并返回它的根元素
+//
+//	映射规则与encoding/xml.Marshal相同，参见Decode中tag的说明。元素的名字依次取自XMLName字段的tag、XMLName字段的值、
+//	字段的tag或者字段名，最后是类型名；名字空间不同于父元素的默认名字空间时会添加xmlns声明，属性的名字空间会自动声明一个前缀。
+//	nil指针和nil接口不会输出，omitempty的字段为零值时不会输出；字段是XMLElement时输出它的深拷贝。
+//
+//	返回的元素还没有插入到doc中，可以通过InsertEndChild等方法插入到任意位置，之后也可以继续使用DOM接口修改。
+func Encode(doc XMLDocument, v interface{}) (XMLElement, error) {
+    if nil == doc {
+        return nil, &BindError{Msg: "nil document"}
+    }
+
+    val := reflect.ValueOf(v)
+    for val.IsValid() && !val.Type().Implements(xmlElementType) && (reflect.Ptr == val.Kind() || reflect.Interface == val.Kind()) {
+        if val.IsNil() {
+            break
+        }
+        val = val.Elem()
+    }
+    if !val.IsValid() || ((reflect.Ptr == val.Kind() || reflect.Interface == val.Kind()) && val.IsNil()) {
+        return nil, &BindError{Msg: "nil value"}
+    }
+    if (reflect.Slice == val.Kind() || reflect.Array == val.Kind()) && reflect.Uint8 != val.Type().Elem().Kind() {
+        return nil, &BindError{Msg: fmt.Sprintf("cannot encode %s as a single element", val.Type())}
+    }
+
+    encoder := &xmlEncoder{doc: doc}
+    return encoder.encodeElement(nil, nil, val, "")
+}
+
+//  xmlEncoder  记录构建子树时使用的文档
+type xmlEncoder struct {
+    doc XMLDocument
+}
+
+//	encodeValue	把字段的值编码为parent的子元素，切片的每一项都是一个子元素
+func (this *xmlEncoder) encodeValue(parent XMLElement, finfo *fieldInfo, val reflect.Value, path string) error {
+    for !val.Type().Implements(xmlElementType) && (reflect.Ptr == val.Kind() || reflect.Interface == val.Kind()) {
+        if val.IsNil() {
+            return nil
+        }
+        val = val.Elem()
+    }
+
+    if val.Type().Implements(xmlElementType) {
+        if !val.IsNil() {
+            parent.InsertEndChild(val.Interface().(XMLElement).DeepClone(this.doc))
+        }
+        return nil
+    }
+
+    if (reflect.Slice == val.Kind() || reflect.Array == val.Kind()) && reflect.Uint8 != val.Type().Elem().Kind() && !implementsMarshaler(val) {
+        for i := 0; i < val.Len(); i++ {
+            if err := this.encodeValue(parent, finfo, val.Index(i), path); nil != err {
+                return err
+            }
+        }
+        return nil
+    }
+
+    _, err := this.encodeElement(parent, finfo, val, path)
+    return err
+}
+
+//	encodeElement	创建val对应的元素，parent不为nil时插入到parent的末尾
+func (this *xmlEncoder) encodeElement(parent XMLElement, finfo *fieldInfo, val reflect.Value, path string) (XMLElement, error) {
+    if val.Type().Implements(xmlElementType) {
+        return val.Interface().(XMLElement).DeepClone(this.doc).ToElement(), nil
+    }
+
+    var tinfo *typeInfo
+    if reflect.Struct == val.Kind() && !implementsMarshaler(val) {
+        var err error
+        if tinfo, err = getTypeInfo(val.Type()); nil != err {
+            return nil, err
+        }
+    }
+
+    //  确定元素的名字和名字空间
+    var name, xmlns string
+    if nil != tinfo && nil != tinfo.xmlname {
+        if "" != tinfo.xmlname.name {
+            name, xmlns = tinfo.xmlname.name, tinfo.xmlname.xmlns
+        } else if field := fieldValue(val, tinfo.xmlname.idx, false); field.IsValid() && nameType == field.Type() {
+            if value := field.Interface().(xml.Name); "" != value.Local {
+                name, xmlns = value.Local, value.Space
+            }
+        }
+    }
+    if "" == name && nil != finfo {
+        name, xmlns = finfo.name, finfo.xmlns
+    }
+    if "" == name {
+        name = val.Type().Name()
+    }
+    if "" == name {
+        return nil, &BindError{Path: path, Msg: "cannot determine element name for " + val.Type().String()}
+    }
+
+    if "" != path {
+        path += ">"
+    }
+    path += name
+
+    elem := NewElement(this.doc, name)
+    inherited := ""
+    if nil != parent {
+        parent.InsertEndChild(elem)
+        inherited = parent.LookupNamespaceURI("")
+    }
+    if "" != xmlns && xmlns != inherited && !strings.Contains(name, ":") {
+        elem.SetAttribute("xmlns", xmlns)
+    }
+
+    if nil != tinfo {
+        return elem, this.encodeStruct(elem, tinfo, val, path)
+    }
+
+    text, err := encodeText(val, path)
+    if nil != err {
+        return nil, err
+    }
+    if "" != text {
+        elem.InsertEndChild(NewText(this.doc, text))
+    }
+    return elem, nil
+}
+
+//	encodeStruct	按照结构体的映射信息输出属性、文本和子元素
+func (this *xmlEncoder) encodeStruct(elem XMLElement, tinfo *typeInfo, val reflect.Value, path string) error {
+    for i := range tinfo.fields {
+        finfo := &tinfo.fields[i]
+        if 0 == finfo.flags&fAttr {
+            continue
+        }
+        field := fieldValue(val, finfo.idx, false)
+        if !field.IsValid() {
+            continue
+        }
+        if err := this.encodeAttr(elem, finfo, field, path); nil != err {
+            return err
+        }
+    }
+
+    //  stack是a>b形式的路径中已经创建的元素，相邻的字段共用相同的前缀
+    var stack []XMLElement
+    for i := range tinfo.fields {
+        finfo := &tinfo.fields[i]
+        field := fieldValue(val, finfo.idx, false)
+        if !field.IsValid() {
+            continue
+        }
+
+        switch finfo.flags & fMode {
+        case fCharData, fCDATA:
+            stack = nil
+            text, err := encodeText(field, path)
+            if nil != err {
+                return err
+            }
+            if "" != text {
+                node := NewText(this.doc, text)
+                node.SetCDATA(fCDATA == finfo.flags&fMode)
+                elem.InsertEndChild(node)
+            }
+
+        case fComment:
+            stack = nil
+            text, err := encodeText(field, path)
+            if nil != err {
+                return err
+            }
+            if strings.Contains(text, "--") {
+                return &BindError{Path: path, Msg: `comment must not contain "--"`}
+            }
+            if "" != text {
+                elem.InsertEndChild(NewComment(this.doc, text))
+            }
+
+        case fInnerXML:
+            stack = nil
+            text, err := encodeText(field, path)
+            if nil != err {
+                return err
+            }
+            if err := this.insertInnerXML(elem, text, path); nil != err {
+                return err
+            }
+
+        case fElement, fElement | fAny:
+            if 0 != finfo.flags&fOmitEmpty && isEmptyValue(field) {
+                continue
+            }
+            if (reflect.Ptr == field.Kind() || reflect.Interface == field.Kind()) && field.IsNil() {
+                continue
+            }
+
+            //  保留与上一个字段相同的路径前缀，创建剩余的路径
+            n := 0
+            for n < len(stack) && n < len(finfo.parents) && stack[n].Name() == finfo.parents[n] {
+                n++
+            }
+            stack = stack[:n]
+            for _, name := range finfo.parents[n:] {
+                parent := elem
+                if len(stack) > 0 {
+                    parent = stack[len(stack)-1]
+                }
+                stack = append(stack, parent.InsertEndChild(NewElement(this.doc, name)).ToElement())
+            }
+
+            parent := elem
+            if len(stack) > 0 {
+                parent = stack[len(stack)-1]
+            }
+            if err := this.encodeValue(parent, finfo, field, path); nil != err {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+//	encodeAttr	输出attr字段，`,any,attr`字段可以是xml.Attr或者[]xml.Attr
+func (this *xmlEncoder) encodeAttr(elem XMLElement, finfo *fieldInfo, val reflect.Value, path string) error {
+    if 0 != finfo.flags&fOmitEmpty && isEmptyValue(val) {
+        return nil
+    }
+    for reflect.Ptr == val.Kind() || reflect.Interface == val.Kind() {
+        if val.IsNil() {
+            return nil
+        }
+        val = val.Elem()
+    }
+
+    if attrType == val.Type() {
+        attr := val.Interface().(xml.Attr)
+        if "" != attr.Name.Local {
+            setAttributeNS(elem, attr.Name.Space, attr.Name.Local, attr.Value)
+        }
+        return nil
+    }
+    if reflect.Slice == val.Kind() && attrType == val.Type().Elem() {
+        for i := 0; i < val.Len(); i++ {
+            if err := this.encodeAttr(elem, finfo, val.Index(i), path); nil != err {
+                return err
+            }
+        }
+        return nil
+    }
+
+    text, err := encodeText(val, path+"@"+finfo.name)
+    if nil != err {
+        return err
+    }
+    setAttributeNS(elem, finfo.xmlns, finfo.name, text)
+    return nil
+}
+
+//	insertInnerXML	解析innerxml字段的内容并插入到elem的末尾
+func (this *xmlEncoder) insertInnerXML(elem XMLElement, text string, path string) error {
+    if "" == text {
+        return nil
+    }
+
+    fragment, err := LoadDocument(strings.NewReader("<innerxml>" + text + "</innerxml>"))
+    if nil != err {
+        return &BindError{Path: path, Msg: "invalid innerxml: " + err.Error()}
+    }
+    for child := fragment.FirstChildElement("").FirstChild(); nil != child; child = child.NextSibling() {
+        elem.InsertEndChild(this.doc.ImportNode(child, true))
+    }
+    return nil
+}
+
+//	setAttributeNS	设置属于名字空间xmlns的属性，必要时在elem上声明一个新的前缀
+func setAttributeNS(elem XMLElement, xmlns string, local string, value string) {
+    if "" == xmlns || strings.Contains(local, ":") {
+        elem.SetAttribute(local, value)
+        return
+    }
+
+    var prefix string
+    switch xmlns {
+    case XMLNamespace:
+        prefix = "xml"
+    case XMLNSNamespace, "xmlns":
+        prefix = "xmlns"
+    default:
+        //  与encoding/xml一样使用名字空间的最后一段作为前缀，已经被占用时加上序号
+        base := strings.TrimRight(xmlns, "/")
+        if i := strings.LastIndexAny(base, "/:"); i >= 0 {
+            base = base[i+1:]
+        }
+        if !isPrefixName(base) {
+            base = "ns"
+        }
+
+        prefix = base
+        for i := 1; ; i++ {
+            uri := elem.LookupNamespaceURI(prefix)
+            if xmlns == uri {
+                break
+            }
+            if "" == uri {
+                elem.SetAttribute("xmlns:"+prefix, xmlns)
+                break
+            }
+            prefix = base + strconv.Itoa(i)
+        }
+    }
+    elem.SetAttribute(prefix+":"+local, value)
+}
+
+//	isPrefixName	判断名字能否作为名字空间前缀使用
+func isPrefixName(name string) bool {
+    if "" == name || strings.HasPrefix(strings.ToLower(name), "xml") {
+        return false
+    }
+    for i, r := range name {
+        if unicode.IsLetter(r) || '_' == r {
+            continue
+        }
+        if i > 0 && (unicode.IsDigit(r) || '-' == r || '.' == r) {
+            continue
+        }
+        return false
+    }
+    return true
+}
+
+//	implementsMarshaler	判断val或者它的指针是否实现了encoding.TextMarshaler
+func implementsMarshaler(val reflect.Value) bool {
+    return val.Type().Implements(marshalerType) || (val.CanAddr() && val.Addr().Type().Implements(marshalerType))
+}
+
+//	encodeText	把val格式化为文本，格式与Decode的解析规则对应
+func encodeText(val reflect.Value, path string) (string, error) {
+    for reflect.Ptr == val.Kind() || reflect.Interface == val.Kind() {
+        if val.IsNil() {
+            return "", nil
+        }
+        val = val.Elem()
+    }
+
+    if implementsMarshaler(val) {
+        if !val.Type().Implements(marshalerType) {
+            val = val.Addr()
+        }
+        text, err := val.Interface().(encoding.TextMarshaler).MarshalText()
+        if nil != err {
+            return "", &BindError{Path: path, Msg: err.Error()}
+        }
+        return string(text), nil
+    }
+
+    switch val.Kind() {
+    case reflect.String:
+        return val.String(), nil
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        if durationType == val.Type() {
+            return time.Duration(val.Int()).String(), nil
+        }
+        return strconv.FormatInt(val.Int(), 10), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return strconv.FormatUint(val.Uint(), 10), nil
+    case reflect.Float32, reflect.Float64:
+        return strconv.FormatFloat(val.Float(), 'g', -1, val.Type().Bits()), nil
+    case reflect.Bool:
+        return strconv.FormatBool(val.Bool()), nil
+    case reflect.Slice, reflect.Array:
+        if reflect.Uint8 == val.Type().Elem().Kind() {
+            bytes := make([]byte, val.Len())
+            reflect.Copy(reflect.ValueOf(bytes), val)
+            return string(bytes), nil
+        }
+    }
+    return "", &BindError{Path: path, Msg: "unsupported type " + val.Type().String()}
+}
+
+//	isEmptyValue	判断值是否为omitempty意义下的空值
+func isEmptyValue(val reflect.Value) bool {
+    switch val.Kind() {
+    case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+        return 0 == val.Len()
+    case reflect.Bool:
+        return !val.Bool()
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return 0 == val.Int()
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return 0 == val.Uint()
+    case reflect.Float32, reflect.Float64:
+        return 0 == val.Float()
+    case reflect.Interface, reflect.Ptr:
+        return val.IsNil()
+    }
+    return false
+}
@@ -0,0 +1,167 @@
+package tinydom_test
+
+import (
+    "encoding/xml"
+    "strings"
+    "testing"
+    "time"
+    "tinydom/xml"
+)
+
+type encodeAuthor struct {
+    XMLName xml.Name `xml:"author"`
+    Name    string   `xml:",chardata"`
+    Email   string   `xml:"email,attr,omitempty"`
+}
+
+type encodeBook struct {
+    XMLName xml.Name       `xml:"book"`
+    ID      string         `xml:"id,attr"`
+    Year    int            `xml:"year,attr,omitempty"`
+    Note    string         `xml:",comment"`
+    Title   string         `xml:"title"`
+    Price   *float64       `xml:"price"`
+    Authors []encodeAuthor `xml:"authors>author"`
+    Tags    []string       `xml:"meta>tags>tag"`
+    Lang    string         `xml:"meta>lang"`
+    Loan    time.Duration  `xml:"loan,omitempty"`
+    Hidden  string         `xml:"-"`
+}
+
+func Test_Encode_构建子树(t *testing.T) {
+    doc := tinydom.NewDocument()
+    price := 12.5
+    book := encodeBook{
+        ID:      "b1",
+        Note:    "classic",
+        Title:   "Moon & <Stars>",
+        Price:   &price,
+        Authors: []encodeAuthor{{Name: "Tom", Email: "tom@example.com"}, {Name: "Ann"}},
+        Tags:    []string{"space", "night"},
+        Lang:    "en",
+        Loan:    72 * time.Hour,
+        Hidden:  "x",
+    }
+
+    elem, err := tinydom.Encode(doc, &book)
+    expect(t, "返回值检测", nil == err && nil != elem)
+    expect(t, "还没有插入到文档中", nil == elem.Parent() && doc == elem.GetDocument())
+    expect(t, "输出", `<book id="b1"><!--classic--><title>Moon &amp; &lt;Stars&gt;</title><price>12.5</price>`+
+        `<authors><author email="tom@example.com">Tom</author><author>Ann</author></authors>`+
+        `<meta><tags><tag>space</tag><tag>night</tag></tags><lang>en</lang></meta><loan>72h0m0s</loan></book>` == elem.String())
+
+    doc.InsertEndChild(tinydom.NewElement(doc, "library")).ToElement().InsertEndChild(elem)
+    elem.SetAttribute("year", "2001")
+    expect(t, "插入后继续修改", `<library><book id="b1" year="2001">` == doc.String()[:len(`<library><book id="b1" year="2001">`)])
+
+    var decoded encodeBook
+    expect(t, "Decode还原", nil == tinydom.Decode(elem, &decoded))
+    expect(t, "Decode还原的值", "Moon & <Stars>" == decoded.Title && 2001 == decoded.Year && 12.5 == *decoded.Price && 2 == len(decoded.Authors) &&
+        "tom@example.com" == decoded.Authors[0].Email && "night" == decoded.Tags[1] && "en" == decoded.Lang && 72*time.Hour == decoded.Loan && "classic" == decoded.Note)
+}
+
+func Test_Encode_元素名和名字空间(t *testing.T) {
+    doc := tinydom.NewDocument()
+
+    type Item struct {
+        Value int `xml:"value,attr"`
+    }
+    elem, err := tinydom.Encode(doc, Item{Value: 1})
+    expect(t, "类型名作为元素名", nil == err && `<Item value="1"/>` == elem.String())
+
+    var named struct {
+        XMLName xml.Name
+        Text    string `xml:",cdata"`
+    }
+    named.XMLName = xml.Name{Space: "urn:x", Local: "named"}
+    named.Text = "a<b"
+    elem, err = tinydom.Encode(doc, &named)
+    expect(t, "XMLName的值和CDATA", nil == err && `<named xmlns="urn:x"><![CDATA[a<b]]></named>` == elem.String())
+    expect(t, "名字空间", "urn:x" == elem.NamespaceURI())
+
+    type Feed struct {
+        XMLName xml.Name `xml:"urn:feed feed"`
+        Title   string   `xml:"urn:feed title"`
+        Thumb   string   `xml:"urn:media thumb"`
+        Lang    string   `xml:"http://example.com/media lang,attr"`
+        Base    string   `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+    }
+    elem, err = tinydom.Encode(doc, Feed{Title: "T", Thumb: "a", Lang: "en", Base: "/"})
+    expect(t, "返回值检测", nil == err)
+    expect(t, "名字空间的声明", `<feed xmlns="urn:feed" xmlns:media="http://example.com/media" media:lang="en" xml:base="/"><title>T</title><thumb xmlns="urn:media">a</thumb></feed>` == elem.String())
+    expect(t, "名字空间的解析", "urn:media" == elem.FirstChildElement("thumb").NamespaceURI() && "http://example.com/media" == elem.FindAttribute("media:lang").NamespaceURI())
+
+    elem, err = tinydom.Encode(doc, "text")
+    expect(t, "非结构体的值", nil == err && `<string>text</string>` == elem.String())
+}
+
+func Test_Encode_特殊字段(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<extra><x a="1"/></extra>`))
+    expect(t, "返回值检测", nil == err)
+
+    type Embedded struct {
+        Kind string `xml:"kind,attr"`
+    }
+    var item struct {
+        XMLName xml.Name `xml:"item"`
+        Embedded
+        Attrs   []xml.Attr           `xml:",any,attr"`
+        Inner   string               `xml:",innerxml"`
+        Node    tinydom.XMLElement   `xml:"ignored"`
+        Nodes   []tinydom.XMLElement `xml:",any"`
+        Nil     *int                 `xml:"nil"`
+        Empty   string               `xml:"empty,omitempty"`
+        Blank   string               `xml:"blank"`
+        When    time.Time            `xml:"when"`
+    }
+    item.Kind = "k"
+    item.Attrs = []xml.Attr{{Name: xml.Name{Local: "extra"}, Value: "e"}}
+    item.Inner = `<raw>r</raw>text`
+    item.Node = doc.FirstChildElement("extra")
+    item.Nodes = []tinydom.XMLElement{doc.FirstChildElement("extra").FirstChildElement("x")}
+    item.When = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+    elem, err := tinydom.Encode(doc, &item)
+    expect(t, "返回值检测", nil == err)
+    expect(t, "输出", `<item kind="k" extra="e"><raw>r</raw>text<extra><x a="1"/></extra><x a="1"/><blank/><when>2020-01-02T03:04:05Z</when></item>` == elem.String())
+    expect(t, "XMLElement字段输出的是拷贝", doc.FirstChildElement("extra") != elem.FirstChildElement("extra") && nil != doc.FirstChildElement("extra").FirstChildElement("x"))
+}
+
+func Test_Encode_错误(t *testing.T) {
+    doc := tinydom.NewDocument()
+
+    _, err := tinydom.Encode(nil, "x")
+    expect(t, "nil文档", nil != err)
+    _, err = tinydom.Encode(doc, nil)
+    expect(t, "nil值", nil != err)
+    _, err = tinydom.Encode(doc, (*encodeBook)(nil))
+    expect(t, "nil指针", nil != err)
+    _, err = tinydom.Encode(doc, []int{1, 2})
+    expect(t, "切片", nil != err)
+
+    type Bad struct {
+        Values map[string]string `xml:"values"`
+    }
+    _, err = tinydom.Encode(doc, Bad{Values: map[string]string{}})
+    bindErr, ok := err.(*tinydom.BindError)
+    expect(t, "不支持的类型", ok && "Bad>values" == bindErr.Path)
+
+    type BadComment struct {
+        Comment string `xml:",comment"`
+    }
+    _, err = tinydom.Encode(doc, BadComment{Comment: "a--b"})
+    expect(t, "注释中的--", nil != err)
+
+    type BadInner struct {
+        Inner string `xml:",innerxml"`
+    }
+    _, err = tinydom.Encode(doc, BadInner{Inner: "<a>"})
+    expect(t, "错误的innerxml", nil != err)
+
+    type Conflict struct {
+        Author encodeAuthor `xml:"writer"`
+    }
+    _, err = tinydom.Encode(doc, Conflict{})
+    _, ok = err.(*tinydom.BindError)
+    expect(t, "与XMLName冲突的名字", ok)
+}
@@ -0,0 +1,559 @@
+package tinydom
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/xml"
+    "io"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+//  XMLPrinter  是用于输出XML文本的XMLVisitor
+//
+//  输出过程中一旦写入失败，遍历就会停止，Error返回遇到的第一个写入错误。
+type XMLPrinter interface {
+    XMLVisitor
+    Error() error
+}
+
+//  SaveOption  用于定制XMLDocument.SaveTo和SaveFile的输出方式
+type SaveOption func(options *saveOptions)
+
+type saveOptions struct {
+    pretty        bool
+    prettyOptions *PrettyPrinterOptions
+    encoding      string
+    bom           bool
+    declaration   int
+}
+
+const (
+    //  保留文档原有的XML声明，指定了字符集时总是输出
+    declarationAuto = iota
+    declarationAlways
+    declarationNever
+)
+
+//	WithPrettyPrint	使用NewPrettyPrinter输出带缩进格式的文档，options为nil时使用默认格式
+func WithPrettyPrint(options *PrettyPrinterOptions) SaveOption {
+    return func(saveOptions *saveOptions) {
+        saveOptions.pretty = true
+        saveOptions.prettyOptions = options
+    }
+}
+
+//	WithEncoding	使用指定的字符集输出文档，字符集需要通过RegisterCharsetEncoder注册，默认为UTF-8.
+//	无法用该字符集表示的字符在文本和属性值中输出为字符引用，在CDATA中会被移到CDATA段之外，
+//	出现在其他地方(比如元素名和注释)时返回ErrUnrepresentableChar。同时总是输出encoding与charset一致的XML声明
+func WithEncoding(charset string) SaveOption {
+    return func(saveOptions *saveOptions) {
+        saveOptions.encoding = charset
+    }
+}
+
+//	WithBOM	在文档开头输出字符集对应的BOM，只对UTF-8、UTF-16BE和UTF-16LE有效，其他字符集会忽略这个选项.
+//	字符集为UTF-16时不管是否指定这个选项，总是输出大端字节序的BOM
+func WithBOM() SaveOption {
+    return func(saveOptions *saveOptions) {
+        saveOptions.bom = true
+    }
+}
+
+//	WithDeclaration	write为true时，文档没有XML声明也会输出一个；为false时不输出XML声明，包括文档中原有的
+func WithDeclaration(write bool) SaveOption {
+    return func(saveOptions *saveOptions) {
+        if write {
+            saveOptions.declaration = declarationAlways
+        } else {
+            saveOptions.declaration = declarationNever
+        }
+    }
+}
+
+//	newPrinter	根据SaveOption创建对应的XMLPrinter，canEncode不为nil时用于判断字符能否直接输出
+func newPrinter(writer io.Writer, config *saveOptions, canEncode func(r rune) bool) XMLPrinter {
+    if config.pretty {
+        printer := NewPrettyPrinter(writer, config.prettyOptions).(*xmlPrettyPrinter)
+        printer.writer.canEncode = canEncode
+        return printer
+    }
+
+    printer := NewSimplePrinter(writer).(*xmlSimplePrinter)
+    printer.writer.canEncode = canEncode
+    return printer
+}
+
+//	saveTo	使用options指定的方式将node输出到writer，返回第一个写入错误
+func saveTo(node XMLNode, writer io.Writer, options []SaveOption) error {
+    config := new(saveOptions)
+    for _, option := range options {
+        option(config)
+    }
+
+    encoder := utf8Encoder
+    if "" != config.encoding {
+        if encoder = lookupCharsetEncoder(config.encoding); nil == encoder {
+            return ErrUnsupportedCharset
+        }
+    }
+
+    buffered := bufio.NewWriter(writer)
+    if config.bom || encoder.requireBOM {
+        buffered.Write(encoder.bom)
+    }
+
+    output := encoder.newWriter(buffered)
+    printer := newPrinter(output, config, encoder.canEncode)
+    if nil != node.ToDocument() {
+        node.Accept(&xmlDeclarationPrinter{XMLPrinter: printer, config: config})
+    } else {
+        node.Accept(printer)
+    }
+    if err := printer.Error(); nil != err {
+        return err
+    }
+
+    if err := output.Close(); nil != err {
+        return err
+    }
+    return buffered.Flush()
+}
+
+//  xmlDeclarationPrinter   输出文档时补充或者改写XML声明，使其中的encoding与实际输出的字符集一致
+type xmlDeclarationPrinter struct {
+    XMLPrinter
+    config      *saveOptions
+    declaration XMLDeclaration
+}
+
+func (this *xmlDeclarationPrinter) VisitEnterDocument(node XMLDocument) bool {
+    if !this.XMLPrinter.VisitEnterDocument(node) {
+        return false
+    }
+
+    this.declaration = node.Declaration()
+    if nil != this.declaration {
+        return true
+    }
+
+    if (declarationAlways == this.config.declaration) || ((declarationAuto == this.config.declaration) && ("" != this.config.encoding)) {
+        return this.XMLPrinter.VisitProcInst(NewProcInst(node, "xml", this.rewrite("")))
+    }
+    return true
+}
+
+func (this *xmlDeclarationPrinter) VisitProcInst(node XMLProcInst) bool {
+    if (nil == this.declaration) || (this.declaration != node) {
+        return this.XMLPrinter.VisitProcInst(node)
+    }
+
+    if declarationNever == this.config.declaration {
+        return true
+    }
+
+    if inst := this.rewrite(node.Instruction()); inst != node.Instruction() {
+        return this.XMLPrinter.VisitProcInst(NewProcInst(node.GetDocument(), "xml", inst))
+    }
+    return this.XMLPrinter.VisitProcInst(node)
+}
+
+//	rewrite	返回encoding与输出字符集一致的XML声明，原有的声明已经一致时原样返回
+func (this *xmlDeclarationPrinter) rewrite(inst string) string {
+    version, encoding, standalone := parseDeclaration(inst)
+
+    charset := this.config.encoding
+    if "" == charset {
+        //  没有指定字符集时输出的是UTF-8，没有声明encoding也是正确的
+        if ("" == encoding) && ("" != inst) {
+            return inst
+        }
+        charset = "UTF-8"
+    }
+
+    if ("" != inst) && ("" != encoding) && (lookupCharsetEncoder(encoding) == lookupCharsetEncoder(charset)) {
+        return inst
+    }
+
+    if "" == version {
+        version = "1.0"
+    }
+    return formatDeclaration(version, charset, standalone)
+}
+
+//------------------------------------------------------------------
+
+//  xmlWriter   包装了输出流，记录下第一次写入失败的错误，此后的写入都会被忽略
+type xmlWriter struct {
+    writer io.Writer
+    err    error
+
+    //  不为nil时，无法用输出字符集表示的字符在文本和属性值中输出为字符引用
+    canEncode func(r rune) bool
+}
+
+func newXMLWriter(writer io.Writer) *xmlWriter {
+    return &xmlWriter{writer: writer}
+}
+
+func (this *xmlWriter) Write(data []byte) (int, error) {
+    if nil != this.err {
+        return 0, this.err
+    }
+
+    n, err := this.writer.Write(data)
+    if nil != err {
+        this.err = err
+    }
+    return n, err
+}
+
+func (this *xmlWriter) WriteString(str string) {
+    if nil != this.err {
+        return
+    }
+
+    _, this.err = io.WriteString(this.writer, str)
+}
+
+//	Escape	转义并输出属性值，除了标记字符之外，引号和空白字符也会被转义，以免被解析器规范化
+func (this *xmlWriter) Escape(str string) {
+    //  出错的情况已经记录在this.err中
+    last := 0
+    for i := 0; (nil != this.canEncode) && (i < len(str)); {
+        r, width := utf8.DecodeRuneInString(str[i:])
+        if ref := this.charRef(r); "" != ref {
+            xml.EscapeText(this, []byte(str[last:i]))
+            this.WriteString(ref)
+            last = i + width
+        }
+        i += width
+    }
+
+    xml.EscapeText(this, []byte(str[last:]))
+}
+
+//	EscapeText	转义并输出元素中的文本，换行和制表符原样输出，以便保留文本原有的格式
+func (this *xmlWriter) EscapeText(str string) {
+    last := 0
+    for i := 0; i < len(str); {
+        r, width := utf8.DecodeRuneInString(str[i:])
+
+        esc := ""
+        switch {
+        case '&' == r:
+            esc = "&amp;"
+        case '<' == r:
+            esc = "&lt;"
+        case '>' == r:
+            esc = "&gt;"
+        case '\r' == r:
+            //  不转义的话，解析器会把\r\n规范化为\n
+            esc = "&#xD;"
+        case ('\n' == r) || ('\t' == r):
+        case (r < 0x20) || (0xFFFE == r) || (0xFFFF == r) || ((utf8.RuneError == r) && (1 == width)):
+            //  XML中不允许出现的字符，与encoding/xml一样替换为U+FFFD
+            esc = "\uFFFD"
+            if ref := this.charRef(0xFFFD); "" != ref {
+                esc = ref
+            }
+        default:
+            esc = this.charRef(r)
+        }
+
+        if "" != esc {
+            this.WriteString(str[last:i])
+            this.WriteString(esc)
+            last = i + width
+        }
+        i += width
+    }
+
+    this.WriteString(str[last:])
+}
+
+//	WriteCDATA	输出CDATA段的内容，无法用输出字符集表示的字符被移到CDATA段之外，输出为字符引用
+func (this *xmlWriter) WriteCDATA(str string) {
+    last := 0
+    for i := 0; (nil != this.canEncode) && (i < len(str)); {
+        r, width := utf8.DecodeRuneInString(str[i:])
+        if ref := this.charRef(r); "" != ref {
+            this.WriteString(str[last:i])
+            this.WriteString("]]>" + ref + "<![CDATA[")
+            last = i + width
+        }
+        i += width
+    }
+
+    this.WriteString(str[last:])
+}
+
+//	charRef	字符无法用输出字符集表示时返回对应的字符引用，否则返回空字符串
+func (this *xmlWriter) charRef(r rune) string {
+    if (nil == this.canEncode) || this.canEncode(r) {
+        return ""
+    }
+    return "&#x" + strings.ToUpper(strconv.FormatInt(int64(r), 16)) + ";"
+}
+
+func (this *xmlWriter) Error() error {
+    return this.err
+}
+
+//  PrettyPrinterOptions    用于控制NewPrettyPrinter的输出格式
+//
+//  含有文本的元素(混合内容)以及声明了xml:space="preserve"的元素不会被重新缩进，
+//  它们的全部内容会原样输出，以免改变文本的含义。
+type PrettyPrinterOptions struct {
+    //  Indent  每一级缩进所使用的字符串
+    Indent string
+    //  NewLine 换行符，为空时使用"\n"，Windows风格的文件可以使用"\r\n"
+    NewLine string
+    //  MaxLineWidth    开始标签的宽度超过这个值时，每个属性单独占一行，为0时不限制
+    MaxLineWidth int
+    //  AttributePerLine    为true时，拥有多个属性的元素总是每个属性单独占一行
+    AttributePerLine bool
+}
+
+//	DefaultPrettyPrinterOptions	返回NewPrettyPrinter默认使用的输出格式：4个空格缩进，"\n"换行
+func DefaultPrettyPrinterOptions() *PrettyPrinterOptions {
+    return &PrettyPrinterOptions{
+        Indent:  "    ",
+        NewLine: "\n",
+    }
+}
+
+type xmlPrettyPrinter struct {
+    writer  *xmlWriter
+    options PrettyPrinterOptions
+
+    //  当前的缩进层级
+    depth int
+    //  大于0表示正在输出混合内容，此时不再插入换行和缩进
+    inline int
+    //  是否已经输出过内容，用于决定是否需要先换行
+    started bool
+}
+
+//	NewPrettyPrinter	创建一个带缩进格式的XML输出器，options为nil时使用DefaultPrettyPrinterOptions
+func NewPrettyPrinter(writer io.Writer, options *PrettyPrinterOptions) XMLPrinter {
+    if nil == options {
+        options = DefaultPrettyPrinterOptions()
+    }
+
+    visitor := new(xmlPrettyPrinter)
+    visitor.writer = newXMLWriter(writer)
+    visitor.options = *options
+    if "" == visitor.options.NewLine {
+        visitor.options.NewLine = "\n"
+    }
+    return visitor
+}
+
+//	beginLine	在混合内容之外，另起一行并按照当前层级缩进
+func (this *xmlPrettyPrinter) beginLine(depth int) {
+    if this.inline > 0 {
+        return
+    }
+
+    if this.started {
+        this.writer.WriteString(this.options.NewLine)
+    }
+    this.started = true
+
+    this.writer.WriteString(strings.Repeat(this.options.Indent, depth))
+}
+
+//	wrapAttributes	判断元素的属性是否需要每个单独占一行
+func (this *xmlPrettyPrinter) wrapAttributes(node XMLElement) bool {
+    if (this.inline > 0) || (node.AttributeCount() < 2) {
+        return false
+    }
+
+    if this.options.AttributePerLine {
+        return true
+    }
+
+    if this.options.MaxLineWidth <= 0 {
+        return false
+    }
+
+    width := utf8.RuneCountInString(strings.Repeat(this.options.Indent, this.depth))
+    width += utf8.RuneCountInString("<" + node.Name() + "/>")
+    node.ForeachAttribute(func(attribute XMLAttribute) int {
+        buf := bytes.NewBufferString("")
+        writeAttribute(newXMLWriter(buf), attribute)
+        width += 1 + utf8.RuneCount(buf.Bytes())
+        return 0
+    })
+
+    return width > this.options.MaxLineWidth
+}
+
+func (this *xmlPrettyPrinter) Error() error {
+    return this.writer.Error()
+}
+
+func (this *xmlPrettyPrinter) VisitEnterDocument(node XMLDocument) bool {
+    return nil == this.writer.Error()
+}
+
+func (this *xmlPrettyPrinter) VisitExitDocument(node XMLDocument) bool {
+    if this.started {
+        this.writer.WriteString(this.options.NewLine)
+    }
+    return nil == this.writer.Error()
+}
+
+func (this *xmlPrettyPrinter) VisitEnterElement(node XMLElement) bool {
+    this.beginLine(this.depth)
+    this.writer.WriteString("<")
+    this.writer.WriteString(node.Name())
+
+    if this.wrapAttributes(node) {
+        node.ForeachAttribute(func(attribute XMLAttribute) int {
+            this.beginLine(this.depth + 1)
+            writeAttribute(this.writer, attribute)
+            return 0
+        })
+    } else {
+        node.ForeachAttribute(func(attribute XMLAttribute) int {
+            this.writer.WriteString(" ")
+            writeAttribute(this.writer, attribute)
+            return 0
+        })
+    }
+
+    if node.NoChildren() {
+        this.writer.WriteString("/>")
+        return nil == this.writer.Error()
+    }
+
+    this.writer.WriteString(">")
+
+    //  混合内容以及声明了xml:space="preserve"的元素，其中的所有子孙节点都原样输出
+    if (this.inline > 0) || isMixedContent(node) || ("preserve" == node.Attribute("xml:space", "")) {
+        this.inline++
+    } else {
+        this.depth++
+    }
+    return nil == this.writer.Error()
+}
+
+func (this *xmlPrettyPrinter) VisitExitElement(node XMLElement) bool {
+    if node.NoChildren() {
+        return nil == this.writer.Error()
+    }
+
+    if this.inline > 0 {
+        this.inline--
+    } else {
+        this.depth--
+        this.beginLine(this.depth)
+    }
+
+    this.writer.WriteString("</")
+    this.writer.WriteString(node.Name())
+    this.writer.WriteString(">")
+    return nil == this.writer.Error()
+}
+
+func (this *xmlPrettyPrinter) VisitProcInst(node XMLProcInst) bool {
+    this.beginLine(this.depth)
+    writeProcInst(this.writer, node)
+    return nil == this.writer.Error()
+}
+
+func (this *xmlPrettyPrinter) VisitText(node XMLText) bool {
+    if this.inline > 0 {
+        writeText(this.writer, node)
+        return nil == this.writer.Error()
+    }
+
+    //  混合内容之外只会出现用于排版的空白文本，重新缩进时丢弃它们
+    if !node.CDATA() && ("" == strings.TrimSpace(node.Value())) {
+        return nil == this.writer.Error()
+    }
+
+    this.beginLine(this.depth)
+    writeText(this.writer, node)
+    return nil == this.writer.Error()
+}
+
+func (this *xmlPrettyPrinter) VisitComment(node XMLComment) bool {
+    this.beginLine(this.depth)
+    writeComment(this.writer, node)
+    return nil == this.writer.Error()
+}
+
+func (this *xmlPrettyPrinter) VisitDirective(node XMLDirective) bool {
+    this.beginLine(this.depth)
+    writeDirective(this.writer, node)
+    return nil == this.writer.Error()
+}
+
+//	isMixedContent	判断元素是否含有混合内容，也就是含有非空白的文本，或者只含有文本.
+//	对于混合内容，重新缩进会改变文本的含义
+func isMixedContent(node XMLElement) bool {
+    onlyText := true
+    for child := node.FirstChild(); nil != child; child = child.NextSibling() {
+        text := child.ToText()
+        if nil == text {
+            onlyText = false
+            continue
+        }
+
+        if text.CDATA() || ("" != strings.TrimSpace(text.Value())) {
+            return true
+        }
+    }
+
+    return onlyText
+}
+
+//------------------------------------------------------------------
+
+//	writeAttribute	输出name="value"形式的属性，属性值会被转义
+func writeAttribute(writer *xmlWriter, attribute XMLAttribute) {
+    writer.WriteString(attribute.Name())
+    writer.WriteString(`="`)
+    writer.Escape(attribute.Value())
+    writer.WriteString(`"`)
+}
+
+//	writeText	输出文本节点，CDATA节点原样输出，普通文本会被转义.
+//	CDATA的内容中不能出现"]]>"，遇到时将其拆分到两个相邻的CDATA段中
+func writeText(writer *xmlWriter, node XMLText) {
+    if node.CDATA() {
+        writer.WriteString("<![CDATA[")
+        writer.WriteCDATA(strings.Replace(node.Value(), "]]>", "]]]]><![CDATA[>", -1))
+        writer.WriteString("]]>")
+        return
+    }
+
+    writer.EscapeText(node.Value())
+}
+
+//	writeComment	原样输出注释节点，注释的内容不会被转义，NewComment和SetComment已经保证其中不含"--"
+func writeComment(writer *xmlWriter, node XMLComment) {
+    writer.WriteString("<!--")
+    writer.WriteString(node.Value())
+    writer.WriteString("-->")
+}
+
+//	writeProcInst	输出处理指令节点
+func writeProcInst(writer *xmlWriter, node XMLProcInst) {
+    writer.WriteString("<?")
+    writer.WriteString(node.Target())
+    writer.WriteString(" ")
+    writer.WriteString(node.Instruction())
+    writer.WriteString("?>")
+}
+
+//	writeDirective	原样输出指令节点，DOCTYPE的内部子集中含有标记，不能被转义
+func writeDirective(writer *xmlWriter, node XMLDirective) {
+    writer.WriteString("<!")
+    writer.WriteString(node.Value())
+    writer.WriteString(">")
+}
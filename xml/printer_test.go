@@ -0,0 +1,292 @@
+package tinydom_test
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+    "tinydom/xml"
+)
+
+func prettyPrint(doc tinydom.XMLDocument, options *tinydom.PrettyPrinterOptions) string {
+    buf := bytes.NewBufferString("")
+    doc.Accept(tinydom.NewPrettyPrinter(buf, options))
+    return buf.String()
+}
+
+func Test_PrettyPrinter_默认格式(t *testing.T) {
+    xml := `<?xml version="1.0" encoding="UTF-8"?><!--comment--><books><book id="1"><name>The Moon</name><author>Tom</author><tags/></book></books>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    result := `<?xml version="1.0" encoding="UTF-8"?>
+<!--comment-->
+<books>
+    <book id="1">
+        <name>The Moon</name>
+        <author>Tom</author>
+        <tags/>
+    </book>
+</books>
+`
+    expect(t, "默认使用4个空格缩进", result == prettyPrint(doc, nil))
+}
+
+func Test_PrettyPrinter_缩进与换行(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<a><b><c/></b></a>`))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    result := prettyPrint(doc, &tinydom.PrettyPrinterOptions{Indent: "\t", NewLine: "\r\n"})
+    expect(t, "自定义缩进和换行符", "<a>\r\n\t<b>\r\n\t\t<c/>\r\n\t</b>\r\n</a>\r\n" == result)
+
+    result = prettyPrint(doc, &tinydom.PrettyPrinterOptions{Indent: " "})
+    expect(t, "换行符为空时使用\\n", "<a>\n <b>\n  <c/>\n </b>\n</a>\n" == result)
+}
+
+func Test_PrettyPrinter_混合内容不重新缩进(t *testing.T) {
+    xml := `<doc><p>Hello <b>big</b> world<br/>!</p><list><item>one</item></list></doc>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    result := `<doc>
+  <p>Hello <b>big</b> world<br/>!</p>
+  <list>
+    <item>one</item>
+  </list>
+</doc>
+`
+    expect(t, "混合内容原样输出", result == prettyPrint(doc, &tinydom.PrettyPrinterOptions{Indent: "  "}))
+}
+
+func Test_PrettyPrinter_重新缩进时丢弃排版空白(t *testing.T) {
+    doc := tinydom.NewDocument()
+    root := doc.InsertEndChild(tinydom.NewElement(doc, "root"))
+    root.InsertEndChild(tinydom.NewText(doc, "\n      "))
+    root.InsertEndChild(tinydom.NewElement(doc, "child"))
+    root.InsertEndChild(tinydom.NewText(doc, "\n"))
+    space := root.InsertEndChild(tinydom.NewElement(doc, "space"))
+    space.InsertEndChild(tinydom.NewText(doc, "   "))
+
+    result := "<root>\n  <child/>\n  <space>   </space>\n</root>\n"
+    expect(t, "排版空白被丢弃，只有空白的元素原样输出", result == prettyPrint(doc, &tinydom.PrettyPrinterOptions{Indent: "  "}))
+}
+
+func Test_PrettyPrinter_属性换行(t *testing.T) {
+    xml := `<root><item name="first" value="1"/><short a="1"/><wrap alpha="aaaaaaaa" beta="bbbbbbbb"><x/></wrap></root>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    result := `<root>
+  <item
+    name="first"
+    value="1"/>
+  <short a="1"/>
+  <wrap
+    alpha="aaaaaaaa"
+    beta="bbbbbbbb">
+    <x/>
+  </wrap>
+</root>
+`
+    expect(t, "每个属性单独占一行", result == prettyPrint(doc, &tinydom.PrettyPrinterOptions{Indent: "  ", AttributePerLine: true}))
+
+    result = `<root>
+  <item name="first" value="1"/>
+  <short a="1"/>
+  <wrap
+    alpha="aaaaaaaa"
+    beta="bbbbbbbb">
+    <x/>
+  </wrap>
+</root>
+`
+    expect(t, "超过最大行宽时换行", result == prettyPrint(doc, &tinydom.PrettyPrinterOptions{Indent: "  ", MaxLineWidth: 36}))
+}
+
+//  failWriter  在写入limit个字节之后返回错误
+type failWriter struct {
+    limit int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (this *failWriter) Write(data []byte) (int, error) {
+    if len(data) > this.limit {
+        n := this.limit
+        this.limit = 0
+        return n, errWriteFailed
+    }
+
+    this.limit -= len(data)
+    return len(data), nil
+}
+
+func Test_Save_输出到流(t *testing.T) {
+    xml := `<?xml version="1.0" encoding="UTF-8"?><books><book id="1"><name>The Moon</name></book></books>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    buf := bytes.NewBufferString("")
+    expect(t, "输出成功", nil == doc.SaveTo(buf))
+    expect(t, "默认与SimplePrinter的输出相同", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+`<books><book id="1"><name>The Moon</name></book></books>` == buf.String())
+
+    buf.Reset()
+    expect(t, "带格式输出成功", nil == doc.SaveTo(buf, tinydom.WithPrettyPrint(&tinydom.PrettyPrinterOptions{Indent: " "})))
+    expect(t, "带格式输出", strings.HasPrefix(buf.String(), "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<books>\n <book id=\"1\">\n"))
+
+    expect(t, "写入错误被返回", errWriteFailed == doc.SaveTo(&failWriter{limit: 10}))
+
+    printer := tinydom.NewSimplePrinter(&failWriter{limit: 10})
+    expect(t, "写入失败后停止遍历", false == doc.Accept(printer))
+    expect(t, "写入错误被记录", errWriteFailed == printer.Error())
+}
+
+func Test_Save_输出到文件(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<root><item>1</item></root>`))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    path := filepath.Join(t.TempDir(), "doc.xml")
+    expect(t, "保存文件", nil == doc.SaveFile(path, tinydom.WithPrettyPrint(nil)))
+
+    data, err := ioutil.ReadFile(path)
+    expect(t, "读取文件", nil == err)
+    expect(t, "文件内容", "<root>\n    <item>1</item>\n</root>\n" == string(data))
+
+    expect(t, "目录不存在", nil != doc.SaveFile(filepath.Join(t.TempDir(), "none", "doc.xml")))
+}
+
+func Test_Save_节点的字符串形式(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<root a="1"><item>x &amp; y</item><!--c--></root>`))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    root := doc.FirstChildElement("root")
+    expect(t, "文档的字符串形式", `<root a="1"><item>x &amp; y</item><!--c--></root>` == doc.String())
+    expect(t, "元素的outer XML", `<item>x &amp; y</item>` == root.FirstChildElement("item").String())
+    expect(t, "文本节点", `x &amp; y` == string(root.FirstChildElement("item").FirstChild().Bytes()))
+    expect(t, "注释节点", `<!--c-->` == root.LastChild().String())
+}
+
+func Test_Save_文本的转义(t *testing.T) {
+    doc := tinydom.NewDocument()
+    root := doc.InsertEndChild(tinydom.NewElement(doc, "root")).ToElement()
+    root.SetAttribute("attr", "a\"b\nc")
+    root.SetText("line1\n\tline2\r\n<&>")
+
+    expect(t, "文本中的换行和制表符原样输出", "<root attr=\"a&#34;b&#xA;c\">line1\n\tline2&#xD;\n&lt;&amp;&gt;</root>" == doc.String())
+
+    loaded, err := tinydom.LoadDocument(strings.NewReader(doc.String()))
+    expect(t, "返回值检测", nil == err)
+    expect(t, "文本内容保持不变", "line1\n\tline2\r\n<&>" == loaded.FirstChildElement("root").Text())
+    expect(t, "属性值保持不变", "a\"b\nc" == loaded.FirstChildElement("root").Attribute("attr", ""))
+}
+
+func Test_Save_输出字符集(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<a x="é中"><![CDATA[<é中>]]>café 中文</a>`))
+    expect(t, "返回值检测", nil == err)
+
+    buf := bytes.NewBufferString("")
+    expect(t, "ISO-8859-1", nil == doc.SaveTo(buf, tinydom.WithEncoding("ISO-8859-1")))
+    expected := `<?xml version="1.0" encoding="ISO-8859-1"?>` + "\n" + "<a x=\"\xE9&#x4E2D;\"><![CDATA[<\xE9]]>&#x4E2D;<![CDATA[>]]>caf\xE9 &#x4E2D;&#x6587;</a>"
+    expect(t, "无法表示的字符输出为字符引用", expected == buf.String())
+
+    loaded, err := tinydom.LoadDocument(bytes.NewReader(buf.Bytes()))
+    expect(t, "重新加载", nil == err && "é中" == loaded.FirstChildElement("a").Attribute("x", "") && "<é中>café 中文" == loaded.FirstChildElement("a").InnerText())
+
+    buf.Reset()
+    expect(t, "US-ASCII", nil == doc.SaveTo(buf, tinydom.WithEncoding("us-ascii"), tinydom.WithDeclaration(false)))
+    expect(t, "ASCII的输出", `<a x="&#xE9;&#x4E2D;"><![CDATA[<]]>&#xE9;<![CDATA[]]>&#x4E2D;<![CDATA[>]]>caf&#xE9; &#x4E2D;&#x6587;</a>` == buf.String())
+
+    buf.Reset()
+    expect(t, "UTF-16LE", nil == doc.SaveTo(buf, tinydom.WithEncoding("UTF-16LE"), tinydom.WithBOM(), tinydom.WithPrettyPrint(nil)))
+    expect(t, "UTF-16LE的BOM", bytes.HasPrefix(buf.Bytes(), []byte{0xFF, 0xFE, '<', 0}))
+    loaded, err = tinydom.LoadDocument(bytes.NewReader(buf.Bytes()))
+    expect(t, "重新加载UTF-16", nil == err && "<é中>café 中文" == loaded.FirstChildElement("a").InnerText())
+
+    buf.Reset()
+    expect(t, "UTF-16", nil == doc.SaveTo(buf, tinydom.WithEncoding("UTF-16")))
+    expect(t, "UTF-16总是有BOM", bytes.HasPrefix(buf.Bytes(), []byte{0xFE, 0xFF, 0, '<', 0, '?'}))
+    loaded, err = tinydom.LoadDocument(bytes.NewReader(buf.Bytes()))
+    expect(t, "重新加载带BOM的UTF-16", nil == err && "UTF-16" == loaded.Declaration().Encoding() && "<é中>café 中文" == loaded.FirstChildElement("a").InnerText())
+
+    buf.Reset()
+    expect(t, "UTF-16BE", nil == doc.SaveTo(buf, tinydom.WithEncoding("UTF-16BE")))
+    expect(t, "UTF-16BE的BOM是可选的", bytes.HasPrefix(buf.Bytes(), []byte{0, '<', 0, '?'}))
+
+    buf.Reset()
+    expect(t, "UTF-8的BOM", nil == doc.SaveTo(buf, tinydom.WithBOM()) && bytes.HasPrefix(buf.Bytes(), []byte("\xEF\xBB\xBF<a")))
+
+    buf.Reset()
+    expect(t, "ISO-8859-1没有BOM", nil == doc.SaveTo(buf, tinydom.WithEncoding("latin1"), tinydom.WithBOM()) && bytes.HasPrefix(buf.Bytes(), []byte("<?xml")))
+
+    expect(t, "没有注册的字符集", errors.Is(doc.SaveTo(bytes.NewBufferString(""), tinydom.WithEncoding("x-none")), tinydom.ErrUnsupportedCharset))
+
+    doc.FirstChildElement("a").InsertEndChild(tinydom.NewElement(doc, "中"))
+    expect(t, "元素名无法表示", errors.Is(doc.SaveTo(bytes.NewBufferString(""), tinydom.WithEncoding("latin1")), tinydom.ErrUnrepresentableChar))
+
+    comment := tinydom.NewDocument()
+    comment.InsertEndChild(tinydom.NewComment(comment, "café"))
+    comment.SetRootElement(tinydom.NewElement(comment, "a"))
+    buf.Reset()
+    expect(t, "ASCII不能表示Latin-1的字符", errors.Is(comment.SaveTo(buf, tinydom.WithEncoding("US-ASCII")), tinydom.ErrUnrepresentableChar) && !bytes.Contains(buf.Bytes(), []byte("\xE9")))
+    expect(t, "Latin-1可以表示", nil == comment.SaveTo(bytes.NewBufferString(""), tinydom.WithEncoding("latin1")))
+}
+
+func Test_Save_注册输出字符集(t *testing.T) {
+    //  只能表示ASCII，输出时把字母转为大写，用于模拟一个需要注册的字符集
+    tinydom.RegisterCharsetEncoder("X-Upper", func(r rune) bool {
+        return r < 0x80
+    }, func(output io.Writer) io.WriteCloser {
+        return &upperWriter{output: output}
+    })
+
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<a>b中</a>`))
+    expect(t, "返回值检测", nil == err)
+
+    buf := bytes.NewBufferString("")
+    expect(t, "注册的字符集", nil == doc.SaveTo(buf, tinydom.WithEncoding("x-upper"), tinydom.WithBOM()))
+    expect(t, "注册的字符集的输出", `<?XML VERSION="1.0" ENCODING="X-UPPER"?>`+"\n"+`<A>B&#X4E2D;</A>` == buf.String())
+}
+
+//  upperWriter 把输出的字母转为大写
+type upperWriter struct {
+    output io.Writer
+}
+
+func (this *upperWriter) Write(data []byte) (int, error) {
+    return this.output.Write(bytes.ToUpper(data))
+}
+
+func (this *upperWriter) Close() error {
+    return nil
+}
+
+func Test_Save_XML声明(t *testing.T) {
+    save := func(source string, options ...tinydom.SaveOption) string {
+        doc, err := tinydom.LoadDocument(strings.NewReader(source))
+        expect(t, "返回值检测", nil == err)
+        buf := bytes.NewBufferString("")
+        expect(t, "输出成功", nil == doc.SaveTo(buf, options...))
+        return buf.String()
+    }
+
+    latin1 := "<?xml version='1.0' encoding='ISO-8859-1' standalone='yes'?><a>caf\xE9</a>"
+    expect(t, "改写与输出不一致的声明", "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n<a>café</a>" == save(latin1))
+    expect(t, "一致的声明原样输出", "<?xml version='1.0' encoding='ISO-8859-1' standalone='yes'?>\n<a>caf\xE9</a>" == save(latin1, tinydom.WithEncoding("latin1")))
+    expect(t, "没有encoding的声明原样输出", "<?xml version=\"1.0\"?>\n<a/>" == save(`<?xml version="1.0"?><a/>`))
+    expect(t, "指定字符集时补充encoding", "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<a/>" == save(`<?xml version="1.0"?><a/>`, tinydom.WithEncoding("utf-8")))
+    expect(t, "指定字符集时补充声明", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<a/>" == save(`<a/>`, tinydom.WithEncoding("UTF-8")))
+    expect(t, "WithDeclaration(true)", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!--c--><a/>" == save(`<!--c--><a/>`, tinydom.WithDeclaration(true)))
+    expect(t, "WithDeclaration(false)", `<a/>` == save(`<?xml version="1.0"?><a/>`, tinydom.WithDeclaration(false)))
+    expect(t, "带格式输出", "<?xml version=\"1.0\" encoding=\"US-ASCII\"?>\n<a/>\n" == save(`<a/>`, tinydom.WithEncoding("US-ASCII"), tinydom.WithPrettyPrint(nil)))
+    expect(t, "没有选项时不添加声明", `<a/>` == save(`<a/>`))
+}
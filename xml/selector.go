@@ -0,0 +1,792 @@
+package tinydom
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+//  XMLSelector 是编译好的CSS选择器，可以被多次使用
+//
+//  支持的语法：
+//      类型选择器  name、*、ns|name、*|name、ns|*
+//      ID和类      #id匹配id属性，.class匹配class属性中以空白分隔的一项
+//      属性选择器  [attr]、[attr=v]、[attr~=v]、[attr|=v]、[attr^=v]、[attr$=v]、[attr*=v]，值后面加i表示忽略大小写
+//      组合器      空白(子孙)、>(子元素)、+(紧邻的兄弟)、~(之后的兄弟)
+//      伪类        :root、:empty、:first-child、:last-child、:only-child、:nth-child(an+b)、:nth-last-child(an+b)、
+//                  :first-of-type、:last-of-type、:only-of-type、:nth-of-type(an+b)、:nth-last-of-type(an+b)、:not(选择器列表)
+//      选择器列表  以逗号分隔的多个选择器
+//
+//  没有前缀的类型选择器匹配本地名或者限定名，ns|name中的ns与元素的前缀进行比较；名字中的特殊字符可以使用反斜杠转义，比如xml\:lang。
+type XMLSelector interface {
+    Match(elem XMLElement) bool
+    QuerySelector(node XMLNode) XMLElement
+    QuerySelectorAll(node XMLNode) []XMLElement
+    String() string
+}
+
+//  SelectorError   是编译CSS选择器时返回的错误
+type SelectorError struct {
+    Selector string
+    Msg      string
+}
+
+func (this *SelectorError) Error() string {
+    return "selector " + strconv.Quote(this.Selector) + ": " + this.Msg
+}
+
+//	CompileSelector	编译CSS选择器
+func CompileSelector(selector string) (XMLSelector, error) {
+    parser := &selectorParser{input: selector}
+    list, err := parser.parse()
+    if nil != err {
+        return nil, &SelectorError{Selector: selector, Msg: err.Error()}
+    }
+
+    return &xmlSelectorImpl{source: selector, list: list}, nil
+}
+
+//	QuerySelector	返回node的子孙元素中按文档顺序第一个匹配selector的元素，没有匹配或者选择器错误时返回nil
+func QuerySelector(node XMLNode, selector string) XMLElement {
+    compiled, err := CompileSelector(selector)
+    if nil != err {
+        return nil
+    }
+
+    return compiled.QuerySelector(node)
+}
+
+//	QuerySelectorAll	按文档顺序返回node的子孙元素中所有匹配selector的元素，选择器错误时返回nil
+func QuerySelectorAll(node XMLNode, selector string) []XMLElement {
+    compiled, err := CompileSelector(selector)
+    if nil != err {
+        return nil
+    }
+
+    return compiled.QuerySelectorAll(node)
+}
+
+//------------------------------------------------------------------
+
+type xmlSelectorImpl struct {
+    source string
+    list   selectorList
+}
+
+func (this *xmlSelectorImpl) String() string {
+    return this.source
+}
+
+func (this *xmlSelectorImpl) Match(elem XMLElement) bool {
+    if nil == elem {
+        return false
+    }
+
+    return this.list.match(elem)
+}
+
+func (this *xmlSelectorImpl) QuerySelector(node XMLNode) XMLElement {
+    var result XMLElement
+    selectorWalk(node, func(elem XMLElement) bool {
+        if this.list.match(elem) {
+            result = elem
+            return false
+        }
+        return true
+    })
+    return result
+}
+
+func (this *xmlSelectorImpl) QuerySelectorAll(node XMLNode) []XMLElement {
+    result := make([]XMLElement, 0)
+    selectorWalk(node, func(elem XMLElement) bool {
+        if this.list.match(elem) {
+            result = append(result, elem)
+        }
+        return true
+    })
+    return result
+}
+
+//	selectorWalk	按文档顺序遍历node的子孙元素，fn返回false时停止遍历
+func selectorWalk(node XMLNode, fn func(elem XMLElement) bool) bool {
+    if nil == node {
+        return true
+    }
+
+    for child := node.FirstChild(); nil != child; child = child.NextSibling() {
+        elem := child.ToElement()
+        if nil == elem {
+            continue
+        }
+
+        if !fn(elem) || !selectorWalk(elem, fn) {
+            return false
+        }
+    }
+    return true
+}
+
+//==================================================================
+//  选择器的结构
+
+//  selectorList    以逗号分隔的选择器列表，匹配其中任意一个即可
+type selectorList []*complexSelector
+
+func (this selectorList) match(elem XMLElement) bool {
+    for _, selector := range this {
+        if selector.match(elem, len(selector.compounds)-1) {
+            return true
+        }
+    }
+    return false
+}
+
+//  complexSelector 由组合器连接的复合选择器，combinators[i]位于compounds[i]和compounds[i+1]之间
+type complexSelector struct {
+    compounds   []*compoundSelector
+    combinators []byte
+}
+
+//	match	从右向左匹配，判断elem是否满足compounds[index]以及它左边的部分
+func (this *complexSelector) match(elem XMLElement, index int) bool {
+    if !this.compounds[index].match(elem) {
+        return false
+    }
+
+    if 0 == index {
+        return true
+    }
+
+    switch this.combinators[index-1] {
+    case '>':
+        parent := selectorParent(elem)
+        return (nil != parent) && this.match(parent, index-1)
+    case '+':
+        prev := elem.PreviousSiblingElement("")
+        return (nil != prev) && this.match(prev, index-1)
+    case '~':
+        for prev := elem.PreviousSiblingElement(""); nil != prev; prev = prev.PreviousSiblingElement("") {
+            if this.match(prev, index-1) {
+                return true
+            }
+        }
+        return false
+    }
+
+    //  子孙组合器
+    for parent := selectorParent(elem); nil != parent; parent = selectorParent(parent) {
+        if this.match(parent, index-1) {
+            return true
+        }
+    }
+    return false
+}
+
+//	selectorParent	返回父元素，父节点是文档或者没有父节点时返回nil
+func selectorParent(elem XMLElement) XMLElement {
+    parent := elem.Parent()
+    if nil == parent {
+        return nil
+    }
+    return parent.ToElement()
+}
+
+//  selectorCondition   是复合选择器中的一个简单选择器
+type selectorCondition func(elem XMLElement) bool
+
+//  compoundSelector    由类型选择器以及若干个ID、类、属性和伪类选择器组成，需要全部满足
+type compoundSelector struct {
+    conditions []selectorCondition
+}
+
+func (this *compoundSelector) match(elem XMLElement) bool {
+    for _, condition := range this.conditions {
+        if !condition(elem) {
+            return false
+        }
+    }
+    return true
+}
+
+//	selectorMatchName	按照类型选择器的规则匹配名字，hasPrefix为false时不限制前缀
+func selectorMatchName(prefix string, hasPrefix bool, name string, itemPrefix string, itemLocal string, itemName string) bool {
+    if hasPrefix && ("*" != prefix) && (prefix != itemPrefix) {
+        return false
+    }
+
+    if "*" == name {
+        return true
+    }
+
+    if hasPrefix {
+        return name == itemLocal
+    }
+    return (name == itemLocal) || (name == itemName)
+}
+
+//	selectorSiblings	返回elem在兄弟元素中的位置(从1开始)以及兄弟元素的个数，sameType为true时只计算同名的元素
+func selectorSiblings(elem XMLElement, sameType bool) (int, int) {
+    parent := elem.Parent()
+    if nil == parent {
+        return 1, 1
+    }
+
+    name := ""
+    if sameType {
+        name = elem.Name()
+    }
+
+    index, count := 0, 0
+    for sibling := parent.FirstChildElement(name); nil != sibling; sibling = sibling.NextSiblingElement(name) {
+        count++
+        if sibling == elem {
+            index = count
+        }
+    }
+    return index, count
+}
+
+//	selectorNth	判断index是否满足an+b
+func selectorNth(a int, b int, index int) bool {
+    if 0 == a {
+        return index == b
+    }
+
+    diff := index - b
+    return (0 == diff%a) && (diff/a >= 0)
+}
+
+//==================================================================
+//  语法分析
+
+type selectorParser struct {
+    input string
+    pos   int
+}
+
+func (this *selectorParser) eof() bool {
+    return this.pos >= len(this.input)
+}
+
+func (this *selectorParser) peek() byte {
+    if this.eof() {
+        return 0
+    }
+    return this.input[this.pos]
+}
+
+func (this *selectorParser) skipSpace() bool {
+    start := this.pos
+    for !this.eof() && isXMLSpace(rune(this.input[this.pos])) {
+        this.pos++
+    }
+    return this.pos > start
+}
+
+func (this *selectorParser) unexpected() error {
+    if this.eof() {
+        return fmt.Errorf("unexpected end of selector")
+    }
+
+    r, _ := utf8.DecodeRuneInString(this.input[this.pos:])
+    return fmt.Errorf("unexpected %q at offset %d", r, this.pos)
+}
+
+func (this *selectorParser) expect(c byte) error {
+    if c != this.peek() {
+        return this.unexpected()
+    }
+    this.pos++
+    return nil
+}
+
+func (this *selectorParser) parse() (selectorList, error) {
+    list, err := this.parseList()
+    if nil != err {
+        return nil, err
+    }
+
+    if !this.eof() {
+        return nil, this.unexpected()
+    }
+    return list, nil
+}
+
+//	parseList	解析以逗号分隔的选择器列表，遇到输入结束或者)时停止
+func (this *selectorParser) parseList() (selectorList, error) {
+    var list selectorList
+    for {
+        this.skipSpace()
+        selector, err := this.parseComplex()
+        if nil != err {
+            return nil, err
+        }
+        list = append(list, selector)
+
+        this.skipSpace()
+        if ',' != this.peek() {
+            return list, nil
+        }
+        this.pos++
+    }
+}
+
+func (this *selectorParser) parseComplex() (*complexSelector, error) {
+    selector := new(complexSelector)
+    for {
+        compound, err := this.parseCompound()
+        if nil != err {
+            return nil, err
+        }
+        selector.compounds = append(selector.compounds, compound)
+
+        hasSpace := this.skipSpace()
+        c := this.peek()
+        if this.eof() || (',' == c) || (')' == c) {
+            return selector, nil
+        }
+
+        combinator := byte(' ')
+        if ('>' == c) || ('+' == c) || ('~' == c) {
+            combinator = c
+            this.pos++
+            this.skipSpace()
+        } else if !hasSpace {
+            return nil, this.unexpected()
+        }
+        selector.combinators = append(selector.combinators, combinator)
+    }
+}
+
+func (this *selectorParser) parseCompound() (*compoundSelector, error) {
+    compound := new(compoundSelector)
+
+    //  类型选择器
+    c := this.peek()
+    if ('*' == c) || ('|' == c) || this.isNameStart() {
+        prefix, hasPrefix, name, err := this.parseQualifiedName()
+        if nil != err {
+            return nil, err
+        }
+        compound.conditions = append(compound.conditions, func(elem XMLElement) bool {
+            return selectorMatchName(prefix, hasPrefix, name, elem.Prefix(), elem.LocalName(), elem.Name())
+        })
+    }
+
+    for {
+        var condition selectorCondition
+        var err error
+        switch this.peek() {
+        case '#':
+            this.pos++
+            condition, err = this.parseValueCondition("id", func(value string, id string) bool {
+                return value == id
+            })
+        case '.':
+            this.pos++
+            condition, err = this.parseValueCondition("class", func(value string, class string) bool {
+                for _, item := range strings.FieldsFunc(value, isXMLSpace) {
+                    if item == class {
+                        return true
+                    }
+                }
+                return false
+            })
+        case '[':
+            this.pos++
+            condition, err = this.parseAttribute()
+        case ':':
+            this.pos++
+            condition, err = this.parsePseudo()
+        default:
+            if 0 == len(compound.conditions) {
+                return nil, this.unexpected()
+            }
+            return compound, nil
+        }
+
+        if nil != err {
+            return nil, err
+        }
+        compound.conditions = append(compound.conditions, condition)
+    }
+}
+
+//	parseValueCondition	解析#id和.class中的名字，生成比较属性值的条件
+func (this *selectorParser) parseValueCondition(attribute string, match func(value string, name string) bool) (selectorCondition, error) {
+    name, err := this.parseName()
+    if nil != err {
+        return nil, err
+    }
+    if "" == name {
+        return nil, this.unexpected()
+    }
+
+    return func(elem XMLElement) bool {
+        attr := elem.FindAttribute(attribute)
+        return (nil != attr) && match(attr.Value(), name)
+    }, nil
+}
+
+//	parseQualifiedName	解析name、*、ns|name、*|name、|name形式的名字
+func (this *selectorParser) parseQualifiedName() (prefix string, hasPrefix bool, name string, err error) {
+    first, err := this.parseNameOrStar()
+    if nil != err {
+        return "", false, "", err
+    }
+
+    //  ns|name，注意区分属性选择器中的|=
+    if ('|' == this.peek()) && ((this.pos+1 >= len(this.input)) || ('=' != this.input[this.pos+1])) {
+        this.pos++
+        name, err = this.parseNameOrStar()
+        if nil != err {
+            return "", false, "", err
+        }
+        if "" == name {
+            return "", false, "", this.unexpected()
+        }
+        return first, true, name, nil
+    }
+
+    if "" == first {
+        return "", false, "", this.unexpected()
+    }
+    return "", false, first, nil
+}
+
+func (this *selectorParser) parseNameOrStar() (string, error) {
+    if '*' == this.peek() {
+        this.pos++
+        return "*", nil
+    }
+    return this.parseName()
+}
+
+func (this *selectorParser) isNameStart() bool {
+    if this.eof() {
+        return false
+    }
+
+    c := this.input[this.pos]
+    return ('\\' == c) || ('_' == c) || ('-' == c) || (('a' <= c) && (c <= 'z')) || (('A' <= c) && (c <= 'Z')) || (c >= 0x80)
+}
+
+//	parseName	解析一个名字，支持反斜杠转义，没有名字时返回空字符串
+func (this *selectorParser) parseName() (string, error) {
+    var buf strings.Builder
+    for !this.eof() {
+        c := this.input[this.pos]
+        switch {
+        case '\\' == c:
+            r, err := this.parseEscape()
+            if nil != err {
+                return "", err
+            }
+            buf.WriteRune(r)
+        case ('_' == c) || ('-' == c) || (('a' <= c) && (c <= 'z')) || (('A' <= c) && (c <= 'Z')) || (('0' <= c) && (c <= '9')):
+            buf.WriteByte(c)
+            this.pos++
+        case c >= 0x80:
+            r, width := utf8.DecodeRuneInString(this.input[this.pos:])
+            buf.WriteRune(r)
+            this.pos += width
+        default:
+            return buf.String(), nil
+        }
+    }
+    return buf.String(), nil
+}
+
+//	parseEscape	解析反斜杠转义：\后面是1到6位十六进制数字(之后可以跟一个空白)，或者是任意一个字符
+func (this *selectorParser) parseEscape() (rune, error) {
+    this.pos++
+    if this.eof() {
+        return 0, fmt.Errorf("incomplete escape at end of selector")
+    }
+
+    end := this.pos
+    for (end < len(this.input)) && (end-this.pos < 6) && strings.IndexByte("0123456789abcdefABCDEF", this.input[end]) >= 0 {
+        end++
+    }
+
+    if end > this.pos {
+        code, _ := strconv.ParseUint(this.input[this.pos:end], 16, 32)
+        this.pos = end
+        if !this.eof() && isXMLSpace(rune(this.input[this.pos])) {
+            this.pos++
+        }
+        if (0 == code) || (code > utf8.MaxRune) {
+            return utf8.RuneError, nil
+        }
+        return rune(code), nil
+    }
+
+    r, width := utf8.DecodeRuneInString(this.input[this.pos:])
+    this.pos += width
+    return r, nil
+}
+
+//	parseString	解析单引号或者双引号包围的字符串
+func (this *selectorParser) parseString() (string, error) {
+    quote := this.input[this.pos]
+    this.pos++
+
+    var buf strings.Builder
+    for !this.eof() {
+        c := this.input[this.pos]
+        switch c {
+        case quote:
+            this.pos++
+            return buf.String(), nil
+        case '\\':
+            r, err := this.parseEscape()
+            if nil != err {
+                return "", err
+            }
+            buf.WriteRune(r)
+        default:
+            buf.WriteByte(c)
+            this.pos++
+        }
+    }
+    return "", fmt.Errorf("unterminated string")
+}
+
+//	parseAttribute	解析[之后的属性选择器
+func (this *selectorParser) parseAttribute() (selectorCondition, error) {
+    this.skipSpace()
+    prefix, hasPrefix, name, err := this.parseQualifiedName()
+    if nil != err {
+        return nil, err
+    }
+
+    //  找出所有名字匹配的属性
+    attributes := func(elem XMLElement) []XMLAttribute {
+        if !hasPrefix {
+            if attr := elem.FindAttribute(name); nil != attr {
+                return []XMLAttribute{attr}
+            }
+            return nil
+        }
+
+        var result []XMLAttribute
+        for attr := elem.FirstAttribute(); nil != attr; attr = attr.NextAttribute() {
+            if selectorMatchName(prefix, true, name, attr.Prefix(), attr.LocalName(), attr.Name()) {
+                result = append(result, attr)
+            }
+        }
+        return result
+    }
+
+    this.skipSpace()
+    if ']' == this.peek() {
+        this.pos++
+        return func(elem XMLElement) bool {
+            return len(attributes(elem)) > 0
+        }, nil
+    }
+
+    op := ""
+    for _, candidate := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+        if strings.HasPrefix(this.input[this.pos:], candidate) {
+            op = candidate
+            break
+        }
+    }
+    if "" == op {
+        return nil, this.unexpected()
+    }
+    this.pos += len(op)
+
+    this.skipSpace()
+    var value string
+    if c := this.peek(); ('"' == c) || ('\'' == c) {
+        value, err = this.parseString()
+    } else {
+        value, err = this.parseName()
+        if (nil == err) && ("" == value) {
+            err = this.unexpected()
+        }
+    }
+    if nil != err {
+        return nil, err
+    }
+
+    this.skipSpace()
+    ignoreCase := false
+    if c := this.peek(); ('i' == c) || ('I' == c) || ('s' == c) || ('S' == c) {
+        ignoreCase = ('i' == c) || ('I' == c)
+        this.pos++
+        this.skipSpace()
+    }
+    if err := this.expect(']'); nil != err {
+        return nil, err
+    }
+
+    if ignoreCase {
+        value = strings.ToLower(value)
+    }
+
+    return func(elem XMLElement) bool {
+        for _, attr := range attributes(elem) {
+            actual := attr.Value()
+            if ignoreCase {
+                actual = strings.ToLower(actual)
+            }
+            if selectorMatchValue(op, actual, value) {
+                return true
+            }
+        }
+        return false
+    }, nil
+}
+
+//	selectorMatchValue	按照属性选择器的运算符比较属性值
+func selectorMatchValue(op string, actual string, value string) bool {
+    switch op {
+    case "=":
+        return actual == value
+    case "~=":
+        if ("" == value) || strings.IndexFunc(value, isXMLSpace) >= 0 {
+            return false
+        }
+        for _, item := range strings.FieldsFunc(actual, isXMLSpace) {
+            if item == value {
+                return true
+            }
+        }
+        return false
+    case "|=":
+        return (actual == value) || strings.HasPrefix(actual, value+"-")
+    case "^=":
+        return ("" != value) && strings.HasPrefix(actual, value)
+    case "$=":
+        return ("" != value) && strings.HasSuffix(actual, value)
+    }
+
+    //  *=
+    return ("" != value) && strings.Contains(actual, value)
+}
+
+//	parsePseudo	解析:之后的伪类
+func (this *selectorParser) parsePseudo() (selectorCondition, error) {
+    start := this.pos
+    name, err := this.parseName()
+    if nil != err {
+        return nil, err
+    }
+    name = strings.ToLower(name)
+
+    switch name {
+    case "root":
+        return func(elem XMLElement) bool {
+            parent := elem.Parent()
+            return (nil == parent) || (nil != parent.ToDocument())
+        }, nil
+    case "empty":
+        return func(elem XMLElement) bool {
+            for child := elem.FirstChild(); nil != child; child = child.NextSibling() {
+                if (nil != child.ToElement()) || ((nil != child.ToText()) && ("" != child.Value())) {
+                    return false
+                }
+            }
+            return true
+        }, nil
+    case "first-child", "last-child", "only-child", "first-of-type", "last-of-type", "only-of-type":
+        sameType := strings.HasSuffix(name, "-of-type")
+        position := strings.SplitN(name, "-", 2)[0]
+        return func(elem XMLElement) bool {
+            index, count := selectorSiblings(elem, sameType)
+            switch position {
+            case "first":
+                return 1 == index
+            case "last":
+                return count == index
+            }
+            return 1 == count
+        }, nil
+    case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+        if err := this.expect('('); nil != err {
+            return nil, err
+        }
+        end := strings.IndexByte(this.input[this.pos:], ')')
+        if end < 0 {
+            return nil, fmt.Errorf("unterminated :%s()", name)
+        }
+        a, b, err := parseNth(this.input[this.pos : this.pos+end])
+        if nil != err {
+            return nil, fmt.Errorf("invalid argument of :%s() at offset %d", name, this.pos)
+        }
+        this.pos += end + 1
+
+        sameType := strings.HasSuffix(name, "-of-type")
+        fromEnd := strings.HasPrefix(name, "nth-last-")
+        return func(elem XMLElement) bool {
+            index, count := selectorSiblings(elem, sameType)
+            if fromEnd {
+                index = count - index + 1
+            }
+            return selectorNth(a, b, index)
+        }, nil
+    case "not":
+        if err := this.expect('('); nil != err {
+            return nil, err
+        }
+        list, err := this.parseList()
+        if nil != err {
+            return nil, err
+        }
+        if err := this.expect(')'); nil != err {
+            return nil, err
+        }
+        return func(elem XMLElement) bool {
+            return !list.match(elem)
+        }, nil
+    }
+
+    return nil, fmt.Errorf("unsupported pseudo-class :%s at offset %d", name, start)
+}
+
+//	parseNth	解析an+b形式的参数，包括odd和even
+func parseNth(arg string) (int, int, error) {
+    arg = strings.ToLower(strings.Join(strings.FieldsFunc(arg, isXMLSpace), ""))
+    switch arg {
+    case "odd":
+        return 2, 1, nil
+    case "even":
+        return 2, 0, nil
+    }
+
+    index := strings.IndexByte(arg, 'n')
+    if index < 0 {
+        b, err := strconv.Atoi(arg)
+        return 0, b, err
+    }
+
+    a := 0
+    switch coefficient := arg[:index]; coefficient {
+    case "", "+":
+        a = 1
+    case "-":
+        a = -1
+    default:
+        var err error
+        if a, err = strconv.Atoi(coefficient); nil != err {
+            return 0, 0, err
+        }
+    }
+
+    b := 0
+    if offset := arg[index+1:]; "" != offset {
+        if ('+' != offset[0]) && ('-' != offset[0]) {
+            return 0, 0, fmt.Errorf("invalid offset %q", offset)
+        }
+        var err error
+        if b, err = strconv.Atoi(offset); nil != err {
+            return 0, 0, err
+        }
+    }
+    return a, b, nil
+}
@@ -0,0 +1,139 @@
+package tinydom_test
+
+import (
+    "strings"
+    "testing"
+    "tinydom/xml"
+)
+
+const selectorBooks = `<books>
+    <book id="b1" lang="en" class="new hot"><name>The Moon</name><author>Tom</author></book>
+    <book id="b2" lang="en-US" class="old"><name>Stars</name><author>Ann</author><author>Bob</author></book>
+    <magazine id="m1" lang="de"><name>Zeit</name></magazine>
+    <book id="b3" lang="fr" data-tags="a b c"><name>Soleil</name><note/></book>
+</books>`
+
+//  ids 返回元素的id属性或者文本，用于比较查询结果
+func ids(elems []tinydom.XMLElement) string {
+    var parts []string
+    for _, elem := range elems {
+        if id := elem.Attribute("id", ""); "" != id {
+            parts = append(parts, id)
+        } else {
+            parts = append(parts, elem.Text())
+        }
+    }
+    return strings.Join(parts, ",")
+}
+
+func Test_Selector_基本选择器(t *testing.T) {
+    doc := loadString(t, selectorBooks)
+
+    expect(t, "类型选择器", "b1,b2,b3" == ids(doc.QuerySelectorAll("book")))
+    expect(t, "通配符", 13 == len(doc.QuerySelectorAll("*")))
+    expect(t, "ID选择器", "Stars" == doc.QuerySelector("#b2 name").Text())
+    expect(t, "类选择器", "b1" == ids(doc.QuerySelectorAll(".hot")) && "b1" == ids(doc.QuerySelectorAll("book.new.hot")))
+    expect(t, "选择器列表按文档顺序返回", "b1,b2,m1,b3" == ids(doc.QuerySelectorAll("magazine, book")))
+    expect(t, "QuerySelector返回第一个", "The Moon" == doc.QuerySelector("name").Text())
+    expect(t, "没有匹配", nil == doc.QuerySelector("none") && 0 == len(doc.QuerySelectorAll("none")))
+    expect(t, "选择器错误", nil == doc.QuerySelector("book[") && nil == doc.QuerySelectorAll(">"))
+
+    book := doc.QuerySelector("#b2")
+    expect(t, "只在子孙元素中查找", "Ann,Bob" == ids(book.QuerySelectorAll("author")) && nil == book.QuerySelector("book"))
+    expect(t, "组合器可以匹配查找范围之外的祖先", 2 == len(book.QuerySelectorAll("books author")))
+
+    handle := tinydom.NewHandle(doc)
+    expect(t, "XMLHandle", "Bob" == handle.QuerySelector("#b2").QuerySelector("author:last-child").ToElement().Text())
+    expect(t, "XMLHandle没有匹配", nil == handle.QuerySelector("none").QuerySelector("x").ToNode())
+    expect(t, "XMLHandle.QuerySelectorAll", 3 == len(handle.QuerySelectorAll("book")))
+}
+
+func Test_Selector_属性选择器(t *testing.T) {
+    doc := loadString(t, selectorBooks)
+
+    expect(t, "存在", "b3" == ids(doc.QuerySelectorAll("[data-tags]")))
+    expect(t, "等于", "b1" == ids(doc.QuerySelectorAll("book[lang=en]")))
+    expect(t, "引号", "b1" == ids(doc.QuerySelectorAll(`book[lang="en"]`)))
+    expect(t, "|=", "b1,b2" == ids(doc.QuerySelectorAll("[lang|=en]")))
+    expect(t, "~=", "b3" == ids(doc.QuerySelectorAll("[data-tags~=b]")) && 0 == len(doc.QuerySelectorAll("[data-tags~='a b']")))
+    expect(t, "^=", "b1,b2,b3" == ids(doc.QuerySelectorAll("[id^=b]")))
+    expect(t, "$=", "b1,m1" == ids(doc.QuerySelectorAll("[id$='1']")))
+    expect(t, "*=", "b2" == ids(doc.QuerySelectorAll("[lang*='-']")))
+    expect(t, "空值不匹配", 0 == len(doc.QuerySelectorAll("[id^='']")))
+    expect(t, "忽略大小写", "b2" == ids(doc.QuerySelectorAll("[lang='EN-us' i]")))
+}
+
+func Test_Selector_组合器(t *testing.T) {
+    doc := loadString(t, selectorBooks)
+
+    expect(t, "子孙", 4 == len(doc.QuerySelectorAll("books name")))
+    expect(t, "子元素", 0 == len(doc.QuerySelectorAll("books > name")) && 3 == len(doc.QuerySelectorAll("books > book > name")))
+    expect(t, "紧邻的兄弟", "b3" == ids(doc.QuerySelectorAll("magazine + book")))
+    expect(t, "之后的兄弟", "b2,m1,b3" == ids(doc.QuerySelectorAll("#b1 ~ *")))
+    expect(t, "没有空白的组合器", "Tom,Ann,Bob" == ids(doc.QuerySelectorAll("name~author")) && "Ann" == ids(doc.QuerySelectorAll("#b2>name+author")))
+    expect(t, "需要回溯的匹配", "Tom,Ann,Bob" == ids(doc.QuerySelectorAll("books > * author")))
+}
+
+func Test_Selector_伪类(t *testing.T) {
+    doc := loadString(t, selectorBooks)
+
+    expect(t, ":first-child", "b1" == ids(doc.QuerySelectorAll("book:first-child")))
+    expect(t, ":last-child", "Tom,Bob" == ids(doc.QuerySelectorAll("author:last-child")))
+    expect(t, ":only-child", "Zeit" == ids(doc.QuerySelectorAll("name:only-child")))
+    expect(t, ":nth-child", "b2,b3" == ids(doc.QuerySelectorAll("books > :nth-child(2n)")))
+    expect(t, ":nth-child(odd)", "b1,m1" == ids(doc.QuerySelectorAll("books > :nth-child(odd)")))
+    expect(t, ":nth-child(-n+2)", "b1,b2" == ids(doc.QuerySelectorAll("books > :nth-child(-n + 2)")))
+    expect(t, ":nth-last-child", "m1" == ids(doc.QuerySelectorAll("books > :nth-last-child(2)")))
+    expect(t, ":first-of-type", "b1,m1" == ids(doc.QuerySelectorAll("books > :first-of-type")))
+    expect(t, ":last-of-type", "m1,b3" == ids(doc.QuerySelectorAll("books > :last-of-type")))
+    expect(t, ":only-of-type", "m1" == ids(doc.QuerySelectorAll("books > :only-of-type")))
+    expect(t, ":nth-of-type", "b3" == ids(doc.QuerySelectorAll("book:nth-of-type(3)")))
+    expect(t, ":nth-last-of-type", "b1" == ids(doc.QuerySelectorAll("book:nth-last-of-type(3)")))
+    expect(t, ":empty", 1 == len(doc.QuerySelectorAll(":empty")) && "note" == doc.QuerySelector(":empty").Name())
+    expect(t, ":root", "books" == doc.QuerySelector(":root").Name())
+    expect(t, ":not", "b2,b3" == ids(doc.QuerySelectorAll("book:not(.hot)")))
+    expect(t, ":not中的选择器列表", "b3" == ids(doc.QuerySelectorAll("book:not(#b1, [lang|=en])")))
+    expect(t, "组合使用", "The Moon" == doc.QuerySelector("books > book[lang=en]:first-child name").Text())
+}
+
+func Test_Selector_名字空间和转义(t *testing.T) {
+    xml := `<feed xmlns:m="urn:media" xml:lang="en"><m:thumb url="a"/><thumb url="b"/><x.y/></feed>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+
+    urls := func(elems []tinydom.XMLElement) string {
+        var parts []string
+        for _, elem := range elems {
+            parts = append(parts, elem.Attribute("url", ""))
+        }
+        return strings.Join(parts, ",")
+    }
+
+    expect(t, "没有前缀时匹配本地名", "a,b" == urls(doc.QuerySelectorAll("thumb")))
+    expect(t, "指定前缀", "a" == urls(doc.QuerySelectorAll("m|thumb")))
+    expect(t, "没有前缀的元素", "b" == urls(doc.QuerySelectorAll("|thumb")))
+    expect(t, "任意前缀", "a,b" == urls(doc.QuerySelectorAll("*|thumb")))
+    expect(t, "转义限定名", "a" == urls(doc.QuerySelectorAll(`m\:thumb`)))
+    expect(t, "转义点号", 1 == len(doc.QuerySelectorAll(`x\.y`)))
+    expect(t, "属性名前缀", "feed" == doc.QuerySelector("[xml|lang=en]").Name() && "feed" == doc.QuerySelector(`[xml\:lang]`).Name())
+    expect(t, "十六进制转义", "a,b" == urls(doc.QuerySelectorAll(`\74 humb`)))
+}
+
+func Test_Selector_编译(t *testing.T) {
+    for _, selector := range []string{"", "a >", "a,", "[a", "[a=]", ":hover", ":nth-child(x)", "a::before", "a | b", "#", "'a'"} {
+        compiled, err := tinydom.CompileSelector(selector)
+        if nil != compiled || nil == err {
+            t.Errorf("%q should not compile", selector)
+        }
+        if _, ok := err.(*tinydom.SelectorError); !ok {
+            t.Errorf("%q: error should be *SelectorError", selector)
+        }
+    }
+
+    doc := loadString(t, selectorBooks)
+    compiled, err := tinydom.CompileSelector("book[lang|=en]")
+    expect(t, "编译成功", nil == err)
+    expect(t, "Match", compiled.Match(doc.QuerySelector("#b2")) && !compiled.Match(doc.QuerySelector("#b3")) && !compiled.Match(nil))
+    expect(t, "选择器文本", "book[lang|=en]" == compiled.String())
+    expect(t, "包级函数", "b1" == ids([]tinydom.XMLElement{tinydom.QuerySelector(doc, "book")}) && 3 == len(tinydom.QuerySelectorAll(doc, "book")))
+}
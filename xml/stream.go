@@ -0,0 +1,428 @@
+package tinydom
+
+import (
+    "bytes"
+    "encoding/xml"
+    "errors"
+    "io"
+    "strings"
+)
+
+//  XMLStreamHandler    接收流式解析的事件，与XMLVisitor相对应
+//
+//  流式解析不会构建DOM树，每个事件得到的都是新创建的节点：EnterElement得到的元素已经带有全部的属性，但是没有子节点，
+//  ExitElement得到的是同一个元素对象。节点的Parent返回外层仍然打开的元素(文档级别的节点返回一个空的XMLDocument)，
+//  因此NamespaceURI、LookupNamespaceURI等方法可以正常使用，但是父节点并不会把它们当作自己的子节点。
+//  节点的Position记录了它在源文本中的位置。
+//
+//  任何一个方法返回错误都会结束解析，StreamDocument会原样返回这个错误；返回ErrStopStream时StreamDocument返回nil。
+type XMLStreamHandler interface {
+    EnterElement(XMLElement) error
+    ExitElement(XMLElement) error
+
+    ProcInst(XMLProcInst) error
+    Text(XMLText) error
+    Comment(XMLComment) error
+    Directive(XMLDirective) error
+}
+
+//  ErrStopStream   由XMLStreamHandler返回，表示提前结束流式解析而不是出现了错误
+var ErrStopStream = errors.New("stop stream")
+
+//	StreamDocument	从rd流中读取XML码流，并把解析得到的节点依次交给handler处理
+//
+//	与LoadDocument不同，解析过程中只保留仍然打开的元素，因此可以用固定的内存处理非常大的文件。
+//	解析失败时返回的错误与LoadDocument相同，总是*ParseError
+func StreamDocument(rd io.Reader, handler XMLStreamHandler) error {
+    return StreamDocumentWithOptions(rd, handler, nil)
+}
+
+//	StreamDocumentWithOptions	按照options从rd流中读取XML码流，并把解析得到的节点依次交给handler处理，options为nil时与StreamDocument相同
+func StreamDocumentWithOptions(rd io.Reader, handler XMLStreamHandler, options *LoadOptions) error {
+    doc := NewDocument()
+    parser := &xmlParser{options: options, doc: doc, handler: &xmlStreamScope{handler: handler, parent: doc}}
+    if err := parser.parse(rd); ErrStopStream != err {
+        return err
+    }
+
+    return nil
+}
+
+//  xmlStreamScope  为流式解析的节点设置Parent，但是不把它们插入到父节点中
+type xmlStreamScope struct {
+    handler XMLStreamHandler
+    parent  XMLNode
+}
+
+func (this *xmlStreamScope) EnterElement(node XMLElement) error {
+    node.setParent(this.parent)
+    this.parent = node
+    return this.handler.EnterElement(node)
+}
+
+func (this *xmlStreamScope) ExitElement(node XMLElement) error {
+    this.parent = node.Parent()
+    return this.handler.ExitElement(node)
+}
+
+func (this *xmlStreamScope) ProcInst(node XMLProcInst) error {
+    node.setParent(this.parent)
+    return this.handler.ProcInst(node)
+}
+
+func (this *xmlStreamScope) Text(node XMLText) error {
+    node.setParent(this.parent)
+    return this.handler.Text(node)
+}
+
+func (this *xmlStreamScope) Comment(node XMLComment) error {
+    node.setParent(this.parent)
+    return this.handler.Comment(node)
+}
+
+func (this *xmlStreamScope) Directive(node XMLDirective) error {
+    node.setParent(this.parent)
+    return this.handler.Directive(node)
+}
+
+//  xmlParser   把encoding/xml读取到的记号转换为tinydom的节点并交给handler，LoadDocument和StreamDocument共用这个解析循环
+//
+//  xmlParser负责检查文档的结构，比如开始标签与结束标签是否匹配、根元素是否唯一，handler只需要处理节点本身。
+type xmlParser struct {
+    options *LoadOptions
+    doc     XMLDocument
+    handler XMLStreamHandler
+}
+
+//	parse	解析rd中的全部内容，handler返回的错误会被原样返回
+func (this *xmlParser) parse(rd io.Reader) error {
+    options := this.options
+    if nil == options {
+        options = new(LoadOptions)
+    }
+
+    //  在交给encoding/xml之前统一转换为UTF-8，这样记录下来的原始输入与解码器的偏移是一致的
+    input, charset, err := newDecodingReader(rd, options.Charset)
+    if nil != err {
+        return &ParseError{Position: Position{Line: 1, Column: 1}, Msg: "unsupported charset: " + charset, Err: err}
+    }
+
+    doc := this.doc
+    handler := this.handler
+    recorder := newRecordReader(input)
+    decoder := xml.NewDecoder(recorder)
+    decoder.CharsetReader = identityCharsetReader
+    var token xml.Token
+    rootElemExist := false
+
+    //  仍然打开的元素，以及每个打开的元素内部所使用的空白处理方式
+    var open []XMLElement
+    var spaces []WhitespaceMode
+    space := options.Whitespace
+
+    //  当前记号的起始位置
+    var position Position
+    fail := func(msg string, err error) error {
+        return &ParseError{Position: position, Msg: msg, Err: err}
+    }
+
+    //  使用RawToken而不是Token，这样encoding/xml不会把前缀替换为名字空间URI，
+    //  相应地，开始标签与结束标签的匹配检查需要由我们自己完成
+    for {
+        position.Line, position.Column = decoder.InputPos()
+        position.Offset = decoder.InputOffset()
+        recorder.Discard(position.Offset)
+        if token, err = decoder.RawToken(); nil != err {
+            break
+        }
+
+        var handlerErr error
+        switch token.(type) {
+        case xml.StartElement:
+            startElement := token.(xml.StartElement)
+
+            //  一个XML文档只允许有唯一一个根节点
+            if 0 == len(open) {
+                if rootElemExist {
+                    return fail("Root element has been exist:"+joinName(startElement.Name), ErrMultipleRoots)
+                }
+
+                //  标记一下根节点已经存在了
+                rootElemExist = true
+            }
+
+            node := NewElement(doc, joinName(startElement.Name))
+            node.setPosition(position)
+            for _, item := range startElement.Attr {
+                name := joinName(item.Name)
+                if nil != node.FindAttribute(name) {
+                    return fail("Attributes have the same name:"+name, ErrDuplicateAttribute)
+                }
+                node.SetAttribute(name, item.Value)
+            }
+            open = append(open, node)
+
+            spaces = append(spaces, space)
+            switch node.Attribute("xml:space", "") {
+            case "preserve":
+                space = PreserveWhitespace
+            case "default":
+                space = options.Whitespace
+            }
+
+            handlerErr = handler.EnterElement(node)
+        case xml.EndElement:
+            endElement := token.(xml.EndElement)
+            name := joinName(endElement.Name)
+            if 0 == len(open) {
+                msg := "unexpected end element </" + name + ">"
+                return fail(msg, &xml.SyntaxError{Msg: msg, Line: position.Line})
+            }
+
+            elem := open[len(open)-1]
+            if elem.Name() != name {
+                msg := "element <" + elem.Name() + "> closed by </" + name + ">"
+                return fail(msg, &xml.SyntaxError{Msg: msg, Line: position.Line})
+            }
+
+            open = open[:len(open)-1]
+            space = spaces[len(spaces)-1]
+            spaces = spaces[:len(spaces)-1]
+
+            handlerErr = handler.ExitElement(elem)
+        case xml.Comment:
+            comment := token.(xml.Comment)
+            node := NewComment(doc, string(comment))
+            node.setPosition(position)
+            handlerErr = handler.Comment(node)
+        case xml.Directive:
+            //  encoding/xml会把指令中的注释替换为空格，因此尽量使用原始的输入，以便原样保留内部子集中的注释
+            directive := []byte(token.(xml.Directive))
+            if raw := recorder.Bytes(position.Offset, decoder.InputOffset()); bytes.HasPrefix(raw, []byte("<!")) && bytes.HasSuffix(raw, []byte(">")) {
+                directive = raw[len("<!") : len(raw)-len(">")]
+            }
+            node := NewDirective(doc, string(directive))
+            node.setPosition(position)
+            handlerErr = handler.Directive(node)
+        case xml.ProcInst:
+            procInst := token.(xml.ProcInst)
+            node := NewProcInst(doc, procInst.Target, string(procInst.Inst))
+            node.setPosition(position)
+            handlerErr = handler.ProcInst(node)
+        case xml.CharData:
+            charData := token.(xml.CharData)
+
+            //  encoding/xml把CDATA段也当作普通的CharData返回，只能通过原始的输入来识别
+            if recorder.HasPrefix(position.Offset, "<![CDATA[") {
+                if 0 == len(open) {
+                    return fail("Text should be in the element", ErrTextOutsideRoot)
+                }
+
+                node := NewText(doc, string(charData))
+                node.SetCDATA(true)
+                node.setPosition(position)
+                handlerErr = handler.Text(node)
+                break
+            }
+
+            if 0 == len(open) {
+                shortCharData := bytes.TrimSpace(charData)
+                if (nil != shortCharData) && (len(shortCharData) > 0) {
+                    return fail("Text should be in the element", ErrTextOutsideRoot)
+                }
+                break
+            }
+
+            if text, keep := normalizeText(string(charData), space); keep {
+                node := NewText(doc, text)
+                node.setPosition(position)
+                handlerErr = handler.Text(node)
+            }
+        default:
+            return fail("Unsupported token type", ErrUnsupportedToken)
+        }
+
+        if nil != handlerErr {
+            return handlerErr
+        }
+    }
+
+    //  之后的错误都发生在当前读取到的位置
+    position.Line, position.Column = decoder.InputPos()
+    position.Offset = decoder.InputOffset()
+
+    if io.EOF != err {
+        if syntaxError, ok := err.(*xml.SyntaxError); ok {
+            return fail(syntaxError.Msg, err)
+        }
+
+        return fail(err.Error(), err)
+    }
+
+    //  还有未关闭的元素
+    if 0 != len(open) {
+        msg := "unexpected EOF"
+        return fail(msg, &xml.SyntaxError{Msg: msg, Line: position.Line})
+    }
+
+    //  不能是空文档
+    if !rootElemExist {
+        return fail("XML document missing the root element", ErrNoRootElement)
+    }
+
+    return nil
+}
+
+//	StreamElements	流式读取rd，只为与path匹配的元素构建子树并交给callback，callback返回后子树就可以被丢弃
+//
+//	path是XPath的一个子集：/catalog/product从根元素开始逐级匹配，//product或者//catalog/product匹配任意位置上以这些元素结尾的路径，
+//	每一级可以是限定名、本地名或者*。匹配的元素内部不会再查找匹配的元素。
+//
+//	交给callback的元素不属于任何父节点，可以使用所有的导航、查询方法，也可以通过XMLDocument.ImportNode复制到其他文档中；
+//	外层元素上声明的名字空间会被复制到子树的根元素上，因此子树中的前缀依然能够被解析。
+//	callback返回的错误会结束解析并被原样返回，返回ErrStopStream时StreamElements返回nil
+func StreamElements(rd io.Reader, path string, callback func(elem XMLElement) error) error {
+    return StreamElementsWithOptions(rd, path, callback, nil)
+}
+
+//	StreamElementsWithOptions	按照options流式读取rd，只为与path匹配的元素构建子树并交给callback，options为nil时与StreamElements相同
+func StreamElementsWithOptions(rd io.Reader, path string, callback func(elem XMLElement) error, options *LoadOptions) error {
+    streamer, err := newSubtreeStreamer(path, callback)
+    if nil != err {
+        return err
+    }
+
+    parser := &xmlParser{options: options, doc: NewDocument(), handler: streamer}
+    if err := parser.parse(rd); ErrStopStream != err {
+        return err
+    }
+
+    return nil
+}
+
+//  xmlSubtreeStreamer  为与路径匹配的元素构建子树，子树之外只保留仍然打开的元素用于匹配路径和解析名字空间
+type xmlSubtreeStreamer struct {
+    steps    []string
+    anywhere bool
+    callback func(elem XMLElement) error
+
+    //  子树之外仍然打开的元素，它们只有属性没有子节点
+    open []XMLElement
+    //  正在构建的子树的根元素，以及子树中当前打开的节点
+    root    XMLElement
+    current XMLNode
+}
+
+func newSubtreeStreamer(path string, callback func(elem XMLElement) error) (*xmlSubtreeStreamer, error) {
+    streamer := &xmlSubtreeStreamer{callback: callback}
+    rest := path
+    switch {
+    case strings.HasPrefix(rest, "//"):
+        streamer.anywhere = true
+        rest = rest[2:]
+    case strings.HasPrefix(rest, "/"):
+        rest = rest[1:]
+    default:
+        return nil, &XPathError{Expr: path, Msg: "path must start with / or //"}
+    }
+
+    streamer.steps = strings.Split(rest, "/")
+    for _, step := range streamer.steps {
+        if "" == step {
+            return nil, &XPathError{Expr: path, Msg: "empty step"}
+        }
+    }
+    if nil == callback {
+        return nil, &XPathError{Expr: path, Msg: "nil callback"}
+    }
+    return streamer, nil
+}
+
+//	match	判断打开的元素加上elem组成的路径是否与steps匹配
+func (this *xmlSubtreeStreamer) match(elem XMLElement) bool {
+    depth := len(this.open) + 1
+    if depth < len(this.steps) || (!this.anywhere && depth != len(this.steps)) {
+        return false
+    }
+
+    for i := range this.steps {
+        node := elem
+        if i > 0 {
+            node = this.open[len(this.open)-i]
+        }
+        step := this.steps[len(this.steps)-1-i]
+        if "*" != step && node.Name() != step && node.LocalName() != step {
+            return false
+        }
+    }
+    return true
+}
+
+func (this *xmlSubtreeStreamer) EnterElement(node XMLElement) error {
+    if nil != this.current {
+        this.current.InsertEndChild(node)
+        this.current = node
+        return nil
+    }
+
+    if !this.match(node) {
+        this.open = append(this.open, node)
+        return nil
+    }
+
+    //  从内向外复制外层元素上的名字空间声明，内层的声明优先
+    for i := len(this.open) - 1; i >= 0; i-- {
+        for attr := this.open[i].FirstAttribute(); nil != attr; attr = attr.NextAttribute() {
+            if XMLNSNamespace == attr.NamespaceURI() && nil == node.FindAttribute(attr.Name()) {
+                node.SetAttribute(attr.Name(), attr.Value())
+            }
+        }
+    }
+    this.root = node
+    this.current = node
+    return nil
+}
+
+func (this *xmlSubtreeStreamer) ExitElement(node XMLElement) error {
+    if nil == this.current {
+        this.open = this.open[:len(this.open)-1]
+        return nil
+    }
+
+    if node != this.root {
+        this.current = node.Parent()
+        return nil
+    }
+
+    this.root = nil
+    this.current = nil
+    return this.callback(node)
+}
+
+func (this *xmlSubtreeStreamer) ProcInst(node XMLProcInst) error {
+    if nil != this.current {
+        this.current.InsertEndChild(node)
+    }
+    return nil
+}
+
+func (this *xmlSubtreeStreamer) Text(node XMLText) error {
+    if nil != this.current {
+        this.current.InsertEndChild(node)
+    }
+    return nil
+}
+
+func (this *xmlSubtreeStreamer) Comment(node XMLComment) error {
+    if nil != this.current {
+        this.current.InsertEndChild(node)
+    }
+    return nil
+}
+
+func (this *xmlSubtreeStreamer) Directive(node XMLDirective) error {
+    if nil != this.current {
+        this.current.InsertEndChild(node)
+    }
+    return nil
+}
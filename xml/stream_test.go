@@ -0,0 +1,236 @@
+package tinydom_test
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+    "tinydom/xml"
+)
+
+//  streamRecorder  把流式解析的事件记录为字符串
+type streamRecorder struct {
+    events []string
+    stopAt string
+    err    error
+}
+
+func (this *streamRecorder) EnterElement(elem tinydom.XMLElement) error {
+    event := "<" + elem.Name()
+    for attr := elem.FirstAttribute(); nil != attr; attr = attr.NextAttribute() {
+        event += " " + attr.Name() + "=" + attr.Value()
+    }
+    if "" != elem.NamespaceURI() {
+        event += " {" + elem.NamespaceURI() + "}"
+    }
+    this.events = append(this.events, event+">")
+    if elem.Name() == this.stopAt {
+        return this.err
+    }
+    return nil
+}
+
+func (this *streamRecorder) ExitElement(elem tinydom.XMLElement) error {
+    this.events = append(this.events, "</"+elem.Name()+">")
+    return nil
+}
+
+func (this *streamRecorder) ProcInst(procInst tinydom.XMLProcInst) error {
+    this.events = append(this.events, "?"+procInst.Target())
+    return nil
+}
+
+func (this *streamRecorder) Text(text tinydom.XMLText) error {
+    if text.CDATA() {
+        this.events = append(this.events, "cdata:"+text.Value())
+    } else {
+        this.events = append(this.events, "text:"+text.Value())
+    }
+    return nil
+}
+
+func (this *streamRecorder) Comment(comment tinydom.XMLComment) error {
+    this.events = append(this.events, "!--"+comment.Comment())
+    return nil
+}
+
+func (this *streamRecorder) Directive(directive tinydom.XMLDirective) error {
+    this.events = append(this.events, "!"+directive.Value())
+    return nil
+}
+
+func Test_Stream_事件(t *testing.T) {
+    xml := `<?xml version="1.0"?>
+<!DOCTYPE feed>
+<!--head-->
+<feed xmlns="urn:feed" xmlns:m="urn:media">
+    <entry id="1">A<![CDATA[<b>]]></entry>
+    <m:thumb/>
+</feed>`
+
+    recorder := new(streamRecorder)
+    err := tinydom.StreamDocument(strings.NewReader(xml), recorder)
+    expect(t, "返回值检测", nil == err)
+    expected := []string{"?xml", "!DOCTYPE feed", "!--head", "<feed xmlns=urn:feed xmlns:m=urn:media {urn:feed}>",
+        "<entry id=1 {urn:feed}>", "text:A", "cdata:<b>", "</entry>", "<m:thumb {urn:media}>", "</m:thumb>", "</feed>"}
+    expect(t, "事件的顺序", strings.Join(expected, "|") == strings.Join(recorder.events, "|"))
+
+    recorder = new(streamRecorder)
+    err = tinydom.StreamDocumentWithOptions(strings.NewReader(`<a> <b/> </a>`), recorder, &tinydom.LoadOptions{Whitespace: tinydom.PreserveWhitespace})
+    expect(t, "解析选项", nil == err && "<a>|text: |<b>|</b>|text: |</a>" == strings.Join(recorder.events, "|"))
+}
+
+//  streamChecker   检查流式解析的节点之间的关系
+type streamChecker struct {
+    streamRecorder
+    t     *testing.T
+    depth int
+    elems []tinydom.XMLElement
+}
+
+func (this *streamChecker) EnterElement(elem tinydom.XMLElement) error {
+    expect(this.t, "元素没有子节点", elem.NoChildren())
+    if 0 == this.depth {
+        expect(this.t, "根元素的父节点是文档", nil != elem.Parent() && nil != elem.Parent().ToDocument() && nil == elem.Parent().FirstChild())
+    } else {
+        expect(this.t, "父节点是外层打开的元素", this.elems[len(this.elems)-1] == elem.Parent())
+    }
+    expect(this.t, "位置信息", elem.Position().IsValid())
+    this.depth++
+    this.elems = append(this.elems, elem)
+    return nil
+}
+
+func (this *streamChecker) ExitElement(elem tinydom.XMLElement) error {
+    this.depth--
+    expect(this.t, "与EnterElement是同一个对象", this.elems[len(this.elems)-1] == elem)
+    this.elems = this.elems[:len(this.elems)-1]
+    return nil
+}
+
+func (this *streamChecker) Text(text tinydom.XMLText) error {
+    expect(this.t, "文本的父节点", this.elems[len(this.elems)-1] == text.Parent())
+    return nil
+}
+
+func Test_Stream_节点关系(t *testing.T) {
+    checker := &streamChecker{t: t}
+    err := tinydom.StreamDocument(strings.NewReader(`<a><b>x<c/></b><d/></a>`), checker)
+    expect(t, "返回值检测", nil == err && 0 == checker.depth)
+}
+
+func Test_Stream_提前结束和错误(t *testing.T) {
+    xml := `<a><b/><c/><d/></a>`
+
+    recorder := &streamRecorder{stopAt: "c", err: tinydom.ErrStopStream}
+    err := tinydom.StreamDocument(strings.NewReader(xml), recorder)
+    expect(t, "ErrStopStream", nil == err && "<a>|<b>|</b>|<c>" == strings.Join(recorder.events, "|"))
+
+    custom := errors.New("custom")
+    recorder = &streamRecorder{stopAt: "c", err: custom}
+    err = tinydom.StreamDocument(strings.NewReader(xml), recorder)
+    expect(t, "handler返回的错误", custom == err)
+
+    for _, source := range []string{`<a><b></a>`, `<a/><b/>`, `text<a/>`, ``, `<a x="1" x="2"/>`} {
+        err = tinydom.StreamDocument(strings.NewReader(source), new(streamRecorder))
+        _, loadErr := tinydom.LoadDocument(strings.NewReader(source))
+        parseErr, ok := err.(*tinydom.ParseError)
+        expect(t, fmt.Sprintf("%q的错误与LoadDocument相同", source), ok && nil != loadErr && loadErr.Error() == parseErr.Error())
+    }
+
+    recorder = new(streamRecorder)
+    err = tinydom.StreamDocument(strings.NewReader(`<a><b/></a><c/>`), recorder)
+    expect(t, "出错之前的事件已经发出", errors.Is(err, tinydom.ErrMultipleRoots) && 4 == len(recorder.events))
+}
+
+const streamCatalog = `<?xml version="1.0"?>
+<catalog xmlns:p="urn:price">
+    <product id="1"><name>Pen</name><p:price>2</p:price></product>
+    <group>
+        <product id="2"><name>Ink</name><product id="3"/></product>
+    </group>
+    <!--end-->
+    <product id="4" xmlns:p="urn:other"><p:price>9</p:price></product>
+</catalog>`
+
+func Test_Stream_构建匹配的子树(t *testing.T) {
+    var ids []string
+    var elems []tinydom.XMLElement
+    err := tinydom.StreamElements(strings.NewReader(streamCatalog), "/catalog/product", func(elem tinydom.XMLElement) error {
+        ids = append(ids, elem.Attribute("id", ""))
+        elems = append(elems, elem)
+        return nil
+    })
+    expect(t, "返回值检测", nil == err)
+    expect(t, "只匹配指定路径", "1,4" == strings.Join(ids, ","))
+
+    first := elems[0]
+    expect(t, "子树与外界断开", nil == first.Parent() && nil == first.NextSibling() && nil == first.PreviousSibling())
+    expect(t, "可以使用导航方法", "Pen" == first.FirstChildElement("name").Text() && "2" == first.SelectOne("p:price").ToElement().Text())
+    expect(t, "继承外层的名字空间", "urn:price" == first.FirstChildElement("p:price").NamespaceURI() && "urn:price" == first.Attribute("xmlns:p", ""))
+    expect(t, "输出子树", `<product id="1" xmlns:p="urn:price"><name>Pen</name><p:price>2</p:price></product>` == first.String())
+    expect(t, "自身的声明优先", "urn:other" == elems[1].FirstChildElement("p:price").NamespaceURI() && 2 == elems[1].AttributeCount())
+
+    doc := tinydom.NewDocument()
+    doc.InsertEndChild(doc.ImportNode(first, true))
+    expect(t, "复制到其他文档", nil == doc.Validate() && "urn:price" == doc.FirstChildElement("product").FirstChildElement("p:price").NamespaceURI())
+}
+
+func Test_Stream_子树的路径(t *testing.T) {
+    collect := func(path string) (string, error) {
+        var ids []string
+        err := tinydom.StreamElements(strings.NewReader(streamCatalog), path, func(elem tinydom.XMLElement) error {
+            ids = append(ids, elem.Name()+elem.Attribute("id", ""))
+            return nil
+        })
+        return strings.Join(ids, ","), err
+    }
+
+    ids, err := collect("//product")
+    expect(t, "任意位置，不进入已经匹配的子树", nil == err && "product1,product2,product4" == ids)
+    ids, _ = collect("//group/product")
+    expect(t, "以路径结尾", "product2" == ids)
+    ids, _ = collect("/catalog/*")
+    expect(t, "通配符", "product1,group,product4" == ids)
+    ids, _ = collect("/catalog")
+    expect(t, "根元素", "catalog" == ids)
+    ids, _ = collect("//price")
+    expect(t, "本地名", "p:price,p:price" == ids)
+    ids, _ = collect("/product")
+    expect(t, "没有匹配", "" == ids)
+
+    for _, path := range []string{"", "catalog", "/", "//", "/catalog//product"} {
+        _, err = collect(path)
+        _, ok := err.(*tinydom.XPathError)
+        expect(t, fmt.Sprintf("%q是错误的路径", path), ok)
+    }
+}
+
+func Test_Stream_子树的提前结束和错误(t *testing.T) {
+    count := 0
+    err := tinydom.StreamElements(strings.NewReader(streamCatalog), "//product", func(elem tinydom.XMLElement) error {
+        count++
+        return tinydom.ErrStopStream
+    })
+    expect(t, "ErrStopStream", nil == err && 1 == count)
+
+    custom := errors.New("custom")
+    err = tinydom.StreamElements(strings.NewReader(streamCatalog), "//product", func(elem tinydom.XMLElement) error {
+        return custom
+    })
+    expect(t, "callback返回的错误", custom == err)
+
+    count = 0
+    err = tinydom.StreamElements(strings.NewReader(`<a><b/><b></a>`), "/a/b", func(elem tinydom.XMLElement) error {
+        count++
+        return nil
+    })
+    expect(t, "语法错误", errors.Is(err, tinydom.ErrSyntax) && 1 == count)
+
+    var text string
+    err = tinydom.StreamElementsWithOptions(strings.NewReader(`<a><b> x </b></a>`), "/a/b", func(elem tinydom.XMLElement) error {
+        text = elem.Text()
+        return nil
+    }, &tinydom.LoadOptions{Whitespace: tinydom.CollapseWhitespace})
+    expect(t, "解析选项", nil == err && " x " == text)
+}
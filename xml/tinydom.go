@@ -4,20 +4,82 @@ Package tinydom	实现了一个简单的XML的DOM树构造工具.
 package tinydom
 
 import (
+    "bufio"
     "bytes"
     "encoding/xml"
     "errors"
+    "fmt"
     "io"
+    "os"
+    "sort"
+    "strings"
+    "time"
+)
+
+const (
+    //  XMLNamespace    是xml前缀固定绑定的名字空间
+    XMLNamespace = "http://www.w3.org/XML/1998/namespace"
+    //  XMLNSNamespace  是xmlns前缀固定绑定的名字空间
+    XMLNSNamespace = "http://www.w3.org/2000/xmlns/"
 )
 
 //  XMLAttribute    是一个元素的属性的接口
+//
+//  Name返回的是带前缀的限定名(如xlink:href)，Prefix和LocalName分别返回其中的前缀和本地名部分，
+//  NamespaceURI则根据所属元素上的xmlns声明解析出前缀绑定的名字空间。没有前缀的属性不属于任何名字空间。
+//
+//  NextAttribute返回同一个元素上的下一个属性，配合XMLElement.FirstAttribute可以按文档顺序遍历属性。
+//
+//  IntValue、FloatValue等方法把属性值转换为对应的类型，转换失败时返回零值；QueryIntValue等方法在转换失败时返回ErrWrongAttributeType。
+//  整数可以使用十进制或者0x开头的十六进制，布尔值可以使用true、false、1、0，时间间隔使用time.ParseDuration的格式。
 type XMLAttribute interface {
     Name() string
+    Prefix() string
+    LocalName() string
+    NamespaceURI() string
     Value() string
     SetValue(string)
+
+    IntValue() int
+    Int64Value() int64
+    UintValue() uint
+    FloatValue() float64
+    BoolValue() bool
+    DurationValue() time.Duration
+    QueryIntValue() (int, error)
+    QueryInt64Value() (int64, error)
+    QueryUintValue() (uint, error)
+    QueryFloatValue() (float64, error)
+    QueryBoolValue() (bool, error)
+    QueryDurationValue() (time.Duration, error)
+    SetIntValue(value int)
+    SetInt64Value(value int64)
+    SetUintValue(value uint)
+    SetFloatValue(value float64)
+    SetBoolValue(value bool)
+    SetDurationValue(value time.Duration)
+
+    NextAttribute() XMLAttribute
 }
 
 //  XMLNode 定义了XML所有节点的基础设施，提供了基本的元素遍历、增删等操作,也提供了逆向转换能力.
+//
+//  String和Bytes返回节点自身及其所有子孙节点的XML文本(outer XML)，输出格式与NewSimplePrinter相同.
+//
+//  Position返回节点在源文本中的位置，只有通过LoadDocument等函数解析得到的节点才有位置信息.
+//
+//  ShallowClone和DeepClone在document中创建节点的副本，document为nil时使用节点自身所在的文档。
+//  ShallowClone只复制节点自身(元素会复制全部属性)，DeepClone还会复制所有子孙节点；副本没有父节点，也不带有位置信息。
+//  副本中用到的、声明在原节点祖先上的名字空间会被声明到副本上，因此前缀的绑定保持不变。
+//  文档节点不能被克隆，此时返回nil，复制整个文档请使用XMLDocument.DeepCopyTo.
+//
+//  InsertEndChild、InsertFirstChild、InsertAfterChild、InsertBeforeChild、ReplaceChild、WrapChild在操作会破坏文档结构时返回nil，并且不修改文档，
+//  比如节点属于其他文档、参照节点不是本节点的子节点、节点将成为它自身的子孙、文档中将出现第二个根元素或者非空白的文本.
+//  ReplaceChild用withThis替换replaceThis，WrapChild把child放到wrapper中，再让wrapper占据child原来的位置；
+//  UnwrapChild用child的全部子节点替换child，MoveChildren把first到last之间的兄弟节点移动到target中afterThis之后，afterThis为nil时移动到最前面.
+//  DeleteChild、UnwrapChild、MoveChildren在操作无效时不修改文档.
+//  每个修改方法都有对应的Try方法，比如TryInsertEndChild、TryMoveChildren，它们执行相同的操作，
+//  在操作无效时返回具体的错误，比如ErrWrongDocument、ErrNotChild、ErrInvalidRange、ErrCycle、ErrMultipleRoots、ErrTextOutsideRoot.
 type XMLNode interface {
     ToElement() XMLElement
     ToText() XMLText
@@ -30,6 +92,7 @@ type XMLNode interface {
     SetValue(newValue string)
 
     GetDocument() XMLDocument
+    Position() Position
 
     NoChildren() bool
     Parent() XMLNode
@@ -41,18 +104,51 @@ type XMLNode interface {
     LastChildElement(name string) XMLElement
     PreviousSiblingElement(name string) XMLElement
     NextSiblingElement(name string) XMLElement
+    FirstChildElementNS(namespaceURI string, localName string) XMLElement
+    LastChildElementNS(namespaceURI string, localName string) XMLElement
+    PreviousSiblingElementNS(namespaceURI string, localName string) XMLElement
+    NextSiblingElementNS(namespaceURI string, localName string) XMLElement
 
     InsertEndChild(node XMLNode) XMLNode
     InsertFirstChild(node XMLNode) XMLNode
     InsertAfterChild(afterThis XMLNode, addThis XMLNode) XMLNode
+    InsertBeforeChild(beforeThis XMLNode, addThis XMLNode) XMLNode
+    ReplaceChild(replaceThis XMLNode, withThis XMLNode) XMLNode
+    WrapChild(child XMLNode, wrapper XMLElement) XMLElement
+    TryInsertEndChild(node XMLNode) error
+    TryInsertFirstChild(node XMLNode) error
+    TryInsertAfterChild(afterThis XMLNode, addThis XMLNode) error
+    TryInsertBeforeChild(beforeThis XMLNode, addThis XMLNode) error
+    TryReplaceChild(replaceThis XMLNode, withThis XMLNode) error
+    TryWrapChild(child XMLNode, wrapper XMLElement) error
+    TryUnwrapChild(child XMLElement) error
+    TryMoveChildren(first XMLNode, last XMLNode, target XMLNode, afterThis XMLNode) error
+    TryDeleteChild(node XMLNode) error
+    UnwrapChild(child XMLElement)
+    MoveChildren(first XMLNode, last XMLNode, target XMLNode, afterThis XMLNode)
     DeleteChildren()
     DeleteChild(node XMLNode)
     Accept(visitor XMLVisitor) bool
 
+    ShallowClone(document XMLDocument) XMLNode
+    DeepClone(document XMLDocument) XMLNode
+
+    Select(expr string) []XMLNode
+    SelectOne(expr string) XMLNode
+    QuerySelector(selector string) XMLElement
+    QuerySelectorAll(selector string) []XMLElement
+
+    String() string
+    Bytes() []byte
+
     //  被迫入侵的接口
     setParent(node XMLNode)
     setPrev(node XMLNode)
     setNext(node XMLNode)
+    setPosition(position Position)
+    shallowClone(document XMLDocument) XMLNode
+    link(prev XMLNode, addThis XMLNode)
+    checkMove(nodes []XMLNode, ignore XMLNode) error
 
     unlink(child XMLNode)
 }
@@ -62,27 +158,88 @@ type XMLNode interface {
 //  Name、SetName其实是Value和SetValue的别名，目的是为了使得接口更加符合直观理解。
 //
 //  Text、SetText的作用是设置<node>与</node>之间的文字，虽然文字都是有XMLText对象来承载的，但是通常来说直接在XMLElement中访问会更加方便。
+//  Text只返回第一个子节点的文本，InnerText则按文档顺序拼接所有子孙文本节点(包括CDATA)，比如<a>x<b>y</b>z</a>的InnerText是xyz。
+//
+//  IntText、FloatText等方法把InnerText转换为对应的类型，元素中没有文本或者不能转换时返回def；QueryIntText等方法通过ErrNoText和ErrWrongTextType区分这两种情况。
+//  SetIntText等方法是SetText的带类型版本。
 //
 //  FindAttribute和ForeachAttribute分别用于查找特定的XML节点的属性和遍历XML属性列表。
 //
 //  Attribute、SetAttribute、DeleteAttribute用于读取和删除属性。
+//
+//  IntAttribute、FloatAttribute等方法读取带类型的属性值，属性不存在或者不能转换时返回def；
+//  QueryIntAttribute等方法则通过ErrNoAttribute和ErrWrongAttributeType区分这两种情况。SetIntAttribute等方法用于设置带类型的属性值。
+//
+//  属性总是按照文档中出现的顺序(或者添加的顺序)保存，ForeachAttribute、FirstAttribute和输出都遵循这个顺序，
+//  InsertAttributeBefore、InsertAttributeAfter、SortAttributes用于调整属性的顺序。
+//
+//  Prefix、LocalName、NamespaceURI用于访问元素的名字空间信息，Name返回的总是带前缀的限定名。
+//  名字空间是根据元素自身及其祖先元素上的xmlns属性动态解析的，因此移动节点后解析结果也会随之变化。
 type XMLElement interface {
     XMLNode
 
     Name() string
     SetName(name string)
 
+    Prefix() string
+    LocalName() string
+    NamespaceURI() string
+    LookupNamespaceURI(prefix string) string
+
     FindAttribute(name string) XMLAttribute
     ForeachAttribute(callback func(attribute XMLAttribute) int) int
+    FirstAttribute() XMLAttribute
 
     AttributeCount() int
     Attribute(name string, def string) string
     SetAttribute(name string, value string) XMLAttribute
+    InsertAttributeBefore(beforeThis XMLAttribute, name string, value string) XMLAttribute
+    InsertAttributeAfter(afterThis XMLAttribute, name string, value string) XMLAttribute
     DeleteAttribute(name string) XMLAttribute
     ClearAttributes()
+    SortAttributes(less func(a XMLAttribute, b XMLAttribute) bool)
+
+    IntAttribute(name string, def int) int
+    Int64Attribute(name string, def int64) int64
+    UintAttribute(name string, def uint) uint
+    FloatAttribute(name string, def float64) float64
+    BoolAttribute(name string, def bool) bool
+    DurationAttribute(name string, def time.Duration) time.Duration
+    QueryIntAttribute(name string) (int, error)
+    QueryInt64Attribute(name string) (int64, error)
+    QueryUintAttribute(name string) (uint, error)
+    QueryFloatAttribute(name string) (float64, error)
+    QueryBoolAttribute(name string) (bool, error)
+    QueryDurationAttribute(name string) (time.Duration, error)
+    SetIntAttribute(name string, value int) XMLAttribute
+    SetInt64Attribute(name string, value int64) XMLAttribute
+    SetUintAttribute(name string, value uint) XMLAttribute
+    SetFloatAttribute(name string, value float64) XMLAttribute
+    SetBoolAttribute(name string, value bool) XMLAttribute
+    SetDurationAttribute(name string, value time.Duration) XMLAttribute
 
     Text() string
     SetText(text string)
+    InnerText() string
+
+    IntText(def int) int
+    Int64Text(def int64) int64
+    UintText(def uint) uint
+    FloatText(def float64) float64
+    BoolText(def bool) bool
+    DurationText(def time.Duration) time.Duration
+    QueryIntText() (int, error)
+    QueryInt64Text() (int64, error)
+    QueryUintText() (uint, error)
+    QueryFloatText() (float64, error)
+    QueryBoolText() (bool, error)
+    QueryDurationText() (time.Duration, error)
+    SetIntText(value int)
+    SetInt64Text(value int64)
+    SetUintText(value uint)
+    SetFloatText(value float64)
+    SetBoolText(value bool)
+    SetDurationText(value time.Duration)
 }
 
 //  XMLText 提供了对XML元素间文本的封装
@@ -92,6 +249,10 @@ type XMLText interface {
     CDATA() bool
 }
 
+//  XMLComment  是XML中的注释
+//
+//  注释中不能出现"--"，也不能以"-"结尾，NewComment、SetComment和SetValue会在这些"-"之后插入一个空格，
+//  比如"a--b-"会被保存为"a- -b- "，以保证输出的注释总是合法的。注释的内容原样输出，不会被转义。
 type XMLComment interface {
     XMLNode
     Comment() string
@@ -102,14 +263,51 @@ type XMLProcInst interface {
     XMLNode
     Target() string
     Instruction() string
+    SetInstruction(inst string)
 }
 
+//  XMLDirective    是XML中的指令，比如<!DOCTYPE ...>，指令的内容原样输出
+//
+//  DocType把DOCTYPE指令解析为DocType，指令不是DOCTYPE时返回ErrNotDocType，不符合语法时返回Err为ErrInvalidDTD的*ParseError。
+//  DocType每次调用都返回新解析的对象，对它的修改需要通过SetDocType写回，SetDocType会重新生成指令的内容。
 type XMLDirective interface {
     XMLNode
+    DocType() (*DocType, error)
+    SetDocType(docType *DocType)
 }
 
+//  XMLDocument 是一个XML文档的根节点
+//
+//  SaveTo和SaveFile用于将文档输出到流或者文件中，默认的输出格式与NewSimplePrinter相同，
+//  可以通过SaveOption指定其他的输出方式。输出过程中遇到的第一个写入错误会被返回。
+//  输出时XML声明中的encoding会与实际使用的字符集保持一致，参考WithEncoding和WithDeclaration。
+//
+//  ImportNode在本文档中创建其他文档中节点的副本，deep为true时复制所有子孙节点，副本需要再通过InsertEndChild等方法插入文档。
+//  DeepCopyTo清空target并把本文档的全部内容复制过去。
+//
+//  RootElement返回文档的根元素，没有时返回nil。SetRootElement用root替换原有的根元素，没有根元素时把root插入到文档的最后，
+//  root必须属于本文档，不满足文档的约束时返回对应的错误，比如ErrWrongDocument。
+//
+//  Declaration返回文档的XML声明，没有时返回nil；SetDeclaration修改XML声明，没有时在文档的最前面插入一个新的声明。
+//
+//  Validate检查文档的结构是否完整：有且只有一个根元素，文档级别没有非空白的文本，所有节点都属于本文档，
+//  节点之间没有环，并且父子、兄弟之间的链接是一致的。文档有效时返回nil，否则返回遇到的第一个问题对应的错误，比如ErrNoRootElement.
 type XMLDocument interface {
     XMLNode
+
+    RootElement() XMLElement
+    SetRootElement(root XMLElement) error
+
+    Declaration() XMLDeclaration
+    SetDeclaration(version string, encoding string, standalone string) XMLDeclaration
+
+    Validate() error
+
+    ImportNode(node XMLNode, deep bool) XMLNode
+    DeepCopyTo(target XMLDocument)
+
+    SaveTo(writer io.Writer, options ...SaveOption) error
+    SaveFile(path string, options ...SaveOption) error
 }
 
 type XMLVisitor interface {
@@ -137,6 +335,14 @@ type XMLHandle interface {
     LastChildElement(name string) XMLHandle
     PreviousSiblingElement(name string) XMLHandle
     NextSiblingElement(name string) XMLHandle
+    FirstChildElementNS(namespaceURI string, localName string) XMLHandle
+    LastChildElementNS(namespaceURI string, localName string) XMLHandle
+    PreviousSiblingElementNS(namespaceURI string, localName string) XMLHandle
+    NextSiblingElementNS(namespaceURI string, localName string) XMLHandle
+    Select(expr string) []XMLNode
+    SelectOne(expr string) XMLHandle
+    QuerySelector(selector string) XMLHandle
+    QuerySelectorAll(selector string) []XMLElement
 
     ToNode() XMLNode
     ToElement() XMLElement
@@ -147,17 +353,134 @@ type XMLHandle interface {
     ToDirective() XMLDirective
 }
 
+//  Position    描述了节点在XML源文本中的位置
+//
+//  Line和Column都是从1开始计数的，Column以字节为单位；Offset是从输入流开始处计算的字节偏移。
+//  Line为0表示位置未知，比如通过NewElement等函数创建的节点。
+type Position struct {
+    Line   int
+    Column int
+    Offset int64
+}
+
+//	IsValid	判断位置信息是否有效
+func (this Position) IsValid() bool {
+    return this.Line > 0
+}
+
+func (this Position) String() string {
+    return fmt.Sprintf("line %d, column %d", this.Line, this.Column)
+}
+
+var (
+    //  ErrSyntax   XML文本不符合语法，此时ParseError.Err是encoding/xml返回的*xml.SyntaxError
+    ErrSyntax = errors.New("XML syntax error")
+    //  ErrNoRootElement    文档中没有根元素
+    ErrNoRootElement = errors.New("XML document missing the root element")
+    //  ErrMultipleRoots    文档中出现了多个根元素
+    ErrMultipleRoots = errors.New("XML document has multiple root elements")
+    //  ErrDuplicateAttribute   同一个元素上出现了同名的属性
+    ErrDuplicateAttribute = errors.New("attributes have the same name")
+    //  ErrTextOutsideRoot  根元素之外出现了文本
+    ErrTextOutsideRoot = errors.New("text should be in the element")
+    //  ErrUnsupportedToken encoding/xml返回了无法识别的记号
+    ErrUnsupportedToken = errors.New("unsupported token type")
+)
+
+//  修改文档时返回的错误
+var (
+    //  ErrNotChild 参数中的节点不是预期的父节点的子节点
+    ErrNotChild = errors.New("node is not a child of this node")
+    //  ErrWrongDocument    节点属于其他文档
+    ErrWrongDocument = errors.New("node belongs to another document")
+    //  ErrInvalidRange last不是first之后的兄弟节点
+    ErrInvalidRange = errors.New("last is not a following sibling of first")
+    //  ErrCycle    操作会使节点成为它自身的子孙节点
+    ErrCycle = errors.New("node would become its own descendant")
+    //  ErrInvalidChild 节点不能成为其他节点的子节点，比如文档节点
+    ErrInvalidChild = errors.New("node cannot be a child")
+    //  ErrBrokenLinks  节点之间的父子或者兄弟关系不一致
+    ErrBrokenLinks = errors.New("inconsistent parent or sibling links")
+)
+
+//  ParseError  是解析XML文档时返回的错误，记录了出错的位置
+//
+//  Err是导致解析失败的原因，可以是ErrNoRootElement等预定义的错误，可以通过errors.Is判断；
+//  对于语法错误，Err是encoding/xml返回的*xml.SyntaxError，可以通过errors.As获取，同时errors.Is(err, ErrSyntax)也成立；
+//  对于读取输入流时发生的错误，Err是输入流返回的原始错误。
+type ParseError struct {
+    Position
+    Msg string
+    Err error
+}
+
+func (this *ParseError) Error() string {
+    return this.Position.String() + ": " + this.Msg
+}
+
+func (this *ParseError) Unwrap() error {
+    return this.Err
+}
+
+//	Is	使得所有的语法错误都可以通过errors.Is(err, ErrSyntax)判断
+func (this *ParseError) Is(target error) bool {
+    if ErrSyntax != target {
+        return false
+    }
+
+    _, ok := this.Err.(*xml.SyntaxError)
+    return ok
+}
+
 //=========================================================
 
 type xmlAttributeImpl struct {
     name  string
     value string
+
+    //  属性所属的元素，用于解析属性前缀所绑定的名字空间
+    owner XMLElement
+
+    //  同一个元素上的属性组成一个单向链表
+    next *xmlAttributeImpl
 }
 
 func (this *xmlAttributeImpl) Name() string {
     return this.name
 }
 
+func (this *xmlAttributeImpl) Prefix() string {
+    prefix, _ := splitName(this.name)
+    return prefix
+}
+
+func (this *xmlAttributeImpl) LocalName() string {
+    _, local := splitName(this.name)
+    return local
+}
+
+func (this *xmlAttributeImpl) NamespaceURI() string {
+    prefix := this.Prefix()
+    switch prefix {
+    case "":
+        //  默认名字空间不作用于属性，只有xmlns声明本身属于xmlns名字空间
+        if "xmlns" == this.name {
+            return XMLNSNamespace
+        }
+        return ""
+    case "xml":
+        return XMLNamespace
+    case "xmlns":
+        return XMLNSNamespace
+    }
+
+    if nil == this.owner {
+        return ""
+    }
+
+    return this.owner.LookupNamespaceURI(prefix)
+}
+
 func (this *xmlAttributeImpl) Value() string {
     return this.value
 }
@@ -166,6 +489,14 @@ func (this *xmlAttributeImpl) SetValue(newValue string) {
     this.value = newValue
 }
 
+func (this *xmlAttributeImpl) NextAttribute() XMLAttribute {
+    if nil == this.next {
+        return nil
+    }
+
+    return this.next
+}
+
 //==================================================================
 
 type xmlNodeImpl struct {
@@ -180,6 +511,8 @@ type xmlNodeImpl struct {
 
     prev XMLNode
     next XMLNode
+
+    position Position
 }
 
 func (this *xmlNodeImpl) getDocument() XMLDocument {
@@ -198,6 +531,14 @@ func (this *xmlNodeImpl) setNext(node XMLNode) {
     this.next = node
 }
 
+func (this *xmlNodeImpl) setPosition(position Position) {
+    this.position = position
+}
+
+func (this *xmlNodeImpl) Position() Position {
+    return this.position
+}
+
 func (this *xmlNodeImpl) ToElement() XMLElement {
     return nil
 }
@@ -320,6 +661,65 @@ func (this *xmlNodeImpl) NextSiblingElement(name string) XMLElement {
     return nil
 }
 
+func (this *xmlNodeImpl) FirstChildElementNS(namespaceURI string, localName string) XMLElement {
+    for item := this.firstChild; nil != item; item = item.NextSibling() {
+        if elem := matchElementNS(item, namespaceURI, localName); nil != elem {
+            return elem
+        }
+    }
+
+    return nil
+}
+
+func (this *xmlNodeImpl) LastChildElementNS(namespaceURI string, localName string) XMLElement {
+    for item := this.lastChild; nil != item; item = item.PreviousSibling() {
+        if elem := matchElementNS(item, namespaceURI, localName); nil != elem {
+            return elem
+        }
+    }
+
+    return nil
+}
+
+func (this *xmlNodeImpl) PreviousSiblingElementNS(namespaceURI string, localName string) XMLElement {
+    for item := this.prev; nil != item; item = item.PreviousSibling() {
+        if elem := matchElementNS(item, namespaceURI, localName); nil != elem {
+            return elem
+        }
+    }
+
+    return nil
+}
+
+func (this *xmlNodeImpl) NextSiblingElementNS(namespaceURI string, localName string) XMLElement {
+    for item := this.next; nil != item; item = item.NextSibling() {
+        if elem := matchElementNS(item, namespaceURI, localName); nil != elem {
+            return elem
+        }
+    }
+
+    return nil
+}
+
+//	matchElementNS	判断node是否是位于namespaceURI名字空间下、本地名为localName的元素.
+//	localName为空时匹配该名字空间下的任意元素
+func matchElementNS(node XMLNode, namespaceURI string, localName string) XMLElement {
+    elem := node.ToElement()
+    if nil == elem {
+        return nil
+    }
+
+    if elem.NamespaceURI() != namespaceURI {
+        return nil
+    }
+
+    if ("" != localName) && (elem.LocalName() != localName) {
+        return nil
+    }
+
+    return elem
+}
+
 func (this *xmlNodeImpl) unlink(child XMLNode) {
     if child == this.firstChild {
         this.firstChild = this.firstChild.NextSibling()
@@ -338,231 +738,745 @@ func (this *xmlNodeImpl) unlink(child XMLNode) {
     }
 
     child.setParent(nil)
+    child.setPrev(nil)
+    child.setNext(nil)
 }
 
-func (this *xmlNodeImpl) InsertEndChild(addThis XMLNode) XMLNode {
-    if addThis.GetDocument() != this.document {
-        return nil
+//	link	把addThis(如果已经在其他位置，先从原来的位置上摘除)链接到prev之后，prev为nil时链接到最前面.
+//	调用者需要保证prev是本节点的子节点，并且已经通过checkInsert检查过addThis
+func (this *xmlNodeImpl) link(prev XMLNode, addThis XMLNode) {
+    if prev == addThis {
+        return
     }
 
     if nil != addThis.Parent() {
         addThis.Parent().unlink(addThis)
     }
 
-    if nil != this.lastChild {
-        this.lastChild.setNext(addThis)
-        addThis.setPrev(this.lastChild)
-        this.lastChild = addThis
-        addThis.setNext(nil)
-    } else {
+    var next XMLNode
+    if nil == prev {
+        next = this.firstChild
         this.firstChild = addThis
-        this.lastChild = addThis
+    } else {
+        next = prev.NextSibling()
+        prev.setNext(addThis)
+    }
 
-        addThis.setPrev(nil)
-        addThis.setNext(nil)
+    if nil == next {
+        this.lastChild = addThis
+    } else {
+        next.setPrev(addThis)
     }
 
+    addThis.setPrev(prev)
+    addThis.setNext(next)
     addThis.setParent(this.impl)
-    return addThis
 }
 
-func (this *xmlNodeImpl) InsertFirstChild(addThis XMLNode) XMLNode {
-    if addThis.GetDocument() != this.document {
-        return nil
-    }
+//	checkInsert	检查addThis能否成为本节点的子节点，ignore是即将被移走的子节点，检查文档级别的约束时不计算在内
+func (this *xmlNodeImpl) checkInsert(addThis XMLNode, ignore XMLNode) error {
+    return this.checkMove([]XMLNode{addThis}, ignore)
+}
 
-    if nil != addThis.Parent() {
-        addThis.Parent().unlink(addThis)
-    }
+//	checkMove	检查nodes能否一起成为本节点的子节点，ignore是即将被移走的子节点，检查文档级别的约束时不计算在内
+func (this *xmlNodeImpl) checkMove(nodes []XMLNode, ignore XMLNode) error {
+    moving := make(map[XMLNode]bool, len(nodes))
+    elements := 0
+    for _, node := range nodes {
+        if nil == node {
+            return ErrInvalidChild
+        }
 
-    if nil != this.firstChild {
-        this.firstChild.setPrev(addThis)
-        addThis.setNext(this.firstChild)
-        this.firstChild = addThis
-        addThis.setPrev(nil)
-    } else {
-        this.firstChild = addThis
-        this.lastChild = addThis
+        if node.GetDocument() != this.document {
+            return ErrWrongDocument
+        }
 
-        addThis.setPrev(nil)
-        addThis.setNext(nil)
-    }
+        if nil != node.ToDocument() {
+            return ErrInvalidChild
+        }
 
-    addThis.setParent(this.impl)
-    return addThis
-}
+        //  不能把节点插入到它自身或者它的子孙节点中
+        for ancestor := this.impl; nil != ancestor; ancestor = ancestor.Parent() {
+            if ancestor == node {
+                return ErrCycle
+            }
+        }
 
-func (this *xmlNodeImpl) InsertAfterChild(afterThis XMLNode, addThis XMLNode) XMLNode {
-    if addThis.GetDocument() != this.document {
-        return nil
+        if text := node.ToText(); (nil != text) && !isDocumentSpace(text) && (this.impl == XMLNode(this.document)) {
+            return ErrTextOutsideRoot
+        }
+
+        if nil != node.ToElement() {
+            elements++
+        }
+        moving[node] = true
     }
 
-    if afterThis.Parent() != this.impl {
+    if this.impl != XMLNode(this.document) {
         return nil
     }
 
-    if afterThis.NextSibling() == nil {
-        return this.InsertEndChild(addThis)
+    //  文档中只能有一个根元素
+    for child := this.firstChild; nil != child; child = child.NextSibling() {
+        if (nil != child.ToElement()) && !moving[child] && (child != ignore) {
+            elements++
+        }
     }
 
-    if nil != addThis.Parent() {
-        addThis.Parent().unlink(addThis)
+    if elements > 1 {
+        return ErrMultipleRoots
     }
+    return nil
+}
 
-    addThis.setPrev(afterThis)
-    addThis.setNext(afterThis.NextSibling())
-    afterThis.NextSibling().setPrev(addThis)
-    afterThis.setNext(addThis)
-    addThis.setParent(this.impl)
+//	isDocumentSpace	判断文本是否是文档级别允许出现的空白
+func isDocumentSpace(text XMLText) bool {
+    return !text.CDATA() && ("" == strings.TrimFunc(text.Value(), isXMLSpace))
+}
 
+func (this *xmlNodeImpl) InsertEndChild(addThis XMLNode) XMLNode {
+    if nil != this.TryInsertEndChild(addThis) {
+        return nil
+    }
     return addThis
 }
 
-func (this *xmlNodeImpl) DeleteChildren() {
-    for nil != this.firstChild {
-        this.DeleteChild(this.firstChild)
+func (this *xmlNodeImpl) InsertFirstChild(addThis XMLNode) XMLNode {
+    if nil != this.TryInsertFirstChild(addThis) {
+        return nil
     }
+    return addThis
+}
 
-    this.firstChild = nil
-    this.lastChild = nil
+func (this *xmlNodeImpl) InsertAfterChild(afterThis XMLNode, addThis XMLNode) XMLNode {
+    if nil != this.TryInsertAfterChild(afterThis, addThis) {
+        return nil
+    }
+    return addThis
 }
 
-func (this *xmlNodeImpl) DeleteChild(node XMLNode) {
-    this.unlink(node)
+func (this *xmlNodeImpl) InsertBeforeChild(beforeThis XMLNode, addThis XMLNode) XMLNode {
+    if nil != this.TryInsertBeforeChild(beforeThis, addThis) {
+        return nil
+    }
+    return addThis
 }
 
-func (this *xmlNodeImpl) Accept(visitor XMLVisitor) bool {
-    return false
+func (this *xmlNodeImpl) ReplaceChild(replaceThis XMLNode, withThis XMLNode) XMLNode {
+    if nil != this.TryReplaceChild(replaceThis, withThis) {
+        return nil
+    }
+    return withThis
 }
 
-//------------------------------------------------------------------
+func (this *xmlNodeImpl) WrapChild(child XMLNode, wrapper XMLElement) XMLElement {
+    if nil != this.TryWrapChild(child, wrapper) {
+        return nil
+    }
+    return wrapper
+}
 
-type xmlElementImpl struct {
-    xmlNodeImpl
+func (this *xmlNodeImpl) TryInsertEndChild(addThis XMLNode) error {
+    if err := this.checkInsert(addThis, nil); nil != err {
+        return err
+    }
 
-    //rootAttribute XMLAttribute
-    attributes map[string]XMLAttribute
+    this.link(this.lastChild, addThis)
+    return nil
 }
 
-func (this *xmlElementImpl) ToElement() XMLElement {
-    return this
+func (this *xmlNodeImpl) TryInsertFirstChild(addThis XMLNode) error {
+    if err := this.checkInsert(addThis, nil); nil != err {
+        return err
+    }
+
+    this.link(nil, addThis)
+    return nil
 }
 
-func (this *xmlElementImpl) Accept(visitor XMLVisitor) bool {
+func (this *xmlNodeImpl) TryInsertAfterChild(afterThis XMLNode, addThis XMLNode) error {
+    if (nil == afterThis) || (afterThis.Parent() != this.impl) {
+        return ErrNotChild
+    }
 
-    if visitor.VisitEnterElement(this) {
-        for node := this.FirstChild(); nil != node; node = node.NextSibling() {
-            if !node.Accept(visitor) {
-                break
-            }
-        }
+    if err := this.checkInsert(addThis, nil); nil != err {
+        return err
     }
 
-    return visitor.VisitExitElement(this)
+    this.link(afterThis, addThis)
+    return nil
 }
 
-func (this *xmlElementImpl) Name() string {
-    return this.Value()
-}
+func (this *xmlNodeImpl) TryInsertBeforeChild(beforeThis XMLNode, addThis XMLNode) error {
+    if (nil == beforeThis) || (beforeThis.Parent() != this.impl) {
+        return ErrNotChild
+    }
 
-func (this *xmlElementImpl) SetName(name string) {
-    this.SetValue(name)
+    if err := this.checkInsert(addThis, nil); nil != err {
+        return err
+    }
+
+    if addThis != beforeThis {
+        this.link(beforeThis.PreviousSibling(), addThis)
+    }
+    return nil
 }
 
-func (this *xmlElementImpl) FindAttribute(name string) XMLAttribute {
-    if nil == this.attributes {
-        return nil
+func (this *xmlNodeImpl) TryReplaceChild(replaceThis XMLNode, withThis XMLNode) error {
+    if (nil == replaceThis) || (replaceThis.Parent() != this.impl) {
+        return ErrNotChild
     }
 
-    attr, ok := this.attributes[name]
-    if !ok {
-        return nil
+    if err := this.checkInsert(withThis, replaceThis); nil != err {
+        return err
     }
 
-    return attr
+    if replaceThis != withThis {
+        this.link(replaceThis.PreviousSibling(), withThis)
+        this.unlink(replaceThis)
+    }
+    return nil
 }
 
-func (this *xmlElementImpl) AttributeCount() int {
-    if nil == this.attributes {
-        return 0
+func (this *xmlNodeImpl) TryWrapChild(child XMLNode, wrapper XMLElement) error {
+    if (nil == child) || (child.Parent() != this.impl) {
+        return ErrNotChild
     }
-    return len(this.attributes)
-}
 
-func (this *xmlElementImpl) Attribute(name string, def string) string {
-    if nil == this.attributes {
-        return def
+    if nil == wrapper {
+        return ErrInvalidChild
     }
 
-    attr, ok := this.attributes[name]
-    if !ok {
-        return def
+    if child == XMLNode(wrapper) {
+        return ErrCycle
     }
 
-    return attr.Value()
+    //  wrapper将占据child的位置
+    if err := this.checkInsert(wrapper, child); nil != err {
+        return err
+    }
+
+    this.link(child.PreviousSibling(), wrapper)
+    wrapper.InsertEndChild(child)
+    return nil
 }
 
-func (this *xmlElementImpl) SetAttribute(name string, value string) XMLAttribute {
-    if nil == this.attributes {
-        this.attributes = make(map[string]XMLAttribute)
-        attr := newAttribute(name, value)
-        this.attributes[name] = attr
-        return attr
+func (this *xmlNodeImpl) TryUnwrapChild(child XMLElement) error {
+    if (nil == child) || (child.Parent() != this.impl) {
+        return ErrNotChild
     }
 
-    attr, ok := this.attributes[name]
-    if ok {
-        attr.SetValue(value)
-        return attr
+    var nodes []XMLNode
+    for node := child.FirstChild(); nil != node; node = node.NextSibling() {
+        nodes = append(nodes, node)
     }
 
-    attr = newAttribute(name, value)
-    this.attributes[name] = attr
-    return attr
-}
+    if err := this.checkMove(nodes, child); nil != err {
+        return err
+    }
 
-func (this *xmlElementImpl) DeleteAttribute(name string) XMLAttribute {
-    attr := this.FindAttribute(name)
-    if nil == attr {
-        return nil
+    for _, node := range nodes {
+        this.link(child.PreviousSibling(), node)
     }
-    delete(this.attributes, name)
-    return attr
+
+    this.unlink(child)
+    return nil
 }
 
-func (this *xmlElementImpl) Text() string {
-    if text := this.FirstChild(); (nil != text) && (nil != text.ToText()) {
-        return text.Value()
+func (this *xmlNodeImpl) TryMoveChildren(first XMLNode, last XMLNode, target XMLNode, afterThis XMLNode) error {
+    if (nil == first) || (nil == last) || (first.Parent() != this.impl) || (last.Parent() != this.impl) {
+        return ErrNotChild
     }
 
-    return ""
-}
+    if nil == target {
+        return ErrNotChild
+    }
 
-func (this *xmlElementImpl) SetText(inText string) {
-    if node := this.FirstChild(); (nil != node) && (nil != node.ToText()) {
-        node.SetValue(inText)
-    } else {
-        theText := NewText(this.getDocument(), inText)
-        this.InsertFirstChild(theText)
+    if target.GetDocument() != this.document {
+        return ErrWrongDocument
     }
-}
 
-func (this *xmlElementImpl) ForeachAttribute(callback func(attribute XMLAttribute) int) int {
-    if nil == this.attributes {
-        return 0
+    if (nil != afterThis) && (afterThis.Parent() != target) {
+        return ErrNotChild
     }
 
-    for _, value := range this.attributes {
-        if ret := callback(value); 0 != ret {
-            return ret
+    var nodes []XMLNode
+    for node := first; node != last; node = node.NextSibling() {
+        if nil == node {
+            return ErrInvalidRange
         }
+        nodes = append(nodes, node)
+    }
+    nodes = append(nodes, last)
+
+    //  afterThis不能是被移动的节点
+    for _, node := range nodes {
+        if node == afterThis {
+            return ErrInvalidRange
+        }
+    }
+
+    if err := target.checkMove(nodes, nil); nil != err {
+        return err
+    }
+
+    prev := afterThis
+    for _, node := range nodes {
+        target.link(prev, node)
+        prev = node
+    }
+    return nil
+}
+
+func (this *xmlNodeImpl) UnwrapChild(child XMLElement) {
+    this.TryUnwrapChild(child)
+}
+
+func (this *xmlNodeImpl) MoveChildren(first XMLNode, last XMLNode, target XMLNode, afterThis XMLNode) {
+    this.TryMoveChildren(first, last, target, afterThis)
+}
+
+func (this *xmlNodeImpl) DeleteChild(node XMLNode) {
+    this.TryDeleteChild(node)
+}
+
+func (this *xmlNodeImpl) DeleteChildren() {
+    for nil != this.firstChild {
+        this.unlink(this.firstChild)
+    }
+}
+
+func (this *xmlNodeImpl) TryDeleteChild(node XMLNode) error {
+    if (nil == node) || (node.Parent() != this.impl) {
+        return ErrNotChild
+    }
+
+    this.unlink(node)
+    return nil
+}
+
+func (this *xmlNodeImpl) Accept(visitor XMLVisitor) bool {
+    return false
+}
+
+func (this *xmlNodeImpl) ShallowClone(document XMLDocument) XMLNode {
+    if nil == document {
+        document = this.document
+    }
+
+    clone := this.impl.shallowClone(document)
+    copyNamespaceDecls(this.impl, clone, false)
+    return clone
+}
+
+func (this *xmlNodeImpl) DeepClone(document XMLDocument) XMLNode {
+    if nil == document {
+        document = this.document
+    }
+
+    clone := deepClone(this.impl, document)
+    copyNamespaceDecls(this.impl, clone, true)
+    return clone
+}
+
+//	deepClone	在document中复制node及其所有子孙节点
+func deepClone(node XMLNode, document XMLDocument) XMLNode {
+    clone := node.shallowClone(document)
+    if nil == clone {
+        return nil
+    }
+
+    for child := node.FirstChild(); nil != child; child = child.NextSibling() {
+        clone.InsertEndChild(deepClone(child, document))
+    }
+    return clone
+}
+
+//	copyNamespaceDecls	把source的子树中用到、但是声明在source的祖先上的名字空间复制到clone上，
+//	这样副本脱离原来的位置之后前缀仍然绑定到相同的名字空间。deep为false时只检查source自身
+func copyNamespaceDecls(source XMLNode, clone XMLNode, deep bool) {
+    root := source.ToElement()
+    if (nil == root) || (nil == clone) {
+        return
+    }
+    target := clone.ToElement()
+
+    use := func(elem XMLElement, prefix string) {
+        if ("xml" == prefix) || ("xmlns" == prefix) {
+            return
+        }
+
+        declName := namespaceDeclName(prefix)
+        if nil != target.FindAttribute(declName) {
+            return
+        }
+
+        //  声明在子树内部的前缀已经随着子树一起复制
+        for node := elem; nil != node; node = node.Parent().ToElement() {
+            if nil != node.FindAttribute(declName) {
+                return
+            }
+            if node == root {
+                break
+            }
+        }
+
+        if uri := root.LookupNamespaceURI(prefix); "" != uri {
+            target.SetAttribute(declName, uri)
+        }
+    }
+
+    var visit func(elem XMLElement)
+    visit = func(elem XMLElement) {
+        use(elem, elem.Prefix())
+        for attr := elem.FirstAttribute(); nil != attr; attr = attr.NextAttribute() {
+            if prefix, _ := splitName(attr.Name()); "" != prefix {
+                use(elem, prefix)
+            }
+        }
+
+        if deep {
+            for child := elem.FirstChildElement(""); nil != child; child = child.NextSiblingElement("") {
+                visit(child)
+            }
+        }
+    }
+    visit(root)
+}
+
+func (this *xmlNodeImpl) shallowClone(document XMLDocument) XMLNode {
+    return nil
+}
+
+func (this *xmlNodeImpl) Select(expr string) []XMLNode {
+    return Select(this.impl, expr)
+}
+
+func (this *xmlNodeImpl) SelectOne(expr string) XMLNode {
+    return SelectOne(this.impl, expr)
+}
+
+func (this *xmlNodeImpl) QuerySelector(selector string) XMLElement {
+    return QuerySelector(this.impl, selector)
+}
+
+func (this *xmlNodeImpl) QuerySelectorAll(selector string) []XMLElement {
+    return QuerySelectorAll(this.impl, selector)
+}
+
+func (this *xmlNodeImpl) String() string {
+    return string(this.Bytes())
+}
+
+func (this *xmlNodeImpl) Bytes() []byte {
+    buf := bytes.NewBufferString("")
+    this.impl.Accept(NewSimplePrinter(buf))
+    return buf.Bytes()
+}
+
+//------------------------------------------------------------------
+
+type xmlElementImpl struct {
+    xmlNodeImpl
+
+    rootAttribute *xmlAttributeImpl
+}
+
+func (this *xmlElementImpl) ToElement() XMLElement {
+    return this
+}
+
+func (this *xmlElementImpl) Accept(visitor XMLVisitor) bool {
+
+    if visitor.VisitEnterElement(this) {
+        for node := this.FirstChild(); nil != node; node = node.NextSibling() {
+            if !node.Accept(visitor) {
+                break
+            }
+        }
+    }
+
+    return visitor.VisitExitElement(this)
+}
+
+func (this *xmlElementImpl) Name() string {
+    return this.Value()
+}
+
+func (this *xmlElementImpl) SetName(name string) {
+    this.SetValue(name)
+}
+
+func (this *xmlElementImpl) Prefix() string {
+    prefix, _ := splitName(this.value)
+    return prefix
+}
+
+func (this *xmlElementImpl) LocalName() string {
+    _, local := splitName(this.value)
+    return local
+}
+
+func (this *xmlElementImpl) NamespaceURI() string {
+    return this.LookupNamespaceURI(this.Prefix())
+}
+
+//	LookupNamespaceURI	从当前元素开始逐级向上查找prefix所绑定的名字空间，prefix为空表示查找默认名字空间.
+//	找不到绑定时返回空字符串
+func (this *xmlElementImpl) LookupNamespaceURI(prefix string) string {
+    switch prefix {
+    case "xml":
+        return XMLNamespace
+    case "xmlns":
+        return XMLNSNamespace
+    }
+
+    declName := namespaceDeclName(prefix)
+    for node := XMLNode(this); nil != node; node = node.Parent() {
+        elem := node.ToElement()
+        if nil == elem {
+            break
+        }
+
+        if attr := elem.FindAttribute(declName); nil != attr {
+            return attr.Value()
+        }
+    }
+
+    return ""
+}
+
+func (this *xmlElementImpl) FindAttribute(name string) XMLAttribute {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return nil
+    }
+
+    return attr
+}
+
+//	findAttribute	查找名为name的属性，同时返回它在链表中的前一个属性
+func (this *xmlElementImpl) findAttribute(name string) (attr *xmlAttributeImpl, prev *xmlAttributeImpl) {
+    for attr = this.rootAttribute; nil != attr; prev, attr = attr, attr.next {
+        if attr.name == name {
+            return attr, prev
+        }
+    }
+
+    return nil, nil
+}
+
+//	ownAttribute	判断attribute是否属于当前元素，是则返回它的实现对象
+func (this *xmlElementImpl) ownAttribute(attribute XMLAttribute) *xmlAttributeImpl {
+    attr, ok := attribute.(*xmlAttributeImpl)
+    if !ok || (nil == attr) || (attr.owner != XMLElement(this)) {
+        return nil
+    }
+
+    return attr
+}
+
+//	unlinkAttribute	将attr从属性链表中摘除，但是不改变它的所属元素
+func (this *xmlElementImpl) unlinkAttribute(attr *xmlAttributeImpl) {
+    if this.rootAttribute == attr {
+        this.rootAttribute = attr.next
+    } else {
+        for prev := this.rootAttribute; nil != prev; prev = prev.next {
+            if prev.next == attr {
+                prev.next = attr.next
+                break
+            }
+        }
+    }
+
+    attr.next = nil
+}
+
+//	shallowClone	在document中创建元素的副本，属性按照原来的顺序全部复制，子节点不复制
+func (this *xmlElementImpl) shallowClone(document XMLDocument) XMLNode {
+    clone := NewElement(document, this.value).(*xmlElementImpl)
+
+    var last *xmlAttributeImpl
+    for item := this.rootAttribute; nil != item; item = item.next {
+        attr := newAttribute(clone, item.name, item.value).(*xmlAttributeImpl)
+        clone.linkAttributeAfter(last, attr)
+        last = attr
+    }
+    return clone
+}
+
+//	linkAttributeAfter	将attr链接到prev之后，prev为nil时链接到链表头部
+func (this *xmlElementImpl) linkAttributeAfter(prev *xmlAttributeImpl, attr *xmlAttributeImpl) {
+    if nil == prev {
+        attr.next = this.rootAttribute
+        this.rootAttribute = attr
+        return
+    }
+
+    attr.next = prev.next
+    prev.next = attr
+}
+
+//	takeAttribute	取出名为name的属性并从链表中摘除，不存在则新建一个
+func (this *xmlElementImpl) takeAttribute(name string, value string) *xmlAttributeImpl {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return newAttribute(this, name, value).(*xmlAttributeImpl)
+    }
+
+    this.unlinkAttribute(attr)
+    attr.value = value
+    return attr
+}
+
+func (this *xmlElementImpl) FirstAttribute() XMLAttribute {
+    if nil == this.rootAttribute {
+        return nil
+    }
+
+    return this.rootAttribute
+}
+
+func (this *xmlElementImpl) AttributeCount() int {
+    count := 0
+    for attr := this.rootAttribute; nil != attr; attr = attr.next {
+        count++
+    }
+
+    return count
+}
+
+func (this *xmlElementImpl) Attribute(name string, def string) string {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return def
+    }
+
+    return attr.value
+}
+
+func (this *xmlElementImpl) SetAttribute(name string, value string) XMLAttribute {
+    attr, _ := this.findAttribute(name)
+    if nil != attr {
+        attr.SetValue(value)
+        return attr
+    }
+
+    //  新的属性总是追加在最后
+    var last *xmlAttributeImpl
+    for item := this.rootAttribute; nil != item; item = item.next {
+        last = item
+    }
+
+    attr = newAttribute(this, name, value).(*xmlAttributeImpl)
+    this.linkAttributeAfter(last, attr)
+    return attr
+}
+
+//	InsertAttributeBefore	在beforeThis之前插入属性，如果同名属性已经存在，则修改它的值并移动到beforeThis之前.
+//	beforeThis不属于当前元素时返回nil
+func (this *xmlElementImpl) InsertAttributeBefore(beforeThis XMLAttribute, name string, value string) XMLAttribute {
+    before := this.ownAttribute(beforeThis)
+    if nil == before {
+        return nil
+    }
+
+    if before.name == name {
+        before.SetValue(value)
+        return before
+    }
+
+    attr := this.takeAttribute(name, value)
+    _, prev := this.findAttribute(before.name)
+    this.linkAttributeAfter(prev, attr)
+    return attr
+}
+
+//	InsertAttributeAfter	在afterThis之后插入属性，如果同名属性已经存在，则修改它的值并移动到afterThis之后.
+//	afterThis不属于当前元素时返回nil
+func (this *xmlElementImpl) InsertAttributeAfter(afterThis XMLAttribute, name string, value string) XMLAttribute {
+    after := this.ownAttribute(afterThis)
+    if nil == after {
+        return nil
+    }
+
+    if after.name == name {
+        after.SetValue(value)
+        return after
+    }
+
+    attr := this.takeAttribute(name, value)
+    this.linkAttributeAfter(after, attr)
+    return attr
+}
+
+func (this *xmlElementImpl) DeleteAttribute(name string) XMLAttribute {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return nil
+    }
+
+    this.unlinkAttribute(attr)
+    attr.owner = nil
+    return attr
+}
+
+func (this *xmlElementImpl) Text() string {
+    if text := this.FirstChild(); (nil != text) && (nil != text.ToText()) {
+        return text.Value()
+    }
+
+    return ""
+}
+
+func (this *xmlElementImpl) SetText(inText string) {
+    if node := this.FirstChild(); (nil != node) && (nil != node.ToText()) {
+        node.SetValue(inText)
+    } else {
+        theText := NewText(this.getDocument(), inText)
+        this.InsertFirstChild(theText)
+    }
+}
+
+func (this *xmlElementImpl) ForeachAttribute(callback func(attribute XMLAttribute) int) int {
+    for attr := this.rootAttribute; nil != attr; {
+        //  先记下下一个属性，这样在回调中删除当前属性也不会影响遍历
+        next := attr.next
+        if ret := callback(attr); 0 != ret {
+            return ret
+        }
+        attr = next
     }
 
     return 0
 }
 
 func (this *xmlElementImpl) ClearAttributes() {
-    this.attributes = nil
+    for attr := this.rootAttribute; nil != attr; {
+        next := attr.next
+        attr.owner = nil
+        attr.next = nil
+        attr = next
+    }
+
+    this.rootAttribute = nil
+}
+
+//	SortAttributes	使用less对属性进行稳定排序，排序结果会体现在遍历和输出中
+func (this *xmlElementImpl) SortAttributes(less func(a XMLAttribute, b XMLAttribute) bool) {
+    var attributes []*xmlAttributeImpl
+    for attr := this.rootAttribute; nil != attr; attr = attr.next {
+        attributes = append(attributes, attr)
+    }
+
+    sort.SliceStable(attributes, func(i, j int) bool {
+        return less(attributes[i], attributes[j])
+    })
+
+    this.rootAttribute = nil
+    for i := len(attributes) - 1; i >= 0; i-- {
+        this.linkAttributeAfter(nil, attributes[i])
+    }
 }
 
 //------------------------------------------------------------------
@@ -580,13 +1494,21 @@ func (this *xmlCommentImpl) Comment() string {
 }
 
 func (this *xmlCommentImpl) SetComment(newComment string) {
-    this.value = newComment
+    this.value = safeComment(newComment)
+}
+
+func (this *xmlCommentImpl) SetValue(newValue string) {
+    this.value = safeComment(newValue)
 }
 
 func (this *xmlCommentImpl) Accept(visitor XMLVisitor) bool {
     return visitor.VisitComment(this)
 }
 
+func (this *xmlCommentImpl) shallowClone(document XMLDocument) XMLNode {
+    return NewComment(document, this.value)
+}
+
 //------------------------------------------------------------------
 
 type xmlProcInstImpl struct {
@@ -610,6 +1532,14 @@ func (this *xmlProcInstImpl) Instruction() string {
     return this.instruction
 }
 
+func (this *xmlProcInstImpl) SetInstruction(inst string) {
+    this.instruction = inst
+}
+
+func (this *xmlProcInstImpl) shallowClone(document XMLDocument) XMLNode {
+    return NewProcInst(document, this.value, this.instruction)
+}
+
 //------------------------------------------------------------------
 
 type xmlDocumentImpl struct {
@@ -633,6 +1563,133 @@ func (this *xmlDocumentImpl) Accept(visitor XMLVisitor) bool {
     return visitor.VisitExitDocument(this)
 }
 
+func (this *xmlDocumentImpl) RootElement() XMLElement {
+    for child := this.firstChild; nil != child; child = child.NextSibling() {
+        if elem := child.ToElement(); nil != elem {
+            return elem
+        }
+    }
+    return nil
+}
+
+func (this *xmlDocumentImpl) SetRootElement(root XMLElement) error {
+    if nil == root {
+        return ErrInvalidChild
+    }
+
+    old := this.RootElement()
+    if err := this.checkInsert(root, old); nil != err {
+        return err
+    }
+
+    if nil == old {
+        this.link(this.lastChild, root)
+    } else if old != root {
+        this.link(old.PreviousSibling(), root)
+        this.unlink(old)
+    }
+    return nil
+}
+
+func (this *xmlDocumentImpl) ImportNode(node XMLNode, deep bool) XMLNode {
+    if nil == node {
+        return nil
+    }
+
+    if deep {
+        return node.DeepClone(this)
+    }
+    return node.ShallowClone(this)
+}
+
+func (this *xmlDocumentImpl) DeepCopyTo(target XMLDocument) {
+    if (nil == target) || (target == XMLDocument(this)) {
+        return
+    }
+
+    target.DeleteChildren()
+    for child := this.firstChild; nil != child; child = child.NextSibling() {
+        target.InsertEndChild(child.DeepClone(target))
+    }
+}
+
+func (this *xmlDocumentImpl) Validate() error {
+    visited := map[XMLNode]bool{this: true}
+    if err := validateChildren(this, this, visited); nil != err {
+        return err
+    }
+
+    elements := 0
+    for child := this.firstChild; nil != child; child = child.NextSibling() {
+        if nil != child.ToElement() {
+            elements++
+        }
+
+        if text := child.ToText(); (nil != text) && !isDocumentSpace(text) {
+            return ErrTextOutsideRoot
+        }
+    }
+
+    switch {
+    case 0 == elements:
+        return ErrNoRootElement
+    case elements > 1:
+        return ErrMultipleRoots
+    }
+    return nil
+}
+
+//	validateChildren	递归地检查node的子节点，visited记录已经访问过的节点，用于发现环
+func validateChildren(document XMLDocument, node XMLNode, visited map[XMLNode]bool) error {
+    var prev XMLNode
+    for child := node.FirstChild(); nil != child; child = child.NextSibling() {
+        if visited[child] {
+            return ErrCycle
+        }
+        visited[child] = true
+
+        if child.GetDocument() != document {
+            return ErrWrongDocument
+        }
+
+        if nil != child.ToDocument() {
+            return ErrInvalidChild
+        }
+
+        if (child.Parent() != node) || (child.PreviousSibling() != prev) {
+            return ErrBrokenLinks
+        }
+
+        if err := validateChildren(document, child, visited); nil != err {
+            return err
+        }
+        prev = child
+    }
+
+    if node.LastChild() != prev {
+        return ErrBrokenLinks
+    }
+    return nil
+}
+
+func (this *xmlDocumentImpl) SaveTo(writer io.Writer, options ...SaveOption) error {
+    return saveTo(this, writer, options)
+}
+
+func (this *xmlDocumentImpl) SaveFile(path string, options ...SaveOption) error {
+    file, err := os.Create(path)
+    if nil != err {
+        return err
+    }
+
+    err = saveTo(this, file, options)
+    if closeErr := file.Close(); nil == err {
+        err = closeErr
+    }
+
+    return err
+}
+
 //------------------------------------------------------------------
 
 type xmlTextImpl struct {
@@ -653,6 +1710,12 @@ func (this *xmlTextImpl) CDATA() bool {
     return this.cdata
 }
 
+func (this *xmlTextImpl) shallowClone(document XMLDocument) XMLNode {
+    clone := NewText(document, this.value)
+    clone.SetCDATA(this.cdata)
+    return clone
+}
+
 //------------------------------------------------------------------
 
 type xmlDirectiveImpl struct {
@@ -667,6 +1730,10 @@ func (this *xmlDirectiveImpl) Accept(visitor XMLVisitor) bool {
     return visitor.VisitDirective(this)
 }
 
+func (this *xmlDirectiveImpl) shallowClone(document XMLDocument) XMLNode {
+    return NewDirective(document, this.value)
+}
+
 //------------------------------------------------------------------
 
 //	NewText	创建一个新的XMLText对象
@@ -683,22 +1750,46 @@ func NewComment(document XMLDocument, comment string) XMLComment {
     node := new(xmlCommentImpl)
     node.impl = node
     node.document = document
-    node.value = comment
+    node.value = safeComment(comment)
     return node
 }
 
+//	safeComment	在连续的"-"之间以及结尾的"-"之后插入空格，使注释中不出现"--"并且不以"-"结尾
+func safeComment(comment string) string {
+    if !strings.Contains(comment, "--") && !strings.HasSuffix(comment, "-") {
+        return comment
+    }
+
+    var buf strings.Builder
+    for i := 0; i < len(comment); i++ {
+        buf.WriteByte(comment[i])
+        if ('-' == comment[i]) && ((i+1 == len(comment)) || ('-' == comment[i+1])) {
+            buf.WriteByte(' ')
+        }
+    }
+    return buf.String()
+}
+
 //	NewElement	创建一个新的XMLElement对象
 func NewElement(document XMLDocument, name string) XMLElement {
     node := new(xmlElementImpl)
     node.impl = node
     node.document = document
     node.value = name
-    node.attributes = make(map[string]XMLAttribute)
     return node
 }
 
-//	NewProcInst	创建一个新的XMLProcInst对象
+//	NewProcInst	创建一个新的XMLProcInst对象，target为xml时返回的对象同时实现了XMLDeclaration
 func NewProcInst(document XMLDocument, target string, inst string) XMLProcInst {
+    if "xml" == target {
+        node := new(xmlDeclarationImpl)
+        node.impl = node
+        node.document = document
+        node.value = target
+        node.instruction = inst
+        return node
+    }
+
     node := new(xmlProcInstImpl)
     node.impl = node
     node.document = document
@@ -717,14 +1808,41 @@ func NewDirective(document XMLDocument, directive string) XMLDirective {
 }
 
 //	newAttribute	创建一个新的XMLAttribute对象.
-//	name和value分别用于指定属性的名称和值
-func newAttribute(name string, value string) XMLAttribute {
+//	owner是属性所属的元素，name和value分别用于指定属性的名称和值
+func newAttribute(owner XMLElement, name string, value string) XMLAttribute {
     attr := new(xmlAttributeImpl)
+    attr.owner = owner
     attr.name = name
     attr.value = value
     return attr
 }
 
+//	splitName	将限定名拆分为前缀和本地名两部分，没有前缀时prefix为空
+func splitName(name string) (prefix string, local string) {
+    if index := strings.IndexByte(name, ':'); index > 0 {
+        return name[:index], name[index+1:]
+    }
+
+    return "", name
+}
+
+//	namespaceDeclName	返回声明prefix所使用的属性名，prefix为空表示默认名字空间
+func namespaceDeclName(prefix string) string {
+    if "" == prefix {
+        return "xmlns"
+    }
+    return "xmlns:" + prefix
+}
+
+//	joinName	将encoding/xml拆分出来的前缀和本地名重新组合为限定名
+func joinName(name xml.Name) string {
+    if "" == name.Space {
+        return name.Local
+    }
+
+    return name.Space + ":" + name.Local
+}
+
 //	NewDocument	创建一个全新的XMLDocument对象
 func NewDocument() XMLDocument {
     node := new(xmlDocumentImpl)
@@ -733,169 +1851,285 @@ func NewDocument() XMLDocument {
     return node
 }
 
-//	LoadDocument	从rd流中读取XML码流并构建成XMLDocument对象
-func LoadDocument(rd io.Reader) (XMLDocument, error) {
-    doc := NewDocument()
-    var parent XMLNode = doc
-    decoder := xml.NewDecoder(rd)
-    var token xml.Token
-    var err error
-    rootElemExist := false
-    for token, err = decoder.Token(); nil == err; token, err = decoder.Token() {
-        switch token.(type) {
-        case xml.StartElement:
-            startElement := token.(xml.StartElement)
-
-            //  一个XML文档只允许有唯一一个根节点
-            if doc == parent {
-                if rootElemExist {
-                    return nil, errors.New("Root element has been exist:" + startElement.Name.Local)
-                }
-
-                //  标记一下根节点已经存在了
-                rootElemExist = true
-            }
+//  xmlRecordReader 记录下encoding/xml解码器读取过的原始字节，用于找回解码器没有提供的信息，比如文本是否是CDATA
+type xmlRecordReader struct {
+    reader io.ByteReader
 
-            node := NewElement(doc, startElement.Name.Local)
-            for _, item := range startElement.Attr {
-                if nil != node.FindAttribute(item.Name.Local) {
-                    return nil, errors.New("Attributes have the same name:" + item.Name.Local)
-                }
-                node.SetAttribute(item.Name.Local, item.Value)
-            }
-            parent.InsertEndChild(node)
-            parent = node
-
-        case xml.EndElement:
-            //endElement := token.(xml.EndElement)
-            parent = parent.Parent()
-        case xml.Comment:
-            comment := token.(xml.Comment)
-            node := NewComment(doc, string(comment))
-            parent.InsertEndChild(node)
-        case xml.Directive:
-            directive := token.(xml.Directive)
-            node := NewDirective(doc, string(directive))
-            parent.InsertEndChild(node)
-        case xml.ProcInst:
-            procInst := token.(xml.ProcInst)
-            node := NewProcInst(doc, procInst.Target, string(procInst.Inst))
-            parent.InsertEndChild(node)
-        case xml.CharData:
-            charData := token.(xml.CharData)
-            shortCharData := bytes.TrimSpace(charData)
-            if (nil != shortCharData) && (len(shortCharData) > 0) {
-                if doc == parent {
-                    return nil, errors.New("Text should be in the element")
-                }
-
-                node := NewText(doc, string(charData))
-                parent.InsertEndChild(node)
-            }
-        default:
-            return nil, errors.New("Unsupported token type")
-        }
+    //  buffer中第一个字节在输入流中的偏移
+    offset int64
+    buffer []byte
+}
+
+func newRecordReader(rd io.Reader) *xmlRecordReader {
+    reader, ok := rd.(io.ByteReader)
+    if !ok {
+        reader = bufio.NewReader(rd)
+    }
+
+    return &xmlRecordReader{reader: reader}
+}
+
+//	ReadByte	encoding/xml的解码器发现输入流实现了io.ByteReader时，只会通过这个方法读取数据
+func (this *xmlRecordReader) ReadByte() (byte, error) {
+    b, err := this.reader.ReadByte()
+    if nil == err {
+        this.buffer = append(this.buffer, b)
+    }
+
+    return b, err
+}
+
+func (this *xmlRecordReader) Read(data []byte) (int, error) {
+    if 0 == len(data) {
+        return 0, nil
+    }
+
+    b, err := this.ReadByte()
+    if nil != err {
+        return 0, err
+    }
+
+    data[0] = b
+    return 1, nil
+}
+
+//	HasPrefix	判断输入流中从offset开始的内容是否以prefix开头
+func (this *xmlRecordReader) HasPrefix(offset int64, prefix string) bool {
+    start := offset - this.offset
+    if (start < 0) || (start > int64(len(this.buffer))) {
+        return false
+    }
+
+    return bytes.HasPrefix(this.buffer[start:], []byte(prefix))
+}
+
+//	Bytes	返回输入流中从start到end之间的内容，这部分内容已经被丢弃时返回nil
+func (this *xmlRecordReader) Bytes(start int64, end int64) []byte {
+    start -= this.offset
+    end -= this.offset
+    if (start < 0) || (end < start) || (end > int64(len(this.buffer))) {
+        return nil
+    }
+
+    return this.buffer[start:end]
+}
+
+//	Discard	丢弃输入流中offset之前的内容
+func (this *xmlRecordReader) Discard(offset int64) {
+    count := offset - this.offset
+    if count <= 0 {
+        return
+    }
+
+    if count > int64(len(this.buffer)) {
+        count = int64(len(this.buffer))
+    }
+
+    this.buffer = this.buffer[:copy(this.buffer, this.buffer[count:])]
+    this.offset += count
+}
+
+//  WhitespaceMode  指定了解析时如何处理文本中的空白
+type WhitespaceMode int
+
+const (
+    //  TrimWhitespace  丢弃只含有空白的文本，其他文本原样保留，这是LoadDocument的默认行为
+    TrimWhitespace WhitespaceMode = iota
+    //  PreserveWhitespace  原样保留所有的文本，包括只含有空白的文本
+    PreserveWhitespace
+    //  CollapseWhitespace  把连续的空白合并为一个空格，文本首尾的空白同样合并而不是去掉，以保留行内元素之间的空格，只含有空白的文本被合并为一个空格
+    CollapseWhitespace
+)
+
+//  LoadOptions 用于定制LoadDocumentWithOptions的解析行为
+type LoadOptions struct {
+    //  Whitespace  文本中空白的处理方式
+    //
+    //  元素上的xml:space="preserve"会使该元素内部的文本总是按照PreserveWhitespace处理，
+    //  xml:space="default"则恢复为这里指定的处理方式。文档级别的空白总是被丢弃，CDATA段总是原样保留。
+    Whitespace WhitespaceMode
+
+    //  Charset 强制使用的字符集，比如GBK，为空时依次根据BOM和XML声明中的encoding识别，都没有时按照UTF-8处理
+    //
+    //  除了UTF-8以外的字符集都会在解析之前转换为UTF-8，因此节点的Position.Offset是转换之后的偏移。
+    //  内置的字符集之外的字符集需要通过RegisterCharset注册，否则解析时返回ErrUnsupportedCharset。
+    Charset string
+}
+
+//	normalizeText	按照mode处理文本中的空白，返回处理后的文本以及该文本是否需要保留
+func normalizeText(text string, mode WhitespaceMode) (string, bool) {
+    switch mode {
+    case PreserveWhitespace:
+        return text, true
+    case CollapseWhitespace:
+        text = collapseSpace(text)
+        return text, "" != text
     }
 
-    if (nil == err) || (io.EOF == err) {
-        //  不能是空文档
-        if nil == doc.FirstChildElement("") {
-            return nil, errors.New("XML document missing the root element")
+    return text, "" != strings.TrimSpace(text)
+}
+
+//	collapseSpace	把text中每一段连续的空白替换为一个空格
+func collapseSpace(text string) string {
+    var builder strings.Builder
+    builder.Grow(len(text))
+
+    space := false
+    for _, r := range text {
+        if isXMLSpace(r) {
+            space = true
+            continue
         }
+        if space {
+            builder.WriteByte(' ')
+            space = false
+        }
+        builder.WriteRune(r)
+    }
+    if space {
+        builder.WriteByte(' ')
+    }
+    return builder.String()
+}
+
+//	isXMLSpace	判断r是否是XML规范中定义的空白字符
+func isXMLSpace(r rune) bool {
+    return (' ' == r) || ('\t' == r) || ('\r' == r) || ('\n' == r)
+}
+
+//	LoadDocument	从rd流中读取XML码流并构建成XMLDocument对象
+//
+//	元素和属性的名字空间前缀以及xmlns声明都会原样保留，因此输出时能够还原原始的前缀。
+//	CDATA段会被读取为独立的、CDATA标记为true的XMLText节点。只含有空白的文本会被丢弃，
+//	如果需要保留空白，请使用LoadDocumentWithOptions
+func LoadDocument(rd io.Reader) (XMLDocument, error) {
+    return LoadDocumentWithOptions(rd, nil)
+}
 
-        return doc, nil
+//	LoadDocumentWithOptions	从rd流中读取XML码流并按照options构建成XMLDocument对象，options为nil时与LoadDocument相同.
+//
+//	解析得到的每个节点都记录了它在源文本中的位置，解析失败时返回的错误总是*ParseError，
+//	可以通过errors.Is与ErrNoRootElement、ErrMultipleRoots、ErrSyntax等预定义的错误进行比较
+func LoadDocumentWithOptions(rd io.Reader, options *LoadOptions) (XMLDocument, error) {
+    doc := NewDocument()
+    parser := &xmlParser{options: options, doc: doc, handler: &xmlTreeBuilder{parent: doc}}
+    if err := parser.parse(rd); nil != err {
+        return nil, err
     }
 
-    return nil, err
+    return doc, nil
+}
+
+//  xmlTreeBuilder  把解析得到的节点依次插入到文档中，用于构建完整的DOM树
+type xmlTreeBuilder struct {
+    parent XMLNode
+}
+
+func (this *xmlTreeBuilder) EnterElement(node XMLElement) error {
+    this.parent.InsertEndChild(node)
+    this.parent = node
+    return nil
+}
+
+func (this *xmlTreeBuilder) ExitElement(node XMLElement) error {
+    this.parent = node.Parent()
+    return nil
+}
+
+func (this *xmlTreeBuilder) ProcInst(node XMLProcInst) error {
+    this.parent.InsertEndChild(node)
+    return nil
+}
+
+func (this *xmlTreeBuilder) Text(node XMLText) error {
+    this.parent.InsertEndChild(node)
+    return nil
+}
+
+func (this *xmlTreeBuilder) Comment(node XMLComment) error {
+    this.parent.InsertEndChild(node)
+    return nil
+}
+
+func (this *xmlTreeBuilder) Directive(node XMLDirective) error {
+    this.parent.InsertEndChild(node)
+    return nil
 }
 
 //------------------------------------------------------------------
 type xmlSimplePrinter struct {
-    writer io.Writer
+    writer *xmlWriter
 }
 
-func NewSimplePrinter(writer io.Writer) XMLVisitor {
+//	NewSimplePrinter	创建一个不带任何格式的XML输出器，除了处理指令之后的换行，所有内容都输出在同一行中
+func NewSimplePrinter(writer io.Writer) XMLPrinter {
     visitor := new(xmlSimplePrinter)
-    visitor.writer = writer
+    visitor.writer = newXMLWriter(writer)
     return visitor
 }
 
+func (this *xmlSimplePrinter) Error() error {
+    return this.writer.Error()
+}
+
 func (this *xmlSimplePrinter) VisitEnterDocument(node XMLDocument) bool {
-    return true
+    return nil == this.writer.Error()
 }
 
 func (this *xmlSimplePrinter) VisitExitDocument(node XMLDocument) bool {
-    return true
+    return nil == this.writer.Error()
 }
 
 func (this *xmlSimplePrinter) VisitEnterElement(node XMLElement) bool {
-    io.WriteString(this.writer, "<")
-    io.WriteString(this.writer, node.Name())
+    this.writer.WriteString("<")
+    this.writer.WriteString(node.Name())
 
     node.ForeachAttribute(func(attribute XMLAttribute) int {
-        io.WriteString(this.writer, ` `)
-        io.WriteString(this.writer, attribute.Name())
-        io.WriteString(this.writer, `="`)
-        xml.EscapeText(this.writer, []byte(attribute.Value()))
-        io.WriteString(this.writer, `"`)
+        this.writer.WriteString(` `)
+        writeAttribute(this.writer, attribute)
         return 0
     })
 
     if node.NoChildren() {
-        io.WriteString(this.writer, "/>")
+        this.writer.WriteString("/>")
 
-        return true
+        return nil == this.writer.Error()
     }
 
-    io.WriteString(this.writer, ">")
-    return true
+    this.writer.WriteString(">")
+    return nil == this.writer.Error()
 }
 
 func (this *xmlSimplePrinter) VisitExitElement(node XMLElement) bool {
     if node.NoChildren() {
-        return true
+        return nil == this.writer.Error()
     }
 
-    io.WriteString(this.writer, "</")
-    io.WriteString(this.writer, node.Name())
-    io.WriteString(this.writer, ">")
-    return true
+    this.writer.WriteString("</")
+    this.writer.WriteString(node.Name())
+    this.writer.WriteString(">")
+    return nil == this.writer.Error()
 }
 
 func (this *xmlSimplePrinter) VisitProcInst(node XMLProcInst) bool {
-    io.WriteString(this.writer, "<?")
-    io.WriteString(this.writer, node.Target())
-    io.WriteString(this.writer, " ")
-    io.WriteString(this.writer, node.Instruction())
-    io.WriteString(this.writer, "?>")
-    io.WriteString(this.writer, "\n")
-    return true
+    writeProcInst(this.writer, node)
+    this.writer.WriteString("\n")
+    return nil == this.writer.Error()
 }
 
 func (this *xmlSimplePrinter) VisitText(node XMLText) bool {
-    if node.CDATA() {
-        io.WriteString(this.writer, "<![CDATA[")
-        io.WriteString(this.writer, node.Value())
-        io.WriteString(this.writer, "]]")
-        return true
-    }
-
-    xml.EscapeText(this.writer, []byte(node.Value()))
-    return true
+    writeText(this.writer, node)
+    return nil == this.writer.Error()
 }
 
 func (this *xmlSimplePrinter) VisitComment(node XMLComment) bool {
-    io.WriteString(this.writer, "<!--")
-    xml.EscapeText(this.writer, []byte(node.Value()))
-    io.WriteString(this.writer, "-->")
-    return true
+    writeComment(this.writer, node)
+    return nil == this.writer.Error()
 }
 
 func (this *xmlSimplePrinter) VisitDirective(node XMLDirective) bool {
-    io.WriteString(this.writer, "<!")
-    xml.EscapeText(this.writer, []byte(node.Value()))
-    io.WriteString(this.writer, ">")
-    return true
+    writeDirective(this.writer, node)
+    return nil == this.writer.Error()
 }
 
 //------------------------------------------------------------------
@@ -982,6 +2216,70 @@ func (this *xmlHandleImpl) NextSiblingElement(name string) XMLHandle {
     return NewHandle(this.node.NextSiblingElement(name))
 }
 
+func (this *xmlHandleImpl) FirstChildElementNS(namespaceURI string, localName string) XMLHandle {
+    if nil == this.node {
+        return this
+    }
+
+    return NewHandle(this.node.FirstChildElementNS(namespaceURI, localName))
+}
+
+func (this *xmlHandleImpl) LastChildElementNS(namespaceURI string, localName string) XMLHandle {
+    if nil == this.node {
+        return this
+    }
+
+    return NewHandle(this.node.LastChildElementNS(namespaceURI, localName))
+}
+
+func (this *xmlHandleImpl) PreviousSiblingElementNS(namespaceURI string, localName string) XMLHandle {
+    if nil == this.node {
+        return this
+    }
+
+    return NewHandle(this.node.PreviousSiblingElementNS(namespaceURI, localName))
+}
+
+func (this *xmlHandleImpl) NextSiblingElementNS(namespaceURI string, localName string) XMLHandle {
+    if nil == this.node {
+        return this
+    }
+
+    return NewHandle(this.node.NextSiblingElementNS(namespaceURI, localName))
+}
+
+func (this *xmlHandleImpl) Select(expr string) []XMLNode {
+    if nil == this.node {
+        return nil
+    }
+
+    return this.node.Select(expr)
+}
+
+func (this *xmlHandleImpl) SelectOne(expr string) XMLHandle {
+    if nil == this.node {
+        return this
+    }
+
+    return NewHandle(this.node.SelectOne(expr))
+}
+
+func (this *xmlHandleImpl) QuerySelector(selector string) XMLHandle {
+    if nil == this.node {
+        return this
+    }
+
+    return NewHandle(this.node.QuerySelector(selector))
+}
+
+func (this *xmlHandleImpl) QuerySelectorAll(selector string) []XMLElement {
+    if nil == this.node {
+        return nil
+    }
+
+    return this.node.QuerySelectorAll(selector)
+}
+
 func (this *xmlHandleImpl) ToNode() XMLNode {
     return this.node
 }
@@ -7,6 +7,8 @@ import (
     "testing"
     "tinydom/xml"
     "bytes"
+    "encoding/xml"
+    "errors"
 )
 
 func expect(t *testing.T, message string, result bool) {
@@ -18,6 +20,14 @@ func expect(t *testing.T, message string, result bool) {
     t.Fail()
 }
 
+//  loadString  解析src，解析失败时测试失败
+func loadString(t *testing.T, src string) tinydom.XMLDocument {
+    doc, err := tinydom.LoadDocument(strings.NewReader(src))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+    return doc
+}
+
 func Test_example1(t *testing.T) {
     xmlstr := `
 	<books>
@@ -417,6 +427,40 @@ func Test_Comment_含有错误注释的XML流(t *testing.T) {
     expect(t, "返回值检测", nil != err)
 }
 
+func Test_Comment_原样输出(t *testing.T) {
+    xml := `<!-- a < b && c > d --><node><!--<elem attr="1"/>--></node>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+    expect(t, "注释内容", " a < b && c > d " == doc.FirstChild().ToComment().Comment())
+    expect(t, "注释不会被转义", xml == doc.String())
+
+    buf := bytes.NewBufferString("")
+    expect(t, "带格式输出", nil == doc.SaveTo(buf, tinydom.WithPrettyPrint(nil)) && "<!-- a < b && c > d -->\n<node>\n    <!--<elem attr=\"1\"/>-->\n</node>\n" == buf.String())
+
+    loaded, err := tinydom.LoadDocument(strings.NewReader(doc.String()))
+    expect(t, "重新加载后不变", nil == err && " a < b && c > d " == loaded.FirstChild().ToComment().Comment())
+}
+
+func Test_Comment_双连字符和结尾的连字符(t *testing.T) {
+    doc := tinydom.NewDocument()
+    root := doc.InsertEndChild(tinydom.NewElement(doc, "root")).ToElement()
+
+    comment := tinydom.NewComment(doc, "a--b---c-")
+    root.InsertEndChild(comment)
+    expect(t, "NewComment插入空格", "a- -b- - -c- " == comment.Comment())
+
+    comment.SetComment("--")
+    expect(t, "SetComment插入空格", "- - " == comment.Comment())
+    comment.SetValue("x-")
+    expect(t, "SetValue插入空格", "x- " == comment.Value())
+    comment.SetComment("a-b")
+    expect(t, "合法的注释保持不变", "a-b" == comment.Comment())
+
+    comment.SetComment("-->")
+    loaded, err := tinydom.LoadDocument(strings.NewReader(doc.String()))
+    expect(t, "输出的注释是合法的", nil == err && "- ->" == loaded.FirstChildElement("root").FirstChild().ToComment().Comment())
+}
+
 func Test_Text_基本功能测试(t *testing.T) {
     xml := "<node>text1<elem1>text2</elem1>\ttext3\n<elem2>\t\n      </elem2></node>"
     doc, err := tinydom.LoadDocument(strings.NewReader(xml))
@@ -591,11 +635,557 @@ func Test_Handle_基本功能测试_Parent(t *testing.T) {
     expect(t, "返回值检测", nil != doc.FirstChild().Parent().ToDocument())
 }
 
-func Test_TODO_Document_通过修改文档破坏xml文档的有效性(t *testing.T) {
+func Test_Node_修改文档_插入与替换(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<root><a/><b/><c/></root>`))
+    expect(t, "返回值检测", nil == err)
+    root := doc.FirstChildElement("root")
+    a := root.FirstChildElement("a")
+    b := root.FirstChildElement("b")
+    c := root.FirstChildElement("c")
+
+    x := tinydom.NewElement(doc, "x")
+    expect(t, "插入到第一个节点之前", x == root.InsertBeforeChild(a, x) && `<root><x/><a/><b/><c/></root>` == root.String())
+    expect(t, "插入到中间", x == root.InsertBeforeChild(c, x) && `<root><a/><b/><x/><c/></root>` == root.String())
+    expect(t, "已经在要求的位置上", x == root.InsertBeforeChild(c, x) && x == root.InsertBeforeChild(x, x) && `<root><a/><b/><x/><c/></root>` == root.String())
+    expect(t, "参照节点不是子节点", nil == root.InsertBeforeChild(doc, tinydom.NewElement(doc, "y")) && nil == root.InsertBeforeChild(nil, x))
+    expect(t, "其他文档的节点", nil == root.InsertBeforeChild(a, tinydom.NewElement(tinydom.NewDocument(), "y")))
+
+    y := tinydom.NewElement(doc, "y")
+    expect(t, "替换", y == root.ReplaceChild(x, y) && `<root><a/><b/><y/><c/></root>` == root.String())
+    expect(t, "被替换的节点被摘除", nil == x.Parent() && nil == x.NextSibling() && nil == x.PreviousSibling())
+    expect(t, "用兄弟节点替换", c == root.ReplaceChild(a, c) && `<root><c/><b/><y/></root>` == root.String())
+    expect(t, "用自身替换", b == root.ReplaceChild(b, b) && `<root><c/><b/><y/></root>` == root.String())
+    expect(t, "被替换的节点不是子节点", nil == root.ReplaceChild(a, x))
+    expect(t, "链表保持一致", y == root.LastChild() && c == root.FirstChild() && b == y.PreviousSibling() && nil == c.PreviousSibling())
+}
+
+func Test_Node_修改文档_包装与移动(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<root><a/>text<b/><c/></root>`))
+    expect(t, "返回值检测", nil == err)
+    root := doc.FirstChildElement("root")
+    a := root.FirstChildElement("a")
+    b := root.FirstChildElement("b")
+
+    wrapper := tinydom.NewElement(doc, "w")
+    expect(t, "包装", wrapper == root.WrapChild(a.NextSibling(), wrapper) && `<root><a/><w>text</w><b/><c/></root>` == root.String())
+    expect(t, "包装的节点不是子节点", nil == root.WrapChild(wrapper.FirstChild(), tinydom.NewElement(doc, "v")))
+    expect(t, "不能用节点包装自身", nil == root.WrapChild(b, b))
+
+    expect(t, "解除包装", nil == root.TryUnwrapChild(wrapper) && `<root><a/>text<b/><c/></root>` == root.String())
+    expect(t, "被解除包装的元素被摘除", nil == wrapper.Parent() && wrapper.NoChildren())
+    expect(t, "解除包装的元素不是子节点", tinydom.ErrNotChild == root.TryUnwrapChild(wrapper) && tinydom.ErrNotChild == root.TryUnwrapChild(nil))
+
+    c := root.FirstChildElement("c")
+    expect(t, "移动到其他元素的最前面", nil == root.TryMoveChildren(a.NextSibling(), b, c, nil) && `<root><a/><c>text<b/></c></root>` == root.String())
+    expect(t, "移动到节点之后", nil == c.TryMoveChildren(c.FirstChild(), c.FirstChild(), root, a) && `<root><a/>text<c><b/></c></root>` == root.String())
+    expect(t, "移动到最后", nil == root.TryMoveChildren(a, a.NextSibling(), root, c) && `<root><c><b/></c><a/>text</root>` == root.String())
+    expect(t, "范围错误", tinydom.ErrInvalidRange == root.TryMoveChildren(a, c, root, nil))
+    expect(t, "afterThis在范围之内", tinydom.ErrInvalidRange == root.TryMoveChildren(c, a, root, a))
+    expect(t, "目标位于范围之内", tinydom.ErrCycle == root.TryMoveChildren(c, a, b, nil))
+    expect(t, "节点不是子节点", tinydom.ErrNotChild == root.TryMoveChildren(b, b, root, nil) && tinydom.ErrNotChild == root.TryMoveChildren(a, a, c, a))
+    expect(t, "其他文档", tinydom.ErrWrongDocument == root.TryMoveChildren(a, a, tinydom.NewDocument(), nil))
+    expect(t, "失败时文档不变", `<root><c><b/></c><a/>text</root>` == root.String())
+}
+
+func Test_Document_通过修改文档破坏xml文档的有效性(t *testing.T) {
+    xml := `<root><a><b/></a><c/></root>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+    expect(t, "加载的文档是有效的", nil == doc.Validate())
+
+    root := doc.FirstChildElement("root")
+    a := root.FirstChildElement("a")
+    b := a.FirstChildElement("b")
+    c := root.FirstChildElement("c")
+
+    expect(t, "第二个根元素", nil == doc.InsertEndChild(tinydom.NewElement(doc, "other")) && nil == doc.InsertFirstChild(tinydom.NewElement(doc, "other")))
+    expect(t, "第二个根元素_移动已有的元素", nil == doc.InsertAfterChild(root, c) && nil == doc.InsertBeforeChild(root, a))
+    expect(t, "文档中的文本", nil == doc.InsertEndChild(tinydom.NewText(doc, "text")))
+    cdata := tinydom.NewText(doc, " ")
+    cdata.SetCDATA(true)
+    expect(t, "文档中的CDATA", nil == doc.InsertEndChild(cdata))
+    expect(t, "文档中的空白", nil != doc.InsertEndChild(tinydom.NewText(doc, "\n")))
+    expect(t, "文档中的注释", nil != doc.InsertFirstChild(tinydom.NewComment(doc, "c")))
+
+    expect(t, "插入到自身", nil == a.InsertEndChild(a))
+    expect(t, "插入到子孙节点", nil == b.InsertEndChild(root) && nil == b.InsertFirstChild(a))
+    expect(t, "插入到子孙节点_指定位置", nil == a.InsertAfterChild(b, root) && nil == a.InsertBeforeChild(b, root))
+    expect(t, "插入到其后", a == root.InsertAfterChild(a, a))
+    expect(t, "文档不能作为子节点", nil == root.InsertEndChild(doc))
+    expect(t, "用子孙替换", nil == a.ReplaceChild(b, root))
+    expect(t, "用祖先包装", nil == a.WrapChild(b, root))
+    expect(t, "用非根元素替换根元素", c == doc.ReplaceChild(root, c) && c == doc.FirstChildElement("") && nil == doc.Validate())
+    expect(t, "恢复", root == doc.ReplaceChild(c, root) && nil != root.InsertEndChild(c))
+
+    expect(t, "删除非子节点", tinydom.ErrNotChild == root.TryDeleteChild(b) && tinydom.ErrNotChild == root.TryDeleteChild(nil))
+    expect(t, "删除子节点", nil == a.TryDeleteChild(b) && nil == b.Parent())
+    a.InsertEndChild(b)
+
+    wrapper := root.WrapChild(a, tinydom.NewElement(doc, "w"))
+    expect(t, "包装", nil != wrapper)
+    expect(t, "解除包装会产生多个根元素", tinydom.ErrMultipleRoots == doc.TryUnwrapChild(root))
+    expect(t, "移动会产生多个根元素", tinydom.ErrMultipleRoots == root.TryMoveChildren(wrapper, c, doc, nil))
+    expect(t, "移动会产生环", tinydom.ErrCycle == root.TryMoveChildren(wrapper, c, b, nil))
+    text := root.InsertEndChild(tinydom.NewText(doc, "text"))
+    expect(t, "移动文本到文档中", tinydom.ErrTextOutsideRoot == root.TryMoveChildren(text, text, doc, nil))
+    expect(t, "移动到其他文档", tinydom.ErrWrongDocument == root.TryMoveChildren(text, text, tinydom.NewDocument(), nil))
+    expect(t, "失败时文档保持不变", "<!--c--><root><w><a><b/></a></w><c/>text</root>\n" == doc.String() && nil == doc.Validate())
+
+    expect(t, "移动注释", nil == doc.TryMoveChildren(doc.FirstChild(), doc.FirstChild(), root, nil))
+    expect(t, "解除包装", nil == root.TryUnwrapChild(wrapper))
+    expect(t, "修改后的文档", "<root><!--c--><a><b/></a><c/>text</root>\n" == doc.String() && nil == doc.Validate())
+
+    doc.DeleteChildren()
+    expect(t, "没有根元素", tinydom.ErrNoRootElement == doc.Validate())
+    expect(t, "新建的文档", tinydom.ErrNoRootElement == tinydom.NewDocument().Validate())
+}
+
+func Test_Document_插入失败时返回具体的错误(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<root><a><b/></a><c/></root>`))
+    expect(t, "返回值检测", nil == err)
+
+    root := doc.FirstChildElement("root")
+    a := root.FirstChildElement("a")
+    b := a.FirstChildElement("b")
+    c := root.FirstChildElement("c")
+
+    expect(t, "第二个根元素", tinydom.ErrMultipleRoots == doc.TryInsertEndChild(tinydom.NewElement(doc, "other")))
+    expect(t, "第二个根元素_移动已有的元素", tinydom.ErrMultipleRoots == doc.TryInsertAfterChild(root, c) && tinydom.ErrMultipleRoots == doc.TryInsertBeforeChild(root, a))
+    expect(t, "文档中的文本", tinydom.ErrTextOutsideRoot == doc.TryInsertFirstChild(tinydom.NewText(doc, "text")))
+    expect(t, "插入到子孙节点", tinydom.ErrCycle == b.TryInsertEndChild(root) && tinydom.ErrCycle == a.TryInsertBeforeChild(b, root))
+    expect(t, "用子孙替换", tinydom.ErrCycle == a.TryReplaceChild(b, root))
+    expect(t, "用祖先包装", tinydom.ErrCycle == a.TryWrapChild(b, root) && tinydom.ErrCycle == a.TryWrapChild(b, b))
+    expect(t, "其他文档的节点", tinydom.ErrWrongDocument == root.TryInsertEndChild(tinydom.NewElement(tinydom.NewDocument(), "x")))
+    expect(t, "参照节点不是子节点", tinydom.ErrNotChild == root.TryInsertAfterChild(b, tinydom.NewElement(doc, "x")) && tinydom.ErrNotChild == root.TryReplaceChild(nil, c))
+    expect(t, "文档不能作为子节点", tinydom.ErrInvalidChild == root.TryInsertEndChild(doc) && tinydom.ErrInvalidChild == root.TryWrapChild(a, nil))
+    expect(t, "失败时文档保持不变", "<root><a><b/></a><c/></root>" == doc.String())
+
+    expect(t, "成功时返回nil", nil == root.TryInsertFirstChild(c) && nil == root.TryWrapChild(a, tinydom.NewElement(doc, "w")))
+    expect(t, "修改后的文档", "<root><c/><w><a><b/></a></w></root>" == doc.String() && nil == doc.Validate())
+
+    root.DeleteChild(b)
+    doc.UnwrapChild(root)
+    root.MoveChildren(c, c, doc, nil)
+    expect(t, "无效的删除和移动不修改文档", "<root><c/><w><a><b/></a></w></root>" == doc.String())
+    a.DeleteChild(b)
+    root.UnwrapChild(root.FirstChildElement("w"))
+    root.MoveChildren(c, c, a, nil)
+    expect(t, "有效的删除和移动", "<root><a><c/></a></root>" == doc.String())
 }
 
 func Test_TODO_Document_各种dom树输出(t *testing.T) {
 }
 
-func Test_TODO_Node_将另外一个文档的node添加到本文档(t *testing.T) {
+func Test_Node_将另外一个文档的node添加到本文档(t *testing.T) {
+    xml := `<library><book id="1" lang="en"><name>The Moon</name><![CDATA[<raw>]]><!--note--></book></library>`
+    src, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+    book := src.FirstChildElement("library").FirstChildElement("book")
+
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<shelf/>`))
+    expect(t, "返回值检测", nil == err)
+    shelf := doc.FirstChildElement("shelf")
+    expect(t, "不能直接插入其他文档的节点", nil == shelf.InsertEndChild(book))
+
+    imported := doc.ImportNode(book, true)
+    expect(t, "导入的节点属于本文档", doc == imported.GetDocument() && nil == imported.Parent())
+    expect(t, "导入的节点可以插入", imported == shelf.InsertEndChild(imported))
+    expect(t, "子孙节点全部被复制", `<shelf><book id="1" lang="en"><name>The Moon</name><![CDATA[<raw>]]><!--note--></book></shelf>` == doc.String())
+    expect(t, "子孙节点也属于本文档", doc == imported.FirstChild().FirstChild().GetDocument())
+    expect(t, "源文档保持不变", book.Parent() == src.FirstChildElement("library") && xml == src.String())
+    expect(t, "副本没有位置信息", !imported.Position().IsValid() && book.Position().IsValid())
+
+    imported.ToElement().SetAttribute("id", "2")
+    expect(t, "属性是独立的副本", "1" == book.Attribute("id", ""))
+
+    shallow := doc.ImportNode(book, false).ToElement()
+    expect(t, "浅复制只复制属性", shallow.NoChildren() && 2 == shallow.AttributeCount() && "lang" == shallow.FirstAttribute().NextAttribute().Name())
+    expect(t, "文档节点不能导入", nil == doc.ImportNode(src, true) && nil == doc.ImportNode(nil, true))
+
+    clone := book.DeepClone(nil)
+    expect(t, "document为nil时复制到同一个文档", src == clone.GetDocument() && book.String() == clone.String())
+    expect(t, "ShallowClone", "<name/>" == book.FirstChild().ShallowClone(doc).String())
+    expect(t, "CDATA标记被复制", book.FirstChild().NextSibling().ShallowClone(nil).ToText().CDATA())
+    procInst := tinydom.NewProcInst(src, "index", "all").ShallowClone(doc).ToProcInst()
+    expect(t, "处理指令", "index" == procInst.Target() && "all" == procInst.Instruction() && doc == procInst.GetDocument())
+    expect(t, "文档节点不能克隆", nil == src.ShallowClone(nil) && nil == src.DeepClone(doc))
+
+    target := tinydom.NewDocument()
+    target.InsertEndChild(tinydom.NewElement(target, "old"))
+    src.DeepCopyTo(target)
+    expect(t, "复制整个文档", xml == target.String() && target == target.FirstChild().GetDocument())
+    src.DeepCopyTo(src)
+    expect(t, "复制到自身不做任何事", xml == src.String())
+}
+
+func Test_Node_复制时保留祖先上的名字空间声明(t *testing.T) {
+    src, err := tinydom.LoadDocument(strings.NewReader(`<r xmlns:a="urn:a" xmlns:b="urn:b" xmlns="urn:d" xmlns:u="urn:unused"><a:item a:k="1"><x b:k="2"/><c:y xmlns:c="urn:c"/></a:item></r>`))
+    expect(t, "返回值检测", nil == err)
+    item := src.FirstChildElement("r").FirstChildElement("a:item")
+
+    doc := tinydom.NewDocument()
+    imported := doc.ImportNode(item, true).ToElement()
+    doc.InsertEndChild(imported)
+    expect(t, "只复制用到的声明", `<a:item a:k="1" xmlns:a="urn:a" xmlns="urn:d" xmlns:b="urn:b"><x b:k="2"/><c:y xmlns:c="urn:c"/></a:item>` == doc.String())
+    expect(t, "名字空间保持不变", "urn:a" == imported.NamespaceURI() && "urn:d" == imported.FirstChildElement("x").NamespaceURI())
+
+    shallow := doc.ImportNode(item, false).ToElement()
+    expect(t, "浅复制只检查元素自身", `<a:item a:k="1" xmlns:a="urn:a"/>` == shallow.String())
+
+    clone := item.FirstChildElement("x").DeepClone(nil)
+    expect(t, "同一个文档中的复制", `<x b:k="2" xmlns="urn:d" xmlns:b="urn:b"/>` == clone.String())
+    expect(t, "源文档保持不变", nil == item.FindAttribute("xmlns:a"))
+}
+
+func Test_Namespace_前缀与名字空间解析(t *testing.T) {
+    xml := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns="urn:default">` +
+        `<soap:Body><item a:id="1" xmlns:a="urn:a"/><a:item xmlns:a="urn:a"/><b:item xmlns:b="urn:b"/></soap:Body></soap:Envelope>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    envelope := doc.FirstChildElement("")
+    expect(t, "限定名", "soap:Envelope" == envelope.Name())
+    expect(t, "前缀", "soap" == envelope.Prefix())
+    expect(t, "本地名", "Envelope" == envelope.LocalName())
+    expect(t, "名字空间", "http://schemas.xmlsoap.org/soap/envelope/" == envelope.NamespaceURI())
+    expect(t, "xmlns声明被保留", "urn:default" == envelope.Attribute("xmlns", ""))
+
+    body := envelope.FirstChildElementNS("http://schemas.xmlsoap.org/soap/envelope/", "Body")
+    expect(t, "按名字空间查找", nil != body)
+
+    item := body.FirstChildElementNS("urn:default", "item")
+    expect(t, "默认名字空间", nil != item)
+    expect(t, "默认名字空间", "" == item.Prefix())
+
+    attr := item.FindAttribute("a:id")
+    expect(t, "属性前缀", "a" == attr.Prefix())
+    expect(t, "属性本地名", "id" == attr.LocalName())
+    expect(t, "属性名字空间", "urn:a" == attr.NamespaceURI())
+    expect(t, "xmlns声明属于xmlns名字空间", tinydom.XMLNSNamespace == item.FindAttribute("xmlns:a").NamespaceURI())
+    expect(t, "xmlns声明的本地名是被声明的前缀", "soap" == envelope.FindAttribute("xmlns:soap").LocalName())
+
+    aItem := body.FirstChildElementNS("urn:a", "item")
+    bItem := body.FirstChildElementNS("urn:b", "item")
+    expect(t, "同名不同名字空间的元素可以区分", nil != aItem && nil != bItem && aItem != bItem)
+    expect(t, "同名不同名字空间的元素可以区分", "a:item" == aItem.Name())
+    expect(t, "同名不同名字空间的元素可以区分", "b:item" == bItem.Name())
+    expect(t, "向后查找", bItem == aItem.NextSiblingElementNS("urn:b", ""))
+    expect(t, "向前查找", item == bItem.PreviousSiblingElementNS("urn:default", "item"))
+    expect(t, "从后查找", bItem == body.LastChildElementNS("urn:b", "item"))
+    expect(t, "查找不存在的名字空间", nil == body.FirstChildElementNS("urn:none", ""))
+
+    handle := tinydom.NewHandle(doc)
+    expect(t, "Handle按名字空间查找", aItem == handle.FirstChildElement("").FirstChildElementNS("http://schemas.xmlsoap.org/soap/envelope/", "Body").FirstChildElementNS("urn:a", "item").ToElement())
+    expect(t, "Handle按名字空间查找", nil == handle.FirstChildElementNS("urn:none", "").FirstChildElementNS("urn:a", "").ToNode())
+}
+
+func Test_Namespace_输出保留前缀(t *testing.T) {
+    xml := `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/"><entry><media:thumbnail url="a.png"/></entry></feed>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    buf := bytes.NewBufferString("")
+    doc.Accept(tinydom.NewSimplePrinter(buf))
+    expect(t, "输出时还原原始前缀", strings.Contains(buf.String(), `<media:thumbnail url="a.png"/>`))
+    expect(t, "输出时还原xmlns声明", strings.Contains(buf.String(), `xmlns:media="http://search.yahoo.com/mrss/"`))
+
+    thumbnail := doc.FirstChildElement("feed").FirstChildElement("entry").FirstChildElement("media:thumbnail")
+    expect(t, "名字空间", "http://search.yahoo.com/mrss/" == thumbnail.NamespaceURI())
+    expect(t, "预定义的xml前缀", tinydom.XMLNamespace == thumbnail.LookupNamespaceURI("xml"))
+    expect(t, "默认名字空间不作用于属性", "" == thumbnail.FindAttribute("url").NamespaceURI())
+
+    //  没有挂到文档中的元素找不到前缀的绑定
+    detached := tinydom.NewElement(doc, "media:thumbnail")
+    expect(t, "未绑定的前缀", "" == detached.NamespaceURI())
+}
+
+func Test_Namespace_结束标签不匹配(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<a:node xmlns:a="urn:a" xmlns:b="urn:a"></b:node>`))
+    expect(t, "返回值检测", nil == doc)
+    expect(t, "返回值检测", nil != err)
+}
+
+func Test_Element_属性顺序(t *testing.T) {
+    xml := `<node z="1" a="2" m="3" b="4" y="5"></node>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    node := doc.FirstChildElement("node")
+    names := func() string {
+        result := ""
+        for attr := node.FirstAttribute(); nil != attr; attr = attr.NextAttribute() {
+            result += attr.Name()
+        }
+        return result
+    }
+
+    expect(t, "按文档顺序遍历", "zamby" == names())
+
+    foreach := ""
+    node.ForeachAttribute(func(attribute tinydom.XMLAttribute) int {
+        foreach += attribute.Name()
+        return 0
+    })
+    expect(t, "ForeachAttribute按文档顺序遍历", "zamby" == foreach)
+
+    //  多次输出的结果完全一致
+    for i := 0; i < 10; i++ {
+        buf := bytes.NewBufferString("")
+        doc.Accept(tinydom.NewSimplePrinter(buf))
+        expect(t, "输出顺序稳定", `<node z="1" a="2" m="3" b="4" y="5"/>` == buf.String())
+    }
+
+    //  新增的属性追加在最后，修改已有属性不改变位置
+    node.SetAttribute("c", "6")
+    node.SetAttribute("a", "(modified)")
+    expect(t, "新增属性追加在最后", "zambyc" == names())
+
+    //  插入与移动
+    m := node.FindAttribute("m")
+    expect(t, "在指定属性之前插入", "x" == node.InsertAttributeBefore(m, "x", "7").Name())
+    expect(t, "在指定属性之前插入", "zaxmbyc" == names())
+    node.InsertAttributeAfter(m, "z", "8")
+    expect(t, "已有的属性被移动到指定属性之后", "axmzbyc" == names())
+    expect(t, "移动的属性值被修改", "8" == node.Attribute("z", ""))
+    node.InsertAttributeBefore(node.FirstAttribute(), "c", "9")
+    expect(t, "移动到最前面", "caxmzby" == names())
+    expect(t, "在自身之前插入同名属性只修改值", m == node.InsertAttributeBefore(m, "m", "10"))
+    expect(t, "在自身之前插入同名属性只修改值", "caxmzby" == names())
+
+    //  不属于当前元素的属性不能作为插入位置
+    other := tinydom.NewElement(doc, "other")
+    otherAttr := other.SetAttribute("o", "1")
+    expect(t, "不属于当前元素的属性", nil == node.InsertAttributeAfter(otherAttr, "p", "1"))
+    expect(t, "不属于当前元素的属性", nil == node.InsertAttributeBefore(nil, "p", "1"))
+
+    //  删除之后的属性不能再作为插入位置
+    deleted := node.DeleteAttribute("x")
+    expect(t, "删除属性", "camzby" == names())
+    expect(t, "删除之后的属性", nil == deleted.NextAttribute())
+    expect(t, "删除之后的属性", nil == node.InsertAttributeAfter(deleted, "p", "1"))
+
+    //  排序
+    node.SortAttributes(func(a tinydom.XMLAttribute, b tinydom.XMLAttribute) bool {
+        return a.Name() < b.Name()
+    })
+    expect(t, "排序", "abcmyz" == names())
+    expect(t, "排序后属性个数不变", 6 == node.AttributeCount())
+
+    buf := bytes.NewBufferString("")
+    doc.Accept(tinydom.NewSimplePrinter(buf))
+    expect(t, "排序体现在输出中", `<node a="(modified)" b="4" c="9" m="10" y="5" z="8"/>` == buf.String())
+}
+
+func Test_Text_CDATA的读取与输出(t *testing.T) {
+    xml := `<content>before<![CDATA[<example>a & b</example>]]>after<empty><![CDATA[  ]]></empty></content>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    content := doc.FirstChildElement("content")
+    before := content.FirstChild().ToText()
+    cdata := before.NextSibling().ToText()
+    after := cdata.NextSibling().ToText()
+    expect(t, "普通文本", nil != before && !before.CDATA() && "before" == before.Value())
+    expect(t, "CDATA是独立的文本节点", nil != cdata && cdata.CDATA())
+    expect(t, "CDATA的内容", "<example>a & b</example>" == cdata.Value())
+    expect(t, "普通文本", nil != after && !after.CDATA() && "after" == after.Value())
+
+    space := content.FirstChildElement("empty").FirstChild()
+    expect(t, "只有空白的CDATA也会被保留", nil != space && space.ToText().CDATA() && "  " == space.Value())
+
+    expect(t, "原样输出", xml == doc.String())
+}
+
+func Test_Text_CDATA内容中含有结束标记(t *testing.T) {
+    doc := tinydom.NewDocument()
+    root := doc.InsertEndChild(tinydom.NewElement(doc, "root"))
+    text := tinydom.NewText(doc, "a]]>b]]>")
+    text.SetCDATA(true)
+    root.InsertEndChild(text)
+
+    result := `<root><![CDATA[a]]]]><![CDATA[>b]]]]><![CDATA[>]]></root>`
+    expect(t, "拆分为多个CDATA段", result == doc.String())
+
+    loaded, err := tinydom.LoadDocument(strings.NewReader(doc.String()))
+    expect(t, "输出的是合法的XML", nil == err)
+
+    value := ""
+    for node := loaded.FirstChildElement("root").FirstChild(); nil != node; node = node.NextSibling() {
+        expect(t, "每一段都是CDATA", node.ToText().CDATA())
+        value += node.Value()
+    }
+    expect(t, "内容保持不变", "a]]>b]]>" == value)
+}
+
+func Test_Text_CDATA出现在根节点之外(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<node></node><![CDATA[text]]>`))
+    expect(t, "返回值检测", nil == doc)
+    expect(t, "返回值检测", nil != err)
+}
+
+func Test_Text_空白处理方式(t *testing.T) {
+    xml := "<root>\n  <p>  Hello   <b>big</b> \t world  </p>\n  <empty>   </empty>\n</root>"
+
+    //  默认的Trim方式与LoadDocument一致
+    doc, err := tinydom.LoadDocumentWithOptions(strings.NewReader(xml), nil)
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+    expect(t, "丢弃只含有空白的文本", "<root><p>  Hello   <b>big</b> \t world  </p><empty/></root>" == doc.String())
+
+    doc, err = tinydom.LoadDocumentWithOptions(strings.NewReader(xml), &tinydom.LoadOptions{Whitespace: tinydom.PreserveWhitespace})
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+    expect(t, "原样保留所有的文本", xml == doc.String())
+
+    doc, err = tinydom.LoadDocumentWithOptions(strings.NewReader(xml), &tinydom.LoadOptions{Whitespace: tinydom.CollapseWhitespace})
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+    expect(t, "合并连续的空白", `<root> <p> Hello <b>big</b> world </p> <empty> </empty> </root>` == doc.String())
+
+    doc, err = tinydom.LoadDocumentWithOptions(strings.NewReader("<p><b>big</b> \n <i>x</i></p>"), &tinydom.LoadOptions{Whitespace: tinydom.CollapseWhitespace})
+    expect(t, "返回值检测", nil == err)
+    expect(t, "保留行内元素之间的空白", `<p><b>big</b> <i>x</i></p>` == doc.String())
+
+    doc, err = tinydom.LoadDocumentWithOptions(strings.NewReader("\n<p/>\n"), &tinydom.LoadOptions{Whitespace: tinydom.CollapseWhitespace})
+    expect(t, "文档级别的空白被丢弃", nil == err && `<p/>` == doc.String())
+}
+
+func Test_Text_xml_space属性(t *testing.T) {
+    xml := `<root><pre xml:space="preserve">  <code>  x  </code> <normal xml:space="default"> <i/> </normal></pre> <after> </after></root>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    result := `<root><pre xml:space="preserve">  <code>  x  </code> <normal xml:space="default"><i/></normal></pre><after/></root>`
+    expect(t, "xml:space按元素生效并被子元素继承", result == doc.String())
+
+    pretty := bytes.NewBufferString("")
+    doc.Accept(tinydom.NewPrettyPrinter(pretty, &tinydom.PrettyPrinterOptions{Indent: " "}))
+    expect(t, "xml:space=preserve的元素不会被重新缩进", strings.Contains(pretty.String(), "\n "+`<pre xml:space="preserve">  <code>  x  </code> <normal`))
+}
+
+func Test_Text_保留空白时文档级别的空白被丢弃(t *testing.T) {
+    xml := "\n<?xml-stylesheet href=\"a.css\"?>\n<root> </root>\n"
+    doc, err := tinydom.LoadDocumentWithOptions(strings.NewReader(xml), &tinydom.LoadOptions{Whitespace: tinydom.PreserveWhitespace})
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+    expect(t, "文档的第一个子节点是处理指令", nil != doc.FirstChild().ToProcInst())
+    expect(t, "处理指令之后是根节点", nil != doc.FirstChild().NextSibling().ToElement())
+    expect(t, "元素中的空白被保留", " " == doc.FirstChildElement("root").Text())
+}
+
+func Test_Position_节点的位置信息(t *testing.T) {
+    xml := "<?xml version=\"1.0\"?>\n<root>\n  <item id=\"1\">text</item>\n  <!--c--></root>"
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil != doc)
+    expect(t, "返回值检测", nil == err)
+
+    expect(t, "处理指令的位置", tinydom.Position{Line: 1, Column: 1, Offset: 0} == doc.FirstChild().Position())
+
+    root := doc.FirstChildElement("root")
+    expect(t, "根节点的位置", tinydom.Position{Line: 2, Column: 1, Offset: 22} == root.Position())
+
+    item := root.FirstChildElement("item")
+    expect(t, "元素的位置", tinydom.Position{Line: 3, Column: 3, Offset: 31} == item.Position())
+    expect(t, "文本的位置", tinydom.Position{Line: 3, Column: 16, Offset: 44} == item.FirstChild().Position())
+    expect(t, "注释的位置", tinydom.Position{Line: 4, Column: 3, Offset: 58} == root.LastChild().Position())
+
+    created := tinydom.NewElement(doc, "created")
+    expect(t, "新建的节点没有位置信息", !created.Position().IsValid())
+}
+
+func Test_Position_解析错误的位置信息(t *testing.T) {
+    _, err := tinydom.LoadDocument(strings.NewReader("<root>\n  <item a=\"1\" a=\"2\"/>\n</root>"))
+    var parseError *tinydom.ParseError
+    expect(t, "解析错误是ParseError", errors.As(err, &parseError))
+    expect(t, "属性同名错误的位置", 2 == parseError.Line && 3 == parseError.Column)
+
+    _, err = tinydom.LoadDocument(strings.NewReader("<root/>\n\n<second/>"))
+    expect(t, "解析错误是ParseError", errors.As(err, &parseError))
+    expect(t, "多个根节点错误的位置", 3 == parseError.Line && 1 == parseError.Column && 9 == parseError.Offset)
+    expect(t, "错误信息中含有位置", strings.HasPrefix(err.Error(), "line 3, column 1: "))
+
+    _, err = tinydom.LoadDocument(strings.NewReader("<root>\n<a></b></root>"))
+    expect(t, "解析错误是ParseError", errors.As(err, &parseError))
+    expect(t, "标签不匹配错误的位置", 2 == parseError.Line && 4 == parseError.Column)
+
+    _, err = tinydom.LoadDocument(strings.NewReader("<root>\n  <a b=></a></root>"))
+    var syntaxError *xml.SyntaxError
+    expect(t, "解析错误是ParseError", errors.As(err, &parseError))
+    expect(t, "包装了encoding/xml的语法错误", errors.As(err, &syntaxError))
+    expect(t, "语法错误的位置", 2 == parseError.Line && 2 == syntaxError.Line)
+}
+
+func Test_Error_错误类型(t *testing.T) {
+    cases := []struct {
+        xml    string
+        target error
+    }{
+        {"", tinydom.ErrNoRootElement},
+        {"<!--only comment-->", tinydom.ErrNoRootElement},
+        {"<a/><b/>", tinydom.ErrMultipleRoots},
+        {`<a x="1" x="2"/>`, tinydom.ErrDuplicateAttribute},
+        {"<a/>text", tinydom.ErrTextOutsideRoot},
+        {"text<a/>", tinydom.ErrTextOutsideRoot},
+        {"<a></b>", tinydom.ErrSyntax},
+        {"<a>", tinydom.ErrSyntax},
+        {"<a/></a>", tinydom.ErrSyntax},
+        {"<a b=></a>", tinydom.ErrSyntax},
+    }
+
+    for _, item := range cases {
+        doc, err := tinydom.LoadDocument(strings.NewReader(item.xml))
+        expect(t, "返回值检测", nil == doc)
+        expect(t, "可以通过errors.Is判断错误类型:"+item.xml, errors.Is(err, item.target))
+
+        for _, other := range cases {
+            if other.target != item.target {
+                expect(t, "不会与其他错误类型混淆:"+item.xml, !errors.Is(err, other.target))
+            }
+        }
+    }
+
+    _, err := tinydom.LoadDocument(strings.NewReader("<a></b>"))
+    var syntaxError *xml.SyntaxError
+    expect(t, "标签不匹配也是encoding/xml的语法错误", errors.As(err, &syntaxError))
+    expect(t, "错误信息", "element <a> closed by </b>" == syntaxError.Msg)
+
+    _, err = tinydom.LoadDocument(&failReader{})
+    expect(t, "读取错误被原样包装", errors.Is(err, errReadFailed))
+    expect(t, "读取错误不是语法错误", !errors.Is(err, tinydom.ErrSyntax))
+}
+
+//  failReader    总是返回读取错误的输入流
+type failReader struct {
+}
+
+var errReadFailed = errors.New("read failed")
+
+func (this *failReader) Read(data []byte) (int, error) {
+    return 0, errReadFailed
+}
+
+func Test_Document_根元素(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<?xml version="1.0"?><!--c--><root><a/></root><!--end-->`))
+    expect(t, "返回值检测", nil == err)
+    expect(t, "根元素", "root" == doc.RootElement().Name())
+    expect(t, "空文档没有根元素", nil == tinydom.NewDocument().RootElement())
+
+    other := tinydom.NewDocument()
+    expect(t, "其他文档的元素", tinydom.ErrWrongDocument == doc.SetRootElement(tinydom.NewElement(other, "x")))
+    expect(t, "nil", tinydom.ErrInvalidChild == doc.SetRootElement(nil))
+
+    expect(t, "替换根元素", nil == doc.SetRootElement(tinydom.NewElement(doc, "new")))
+    expect(t, "保持原来的位置", `<?xml version="1.0"?>`+"\n"+`<!--c--><new/><!--end-->` == doc.String())
+
+    a := tinydom.NewElement(doc, "a")
+    doc.RootElement().InsertEndChild(a)
+    expect(t, "子孙元素成为根元素", nil == doc.SetRootElement(a) && a == doc.RootElement() && nil == doc.Validate())
+    expect(t, "设置相同的根元素", nil == doc.SetRootElement(a) && a == doc.RootElement())
+
+    expect(t, "没有根元素时插入到最后", nil == other.SetRootElement(tinydom.NewElement(other, "x")) && "<x/>" == other.String())
 }
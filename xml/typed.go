@@ -0,0 +1,458 @@
+package tinydom
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+    "time"
+)
+
+//  读取带类型的属性值时返回的错误
+var (
+    //  ErrNoAttribute  元素上没有指定的属性
+    ErrNoAttribute = errors.New("no such attribute")
+    //  ErrWrongAttributeType   属性值不能转换为要求的类型
+    ErrWrongAttributeType = errors.New("attribute has the wrong type")
+)
+
+//  读取带类型的文本时返回的错误
+var (
+    //  ErrNoText   元素中没有文本
+    ErrNoText = errors.New("element has no text")
+    //  ErrWrongTextType    文本不能转换为要求的类型
+    ErrWrongTextType = errors.New("text has the wrong type")
+)
+
+//	parseInt	解析十进制或者0x开头的十六进制整数，前后的空白会被忽略
+func parseInt(str string, bitSize int) (int64, error) {
+    str = strings.TrimFunc(str, isXMLSpace)
+    sign := ""
+    if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+        sign, str = str[:1], str[1:]
+    }
+
+    base := 10
+    if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+        base, str = 16, str[2:]
+
+        //  符号只能出现在0x之前，"0x-5"不是合法的整数
+        if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+            return 0, ErrWrongAttributeType
+        }
+    }
+
+    value, err := strconv.ParseInt(sign+str, base, bitSize)
+    if nil != err {
+        return 0, ErrWrongAttributeType
+    }
+    return value, nil
+}
+
+//	parseUint	解析十进制或者0x开头的十六进制无符号整数，可以带有正号，前后的空白会被忽略
+func parseUint(str string, bitSize int) (uint64, error) {
+    str = strings.TrimFunc(str, isXMLSpace)
+    str = strings.TrimPrefix(str, "+")
+
+    base := 10
+    if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+        base, str = 16, str[2:]
+    }
+
+    value, err := strconv.ParseUint(str, base, bitSize)
+    if nil != err {
+        return 0, ErrWrongAttributeType
+    }
+    return value, nil
+}
+
+//	parseFloat	解析浮点数，前后的空白会被忽略
+func parseFloat(str string) (float64, error) {
+    value, err := strconv.ParseFloat(strings.TrimFunc(str, isXMLSpace), 64)
+    if nil != err {
+        return 0, ErrWrongAttributeType
+    }
+    return value, nil
+}
+
+//	parseBool	解析布尔值，接受true、false、1、0等strconv.ParseBool支持的写法，前后的空白会被忽略
+func parseBool(str string) (bool, error) {
+    value, err := strconv.ParseBool(strings.TrimFunc(str, isXMLSpace))
+    if nil != err {
+        return false, ErrWrongAttributeType
+    }
+    return value, nil
+}
+
+//	parseDuration	解析time.ParseDuration格式的时间间隔，比如1h30m，前后的空白会被忽略
+func parseDuration(str string) (time.Duration, error) {
+    value, err := time.ParseDuration(strings.TrimFunc(str, isXMLSpace))
+    if nil != err {
+        return 0, ErrWrongAttributeType
+    }
+    return value, nil
+}
+
+func formatFloat(value float64) string {
+    return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+//------------------------------------------------------------------
+//  XMLAttribute
+
+func (this *xmlAttributeImpl) QueryIntValue() (int, error) {
+    value, err := parseInt(this.value, strconv.IntSize)
+    return int(value), err
+}
+
+func (this *xmlAttributeImpl) QueryInt64Value() (int64, error) {
+    return parseInt(this.value, 64)
+}
+
+func (this *xmlAttributeImpl) QueryUintValue() (uint, error) {
+    value, err := parseUint(this.value, strconv.IntSize)
+    return uint(value), err
+}
+
+func (this *xmlAttributeImpl) QueryFloatValue() (float64, error) {
+    return parseFloat(this.value)
+}
+
+func (this *xmlAttributeImpl) QueryBoolValue() (bool, error) {
+    return parseBool(this.value)
+}
+
+func (this *xmlAttributeImpl) QueryDurationValue() (time.Duration, error) {
+    return parseDuration(this.value)
+}
+
+func (this *xmlAttributeImpl) IntValue() int {
+    value, _ := this.QueryIntValue()
+    return value
+}
+
+func (this *xmlAttributeImpl) Int64Value() int64 {
+    value, _ := this.QueryInt64Value()
+    return value
+}
+
+func (this *xmlAttributeImpl) UintValue() uint {
+    value, _ := this.QueryUintValue()
+    return value
+}
+
+func (this *xmlAttributeImpl) FloatValue() float64 {
+    value, _ := this.QueryFloatValue()
+    return value
+}
+
+func (this *xmlAttributeImpl) BoolValue() bool {
+    value, _ := this.QueryBoolValue()
+    return value
+}
+
+func (this *xmlAttributeImpl) DurationValue() time.Duration {
+    value, _ := this.QueryDurationValue()
+    return value
+}
+
+func (this *xmlAttributeImpl) SetIntValue(value int) {
+    this.value = strconv.Itoa(value)
+}
+
+func (this *xmlAttributeImpl) SetInt64Value(value int64) {
+    this.value = strconv.FormatInt(value, 10)
+}
+
+func (this *xmlAttributeImpl) SetUintValue(value uint) {
+    this.value = strconv.FormatUint(uint64(value), 10)
+}
+
+func (this *xmlAttributeImpl) SetFloatValue(value float64) {
+    this.value = formatFloat(value)
+}
+
+func (this *xmlAttributeImpl) SetBoolValue(value bool) {
+    this.value = strconv.FormatBool(value)
+}
+
+func (this *xmlAttributeImpl) SetDurationValue(value time.Duration) {
+    this.value = value.String()
+}
+
+//------------------------------------------------------------------
+//  XMLElement的属性
+
+func (this *xmlElementImpl) QueryIntAttribute(name string) (int, error) {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return 0, ErrNoAttribute
+    }
+    return attr.QueryIntValue()
+}
+
+func (this *xmlElementImpl) QueryInt64Attribute(name string) (int64, error) {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return 0, ErrNoAttribute
+    }
+    return attr.QueryInt64Value()
+}
+
+func (this *xmlElementImpl) QueryUintAttribute(name string) (uint, error) {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return 0, ErrNoAttribute
+    }
+    return attr.QueryUintValue()
+}
+
+func (this *xmlElementImpl) QueryFloatAttribute(name string) (float64, error) {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return 0, ErrNoAttribute
+    }
+    return attr.QueryFloatValue()
+}
+
+func (this *xmlElementImpl) QueryBoolAttribute(name string) (bool, error) {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return false, ErrNoAttribute
+    }
+    return attr.QueryBoolValue()
+}
+
+func (this *xmlElementImpl) QueryDurationAttribute(name string) (time.Duration, error) {
+    attr, _ := this.findAttribute(name)
+    if nil == attr {
+        return 0, ErrNoAttribute
+    }
+    return attr.QueryDurationValue()
+}
+
+func (this *xmlElementImpl) IntAttribute(name string, def int) int {
+    if value, err := this.QueryIntAttribute(name); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) Int64Attribute(name string, def int64) int64 {
+    if value, err := this.QueryInt64Attribute(name); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) UintAttribute(name string, def uint) uint {
+    if value, err := this.QueryUintAttribute(name); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) FloatAttribute(name string, def float64) float64 {
+    if value, err := this.QueryFloatAttribute(name); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) BoolAttribute(name string, def bool) bool {
+    if value, err := this.QueryBoolAttribute(name); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) DurationAttribute(name string, def time.Duration) time.Duration {
+    if value, err := this.QueryDurationAttribute(name); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) SetIntAttribute(name string, value int) XMLAttribute {
+    return this.SetAttribute(name, strconv.Itoa(value))
+}
+
+func (this *xmlElementImpl) SetInt64Attribute(name string, value int64) XMLAttribute {
+    return this.SetAttribute(name, strconv.FormatInt(value, 10))
+}
+
+func (this *xmlElementImpl) SetUintAttribute(name string, value uint) XMLAttribute {
+    return this.SetAttribute(name, strconv.FormatUint(uint64(value), 10))
+}
+
+func (this *xmlElementImpl) SetFloatAttribute(name string, value float64) XMLAttribute {
+    return this.SetAttribute(name, formatFloat(value))
+}
+
+func (this *xmlElementImpl) SetBoolAttribute(name string, value bool) XMLAttribute {
+    return this.SetAttribute(name, strconv.FormatBool(value))
+}
+
+func (this *xmlElementImpl) SetDurationAttribute(name string, value time.Duration) XMLAttribute {
+    return this.SetAttribute(name, value.String())
+}
+
+//------------------------------------------------------------------
+//  XMLElement的文本
+
+func (this *xmlElementImpl) InnerText() string {
+    var buf strings.Builder
+    collectText(this, &buf)
+    return buf.String()
+}
+
+//	collectText	按文档顺序拼接node的所有子孙文本节点(包括CDATA)，返回是否找到了文本节点.
+//	InnerText和XPath的string()都使用它计算元素的字符串值
+func collectText(node XMLNode, buf *strings.Builder) bool {
+    found := false
+    for child := node.FirstChild(); nil != child; child = child.NextSibling() {
+        if nil != child.ToText() {
+            buf.WriteString(child.Value())
+            found = true
+        } else if nil != child.ToElement() {
+            found = collectText(child, buf) || found
+        }
+    }
+    return found
+}
+
+//	queryText	返回用于类型转换的文本，也就是InnerText，没有任何文本节点时返回ErrNoText
+func (this *xmlElementImpl) queryText() (string, error) {
+    var buf strings.Builder
+    if !collectText(this, &buf) {
+        return "", ErrNoText
+    }
+    return buf.String(), nil
+}
+
+//	textError	把属性值转换失败的错误换成文本对应的错误
+func textError(err error) error {
+    if ErrWrongAttributeType == err {
+        return ErrWrongTextType
+    }
+    return err
+}
+
+func (this *xmlElementImpl) QueryIntText() (int, error) {
+    text, err := this.queryText()
+    if nil != err {
+        return 0, err
+    }
+    value, err := parseInt(text, strconv.IntSize)
+    return int(value), textError(err)
+}
+
+func (this *xmlElementImpl) QueryInt64Text() (int64, error) {
+    text, err := this.queryText()
+    if nil != err {
+        return 0, err
+    }
+    value, err := parseInt(text, 64)
+    return value, textError(err)
+}
+
+func (this *xmlElementImpl) QueryUintText() (uint, error) {
+    text, err := this.queryText()
+    if nil != err {
+        return 0, err
+    }
+    value, err := parseUint(text, strconv.IntSize)
+    return uint(value), textError(err)
+}
+
+func (this *xmlElementImpl) QueryFloatText() (float64, error) {
+    text, err := this.queryText()
+    if nil != err {
+        return 0, err
+    }
+    value, err := parseFloat(text)
+    return value, textError(err)
+}
+
+func (this *xmlElementImpl) QueryBoolText() (bool, error) {
+    text, err := this.queryText()
+    if nil != err {
+        return false, err
+    }
+    value, err := parseBool(text)
+    return value, textError(err)
+}
+
+func (this *xmlElementImpl) QueryDurationText() (time.Duration, error) {
+    text, err := this.queryText()
+    if nil != err {
+        return 0, err
+    }
+    value, err := parseDuration(text)
+    return value, textError(err)
+}
+
+func (this *xmlElementImpl) IntText(def int) int {
+    if value, err := this.QueryIntText(); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) Int64Text(def int64) int64 {
+    if value, err := this.QueryInt64Text(); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) UintText(def uint) uint {
+    if value, err := this.QueryUintText(); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) FloatText(def float64) float64 {
+    if value, err := this.QueryFloatText(); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) BoolText(def bool) bool {
+    if value, err := this.QueryBoolText(); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) DurationText(def time.Duration) time.Duration {
+    if value, err := this.QueryDurationText(); nil == err {
+        return value
+    }
+    return def
+}
+
+func (this *xmlElementImpl) SetIntText(value int) {
+    this.SetText(strconv.Itoa(value))
+}
+
+func (this *xmlElementImpl) SetInt64Text(value int64) {
+    this.SetText(strconv.FormatInt(value, 10))
+}
+
+func (this *xmlElementImpl) SetUintText(value uint) {
+    this.SetText(strconv.FormatUint(uint64(value), 10))
+}
+
+func (this *xmlElementImpl) SetFloatText(value float64) {
+    this.SetText(formatFloat(value))
+}
+
+func (this *xmlElementImpl) SetBoolText(value bool) {
+    this.SetText(strconv.FormatBool(value))
+}
+
+func (this *xmlElementImpl) SetDurationText(value time.Duration) {
+    this.SetText(value.String())
+}
@@ -0,0 +1,167 @@
+package tinydom_test
+
+import (
+    "math"
+    "strings"
+    "testing"
+    "time"
+    "tinydom/xml"
+)
+
+func Test_Typed_读取带类型的属性(t *testing.T) {
+    xml := `<item count="42" big="-9000000000" mask="0xff" neg="-0x10" size=" 7 " price="12.5" enabled="true" off="0" timeout="1m30s" name="book"/>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+    item := doc.FirstChildElement("item")
+
+    expect(t, "IntAttribute", 42 == item.IntAttribute("count", 0))
+    expect(t, "Int64Attribute", -9000000000 == item.Int64Attribute("big", 0))
+    expect(t, "十六进制", 255 == item.IntAttribute("mask", 0) && 255 == item.UintAttribute("mask", 0) && -16 == item.IntAttribute("neg", 0))
+    expect(t, "忽略前后的空白", 7 == item.IntAttribute("size", 0))
+    expect(t, "FloatAttribute", 12.5 == item.FloatAttribute("price", 0))
+    expect(t, "BoolAttribute", item.BoolAttribute("enabled", false) && !item.BoolAttribute("off", true))
+    expect(t, "DurationAttribute", 90*time.Second == item.DurationAttribute("timeout", 0))
+
+    expect(t, "属性不存在时返回默认值", 3 == item.IntAttribute("none", 3) && item.BoolAttribute("none", true) && 1.5 == item.FloatAttribute("none", 1.5))
+    expect(t, "格式错误时返回默认值", 3 == item.IntAttribute("name", 3) && time.Second == item.DurationAttribute("count", time.Second))
+    expect(t, "超出范围时返回默认值", 3 == item.UintAttribute("neg", 3) && 3 == item.UintAttribute("big", 3))
+}
+
+func Test_Typed_查询带类型的属性(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<item count="42" price="x" enabled="yes"/>`))
+    expect(t, "返回值检测", nil == err)
+    item := doc.FirstChildElement("item")
+
+    value, err := item.QueryIntAttribute("count")
+    expect(t, "QueryIntAttribute", 42 == value && nil == err)
+
+    value, err = item.QueryIntAttribute("none")
+    expect(t, "属性不存在", 0 == value && tinydom.ErrNoAttribute == err)
+
+    price, err := item.QueryFloatAttribute("price")
+    expect(t, "格式错误", 0 == price && tinydom.ErrWrongAttributeType == err)
+
+    _, err = item.QueryBoolAttribute("enabled")
+    expect(t, "布尔值格式错误", tinydom.ErrWrongAttributeType == err)
+
+    _, err = item.QueryInt64Attribute("none")
+    expect(t, "QueryInt64Attribute", tinydom.ErrNoAttribute == err)
+    _, err = item.QueryUintAttribute("price")
+    expect(t, "QueryUintAttribute", tinydom.ErrWrongAttributeType == err)
+    _, err = item.QueryDurationAttribute("count")
+    expect(t, "QueryDurationAttribute", tinydom.ErrWrongAttributeType == err)
+
+    for _, text := range []string{"0x-5", "0x+5", "-0x-5", "+0X+5"} {
+        item.SetAttribute("hex", text)
+        _, err = item.QueryIntAttribute("hex")
+        expect(t, text+"的符号在0x之后", tinydom.ErrWrongAttributeType == err)
+    }
+    item.SetAttribute("hex", "+0x5")
+    value, err = item.QueryIntAttribute("hex")
+    expect(t, "符号在0x之前", 5 == value && nil == err)
+    unsigned, err := item.QueryUintAttribute("hex")
+    expect(t, "无符号整数的正号", 5 == unsigned && nil == err)
+
+    item.SetAttribute("plus", "+5")
+    value, err = item.QueryIntAttribute("plus")
+    unsigned, uerr := item.QueryUintAttribute("plus")
+    expect(t, "有符号和无符号整数都接受正号", 5 == value && nil == err && 5 == unsigned && nil == uerr)
+
+    for _, text := range []string{"-5", "++5", "+-5", "0x+5"} {
+        item.SetAttribute("plus", text)
+        _, err = item.QueryUintAttribute("plus")
+        expect(t, text+"不是无符号整数", tinydom.ErrWrongAttributeType == err)
+    }
+}
+
+func Test_Typed_设置带类型的属性(t *testing.T) {
+    doc := tinydom.NewDocument()
+    item := doc.InsertEndChild(tinydom.NewElement(doc, "item")).ToElement()
+
+    item.SetIntAttribute("a", -1)
+    item.SetInt64Attribute("b", math.MaxInt64)
+    item.SetUintAttribute("c", 7)
+    item.SetFloatAttribute("d", 0.1)
+    item.SetBoolAttribute("e", true)
+    attr := item.SetDurationAttribute("f", 1500*time.Millisecond)
+    expect(t, "设置的属性", `<item a="-1" b="9223372036854775807" c="7" d="0.1" e="true" f="1.5s"/>` == item.String())
+    expect(t, "返回设置的属性", "f" == attr.Name())
+
+    item.SetIntAttribute("a", 2)
+    expect(t, "覆盖已有的属性保持顺序", "a" == item.FirstAttribute().Name() && 2 == item.IntAttribute("a", 0))
+}
+
+func Test_Typed_属性对象的类型转换(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<item n="5" f="2.5" b="false" d="2h" bad="?"/>`))
+    expect(t, "返回值检测", nil == err)
+    item := doc.FirstChildElement("item")
+
+    expect(t, "IntValue", 5 == item.FindAttribute("n").IntValue() && 5 == item.FindAttribute("n").Int64Value() && 5 == item.FindAttribute("n").UintValue())
+    expect(t, "FloatValue", 2.5 == item.FindAttribute("f").FloatValue())
+    expect(t, "BoolValue", !item.FindAttribute("b").BoolValue())
+    expect(t, "DurationValue", 2*time.Hour == item.FindAttribute("d").DurationValue())
+    expect(t, "转换失败时返回零值", 0 == item.FindAttribute("bad").IntValue() && 0 == item.FindAttribute("bad").FloatValue())
+
+    _, err = item.FindAttribute("bad").QueryIntValue()
+    expect(t, "QueryIntValue", tinydom.ErrWrongAttributeType == err)
+    _, err = item.FindAttribute("bad").QueryBoolValue()
+    expect(t, "QueryBoolValue", tinydom.ErrWrongAttributeType == err)
+
+    attr := item.FindAttribute("bad")
+    attr.SetIntValue(10)
+    expect(t, "SetIntValue", "10" == attr.Value())
+    attr.SetInt64Value(-3)
+    expect(t, "SetInt64Value", "-3" == attr.Value())
+    attr.SetUintValue(3)
+    expect(t, "SetUintValue", "3" == attr.Value())
+    attr.SetFloatValue(1e21)
+    expect(t, "SetFloatValue", "1e+21" == attr.Value() && 1e21 == attr.FloatValue())
+    attr.SetBoolValue(true)
+    expect(t, "SetBoolValue", "true" == attr.Value())
+    attr.SetDurationValue(time.Minute)
+    expect(t, "SetDurationValue", "1m0s" == attr.Value() && time.Minute == attr.DurationValue())
+}
+
+func Test_Typed_读取带类型的文本(t *testing.T) {
+    xml := `<item><count> 42 </count><price>12<!-- 大约 -->.5</price><enabled><![CDATA[true]]></enabled><timeout>1m</timeout><name>book</name><empty/><mixed>x<b>y<c>z</c></b><![CDATA[<w>]]></mixed></item>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+    item := doc.FirstChildElement("item")
+
+    expect(t, "IntText", 42 == item.FirstChildElement("count").IntText(0) && 42 == item.FirstChildElement("count").Int64Text(0) && 42 == item.FirstChildElement("count").UintText(0))
+    expect(t, "跨越注释的文本", 12.5 == item.FirstChildElement("price").FloatText(0))
+    expect(t, "CDATA中的文本", item.FirstChildElement("enabled").BoolText(false))
+    expect(t, "DurationText", time.Minute == item.FirstChildElement("timeout").DurationText(0))
+    expect(t, "没有文本时返回默认值", 3 == item.FirstChildElement("empty").IntText(3))
+    expect(t, "格式错误时返回默认值", 3 == item.FirstChildElement("name").IntText(3) && 1.5 == item.FirstChildElement("name").FloatText(1.5))
+
+    _, err = item.FirstChildElement("empty").QueryIntText()
+    expect(t, "QueryIntText没有文本", tinydom.ErrNoText == err)
+    _, err = item.FirstChildElement("name").QueryBoolText()
+    expect(t, "QueryBoolText格式错误", tinydom.ErrWrongTextType == err)
+    value, err := item.FirstChildElement("count").QueryIntText()
+    expect(t, "QueryIntText", 42 == value && nil == err)
+
+    mixed := item.FirstChildElement("mixed")
+    expect(t, "Text只返回第一个文本", "x" == mixed.Text())
+    expect(t, "InnerText拼接所有子孙文本", "xyz<w>" == mixed.InnerText())
+    expect(t, "没有文本的InnerText", "" == item.FirstChildElement("empty").InnerText())
+}
+
+func Test_Typed_设置带类型的文本(t *testing.T) {
+    doc := tinydom.NewDocument()
+    item := doc.InsertEndChild(tinydom.NewElement(doc, "item")).ToElement()
+
+    item.SetIntText(-1)
+    expect(t, "SetIntText", "<item>-1</item>" == item.String())
+    item.SetInt64Text(math.MaxInt64)
+    expect(t, "SetInt64Text", "9223372036854775807" == item.Text())
+    item.SetUintText(7)
+    expect(t, "SetUintText", 7 == item.UintText(0))
+    item.SetFloatText(0.1)
+    expect(t, "SetFloatText", "0.1" == item.Text())
+    item.SetBoolText(false)
+    expect(t, "SetBoolText", "false" == item.Text() && !item.BoolText(true))
+    item.SetDurationText(90 * time.Second)
+    expect(t, "SetDurationText", "1m30s" == item.Text() && nil == item.FirstChild().NextSibling())
+}
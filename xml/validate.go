@@ -0,0 +1,439 @@
+package tinydom
+
+import (
+    "errors"
+    "strings"
+    "unicode/utf8"
+)
+
+//  ErrNoDTD    没有指定DTD，文档中也没有带内部子集的DOCTYPE
+var ErrNoDTD = errors.New("document has no DTD")
+
+//  ValidateOptions 用于控制ValidateDTD的行为
+type ValidateOptions struct {
+    //  ApplyDefaults   为true时，把DTD中声明了默认值(包括#FIXED)而元素上没有出现的属性添加到元素上
+    ApplyDefaults bool
+}
+
+//  ValidationError 是文档中不符合DTD的地方
+type ValidationError struct {
+    //  Position    出错的元素在源文本中的位置，新建的元素没有位置信息
+    Position
+    //  Element 出错的元素
+    Element XMLElement
+    Msg     string
+}
+
+func (this *ValidationError) Error() string {
+    if !this.Position.IsValid() {
+        return this.Msg
+    }
+    return this.Position.String() + ": " + this.Msg
+}
+
+//	ValidateDTD	按照dtd检查文档，返回所有不符合DTD的地方，文档有效时返回空的列表
+//
+//	dtd为nil时使用文档中DOCTYPE的内部子集；同时使用内部子集和外部DTD时，可以把外部DTD的声明追加到内部子集之后，
+//	同名的声明以最先出现的为准，这与XML规范中内部子集优先的规则一致。
+//	检查的内容包括：根元素与DOCTYPE中的名字一致，元素已经声明并且内容符合内容模型，属性已经声明，
+//	#REQUIRED的属性存在，#FIXED的属性值正确，枚举、ID、IDREF(S)、ENTITY(IES)、NMTOKEN(S)类型的属性值合法，ID不重复并且IDREF指向存在的ID。
+//	xmlns以及xmlns:前缀的名字空间声明不需要在DTD中声明。
+//
+//	没有可用的DTD时返回ErrNoDTD，DOCTYPE不符合语法时返回对应的*ParseError，没有根元素时返回ErrNoRootElement
+func ValidateDTD(doc XMLDocument, dtd *DTD, options *ValidateOptions) ([]*ValidationError, error) {
+    if nil == options {
+        options = new(ValidateOptions)
+    }
+
+    docType, err := findDocType(doc)
+    if nil != err {
+        return nil, err
+    }
+
+    if nil == dtd {
+        if (nil == docType) || (nil == docType.Subset) {
+            return nil, ErrNoDTD
+        }
+        dtd = docType.Subset
+    }
+
+    root := doc.RootElement()
+    if nil == root {
+        return nil, ErrNoRootElement
+    }
+
+    validator := &dtdValidator{
+        dtd:        dtd,
+        options:    *options,
+        attributes: make(map[string][]*AttributeDecl),
+        ids:        make(map[string]bool),
+        seen:       make(map[string]bool),
+    }
+
+    if (nil != docType) && (docType.Name != root.Name()) {
+        validator.report(root, "root element "+root.Name()+" does not match DOCTYPE "+docType.Name)
+    }
+
+    //  先收集所有的ID，IDREF可以指向在它之后出现的元素
+    validator.collectIDs(root)
+    validator.validateElement(root)
+    return validator.errors, nil
+}
+
+//	findDocType	返回文档中的DOCTYPE，没有时返回nil
+func findDocType(doc XMLDocument) (*DocType, error) {
+    for child := doc.FirstChild(); nil != child; child = child.NextSibling() {
+        directive := child.ToDirective()
+        if nil == directive {
+            continue
+        }
+
+        docType, err := directive.DocType()
+        if ErrNotDocType == err {
+            continue
+        }
+        return docType, err
+    }
+    return nil, nil
+}
+
+//------------------------------------------------------------------
+
+type dtdValidator struct {
+    dtd     *DTD
+    options ValidateOptions
+
+    //  元素名到属性定义的缓存
+    attributes map[string][]*AttributeDecl
+    //  文档中所有的ID，以及检查过程中已经遇到的ID
+    ids    map[string]bool
+    seen   map[string]bool
+    errors []*ValidationError
+}
+
+func (this *dtdValidator) report(elem XMLElement, msg string) {
+    this.errors = append(this.errors, &ValidationError{Position: elem.Position(), Element: elem, Msg: msg})
+}
+
+//	attributeDecls	返回元素的属性定义
+func (this *dtdValidator) attributeDecls(name string) []*AttributeDecl {
+    decls, ok := this.attributes[name]
+    if !ok {
+        decls = this.dtd.Attributes(name)
+        this.attributes[name] = decls
+    }
+    return decls
+}
+
+func (this *dtdValidator) collectIDs(elem XMLElement) {
+    for _, decl := range this.attributeDecls(elem.Name()) {
+        if "ID" != decl.Type {
+            continue
+        }
+        if attr := elem.FindAttribute(decl.Name); nil != attr {
+            this.ids[normalizeAttributeValue(attr.Value())] = true
+        }
+    }
+
+    for child := elem.FirstChildElement(""); nil != child; child = child.NextSiblingElement("") {
+        this.collectIDs(child)
+    }
+}
+
+func (this *dtdValidator) validateElement(elem XMLElement) {
+    decl := this.dtd.Element(elem.Name())
+    if nil == decl {
+        this.report(elem, "element "+elem.Name()+" is not declared")
+    } else {
+        this.validateContent(elem, decl)
+    }
+
+    this.validateAttributes(elem)
+
+    for child := elem.FirstChildElement(""); nil != child; child = child.NextSiblingElement("") {
+        this.validateElement(child)
+    }
+}
+
+//	validateContent	检查元素的内容是否符合声明，注释和处理指令不影响内容模型
+func (this *dtdValidator) validateContent(elem XMLElement, decl *ElementDecl) {
+    switch decl.Kind {
+    case EmptyContent:
+        if !elem.NoChildren() {
+            this.report(elem, "element "+elem.Name()+" is declared EMPTY but has content")
+        }
+
+    case MixedContent:
+        for child := elem.FirstChildElement(""); nil != child; child = child.NextSiblingElement("") {
+            if !containsString(decl.Mixed, child.Name()) {
+                this.report(child, "element "+child.Name()+" is not allowed in "+elem.Name())
+            }
+        }
+
+    case ElementContent:
+        var names []string
+        for child := elem.FirstChild(); nil != child; child = child.NextSibling() {
+            if text := child.ToText(); (nil != text) && !isDocumentSpace(text) {
+                this.report(elem, "character data is not allowed in element "+elem.Name())
+                return
+            }
+            if childElem := child.ToElement(); nil != childElem {
+                names = append(names, childElem.Name())
+            }
+        }
+
+        model := &ContentParticle{}
+        if nil != decl.Model {
+            model = decl.Model
+        }
+        if !matchContentModel(model, names) {
+            this.report(elem, "content of element "+elem.Name()+" ("+strings.Join(names, ",")+") does not match "+model.String())
+        }
+    }
+}
+
+func (this *dtdValidator) validateAttributes(elem XMLElement) {
+    decls := this.attributeDecls(elem.Name())
+
+    elem.ForeachAttribute(func(attribute XMLAttribute) int {
+        name := attribute.Name()
+        if ("xmlns" == name) || strings.HasPrefix(name, "xmlns:") {
+            return 0
+        }
+
+        for _, decl := range decls {
+            if name == decl.Name {
+                return 0
+            }
+        }
+        this.report(elem, "attribute "+name+" of element "+elem.Name()+" is not declared")
+        return 0
+    })
+
+    for _, decl := range decls {
+        attr := elem.FindAttribute(decl.Name)
+        if nil == attr {
+            switch {
+            case "#REQUIRED" == decl.Default:
+                this.report(elem, "required attribute "+decl.Name+" of element "+elem.Name()+" is missing")
+            case ("#IMPLIED" != decl.Default) && this.options.ApplyDefaults:
+                elem.SetAttribute(decl.Name, decl.Value)
+            }
+            continue
+        }
+
+        this.validateAttributeValue(elem, decl, attr.Value())
+    }
+}
+
+//	validateAttributeValue	检查属性值是否符合属性的类型，除了CDATA之外，属性值在检查之前会被规范化
+func (this *dtdValidator) validateAttributeValue(elem XMLElement, decl *AttributeDecl, value string) {
+    prefix := "attribute " + decl.Name + " of element " + elem.Name()
+    if "CDATA" != decl.Type {
+        value = normalizeAttributeValue(value)
+    }
+
+    if ("#FIXED" == decl.Default) && (value != decl.Value) {
+        this.report(elem, prefix+" must be "+quoteLiteral(decl.Value))
+        return
+    }
+
+    switch decl.Type {
+    case "", "NOTATION":
+        if !containsString(decl.Enum, value) {
+            this.report(elem, prefix+" must be one of ("+strings.Join(decl.Enum, "|")+")")
+        }
+
+    case "ID":
+        if !isXMLName(value) {
+            this.report(elem, prefix+" is not a valid name")
+        } else if this.seen[value] {
+            this.report(elem, "duplicate ID "+value)
+        }
+        this.seen[value] = true
+
+    case "IDREF", "IDREFS", "ENTITY", "ENTITIES":
+        values := strings.Fields(value)
+        if ((1 != len(values)) && !strings.HasSuffix(decl.Type, "S")) || (0 == len(values)) {
+            this.report(elem, prefix+" must be a single name")
+            return
+        }
+
+        for _, value := range values {
+            switch {
+            case !isXMLName(value):
+                this.report(elem, prefix+" is not a valid name")
+            case strings.HasPrefix(decl.Type, "IDREF") && !this.ids[value]:
+                this.report(elem, prefix+" refers to missing ID "+value)
+            case strings.HasPrefix(decl.Type, "ENTITY") && !this.isUnparsedEntity(value):
+                this.report(elem, prefix+" refers to undeclared unparsed entity "+value)
+            }
+        }
+
+    case "NMTOKEN", "NMTOKENS":
+        values := strings.Fields(value)
+        if ("NMTOKEN" == decl.Type) && (1 != len(values)) {
+            this.report(elem, prefix+" must be a single name token")
+            return
+        }
+
+        for _, value := range values {
+            if !isXMLNmtoken(value) {
+                this.report(elem, prefix+" is not a valid name token")
+                return
+            }
+        }
+    }
+}
+
+func (this *dtdValidator) isUnparsedEntity(name string) bool {
+    entity := this.dtd.Entity(name)
+    return (nil != entity) && ("" != entity.NData)
+}
+
+//------------------------------------------------------------------
+
+//	matchContentModel	判断子元素的名字序列是否符合内容模型
+//
+//	内容模型先被编译为非确定的有限自动机，再依次读入子元素的名字，时间与子元素的数量成正比
+func matchContentModel(model *ContentParticle, names []string) bool {
+    automaton := &contentAutomaton{}
+    start, end := automaton.compile(model)
+    return automaton.match(start, end, names)
+}
+
+//  contentState    自动机中的状态，name不为空时读入同名的元素后转移到next，epsilon是不需要读入元素的转移
+type contentState struct {
+    name    string
+    next    int
+    epsilon []int
+}
+
+//  contentAutomaton    由内容模型编译得到的非确定的有限自动机
+type contentAutomaton struct {
+    states []contentState
+}
+
+func (this *contentAutomaton) newState() int {
+    this.states = append(this.states, contentState{next: -1})
+    return len(this.states) - 1
+}
+
+func (this *contentAutomaton) link(from int, to int) {
+    this.states[from].epsilon = append(this.states[from].epsilon, to)
+}
+
+//	compile	把particle编译为自动机的一个片段，返回片段的开始和结束状态
+func (this *contentAutomaton) compile(particle *ContentParticle) (int, int) {
+    start, end := this.compileOnce(particle)
+    if 0 == particle.Occurs {
+        return start, end
+    }
+
+    first, last := this.newState(), this.newState()
+    this.link(first, start)
+    this.link(end, last)
+    if '+' != particle.Occurs {
+        this.link(first, last)
+    }
+    if '?' != particle.Occurs {
+        this.link(end, start)
+    }
+    return first, last
+}
+
+//	compileOnce	不考虑出现次数，编译particle
+func (this *contentAutomaton) compileOnce(particle *ContentParticle) (int, int) {
+    start, end := this.newState(), this.newState()
+    if "" != particle.Name {
+        this.states[start].name = particle.Name
+        this.states[start].next = end
+        return start, end
+    }
+
+    if particle.Choice {
+        for _, child := range particle.Children {
+            childStart, childEnd := this.compile(child)
+            this.link(start, childStart)
+            this.link(childEnd, end)
+        }
+        return start, end
+    }
+
+    last := start
+    for _, child := range particle.Children {
+        childStart, childEnd := this.compile(child)
+        this.link(last, childStart)
+        last = childEnd
+    }
+    this.link(last, end)
+    return start, end
+}
+
+//	match	从start开始依次读入names，判断最后能否到达end
+func (this *contentAutomaton) match(start int, end int, names []string) bool {
+    //  marks记录状态最后一次被加入集合时的步数，这样每一步都不需要清空集合
+    marks := make([]int, len(this.states))
+    step := 1
+    current := this.closure(nil, start, marks, step)
+
+    for _, name := range names {
+        step++
+        var next []int
+        for _, state := range current {
+            if name == this.states[state].name {
+                next = this.closure(next, this.states[state].next, marks, step)
+            }
+        }
+        if 0 == len(next) {
+            return false
+        }
+        current = next
+    }
+
+    return step == marks[end]
+}
+
+//	closure	把state以及通过epsilon转移能够到达的状态加入到set中
+func (this *contentAutomaton) closure(set []int, state int, marks []int, step int) []int {
+    if step == marks[state] {
+        return set
+    }
+
+    marks[state] = step
+    set = append(set, state)
+    for _, next := range this.states[state].epsilon {
+        set = this.closure(set, next, marks, step)
+    }
+    return set
+}
+
+func containsString(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+//	normalizeAttributeValue	去掉两端的空格，并把连续的空格合并为一个
+func normalizeAttributeValue(value string) string {
+    return strings.Join(strings.Fields(value), " ")
+}
+
+//	isXMLName	判断字符串是否符合XML的Name
+func isXMLName(name string) bool {
+    first, _ := utf8.DecodeRuneInString(name)
+    return ("" != name) && isNameStartChar(first) && isXMLNmtoken(name)
+}
+
+//	isXMLNmtoken	判断字符串是否符合XML的Nmtoken
+func isXMLNmtoken(token string) bool {
+    for _, r := range token {
+        if !isNameChar(r) {
+            return false
+        }
+    }
+    return "" != token
+}
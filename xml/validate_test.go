@@ -0,0 +1,158 @@
+package tinydom_test
+
+import (
+    "bytes"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+    "tinydom/xml"
+)
+
+const validateLibrary = `<!DOCTYPE library [
+    <!ELEMENT library (book+,(magazine|journal)*,note?)>
+    <!ELEMENT book (title,author*)>
+    <!ELEMENT title (#PCDATA)>
+    <!ELEMENT author (#PCDATA|em)*>
+    <!ELEMENT em (#PCDATA)>
+    <!ELEMENT magazine EMPTY>
+    <!ELEMENT journal ANY>
+    <!ELEMENT note (#PCDATA)>
+    <!ATTLIST book id ID #REQUIRED
+                   lang (en|zh) "en"
+                   format CDATA #FIXED "paper"
+                   refs IDREFS #IMPLIED
+                   tags NMTOKENS #IMPLIED>
+    <!ATTLIST magazine cover ENTITY #IMPLIED issue NMTOKEN #IMPLIED>
+    <!ENTITY cover1 SYSTEM "cover1.png" NDATA png>
+    <!ENTITY text "plain">
+    <!NOTATION png SYSTEM "image/png">
+]>
+`
+
+func Test_Validate_有效的文档(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(validateLibrary + `<library xmlns:x="urn:x">
+    <book id="b1" lang="zh" refs=" b2  b1 " tags="go xml"><title>Go</title><author>Tom <em>Lee</em></author></book>
+    <book id="b2" format="paper"><title>XML</title></book>
+    <!--magazines-->
+    <magazine cover="cover1" issue="2020-01"/>
+    <journal>text<title>any</title></journal>
+</library>`))
+    expect(t, "返回值检测", nil == err)
+
+    violations, err := tinydom.ValidateDTD(doc, nil, nil)
+    expect(t, "使用内部子集", nil == err)
+    expect(t, "没有错误", 0 == len(violations))
+    expect(t, "默认不添加属性", nil == doc.RootElement().FirstChildElement("book").NextSiblingElement("book").FindAttribute("lang"))
+}
+
+func Test_Validate_错误的文档(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(validateLibrary + `<library>
+    <book lang="fr" format="ebook" refs="b9"><author>Tom</author><title>Go</title></book>
+    <book id="b2"><title>XML</title><title>Again</title></book>
+    <book id="b2" tags="a,b"><title>Dup</title></book>
+    <magazine cover="text" issue="1 2">x</magazine>
+    <note>text<em>bold</em></note>
+    <unknown size="1"/>
+</library>`))
+    expect(t, "返回值检测", nil == err)
+
+    violations, err := tinydom.ValidateDTD(doc, nil, nil)
+    expect(t, "返回值检测", nil == err)
+
+    var messages []string
+    for _, violation := range violations {
+        messages = append(messages, violation.Msg)
+    }
+    expected := []string{
+        "content of element library (book,book,book,magazine,note,unknown) does not match (book+,(magazine|journal)*,note?)",
+        "content of element book (author,title) does not match (title,author*)",
+        "required attribute id of element book is missing",
+        `attribute lang of element book must be one of (en|zh)`,
+        `attribute format of element book must be "paper"`,
+        "attribute refs of element book refers to missing ID b9",
+        "content of element book (title,title) does not match (title,author*)",
+        "duplicate ID b2",
+        "attribute tags of element book is not a valid name token",
+        "element magazine is declared EMPTY but has content",
+        "attribute cover of element magazine refers to undeclared unparsed entity text",
+        "attribute issue of element magazine must be a single name token",
+        "element em is not allowed in note",
+        "element unknown is not declared",
+        "attribute size of element unknown is not declared",
+    }
+    expect(t, "按照文档的顺序返回所有错误", strings.Join(expected, "\n") == strings.Join(messages, "\n"))
+
+    first := violations[0]
+    expect(t, "错误的位置", doc.RootElement() == first.Element && first.Position == doc.RootElement().Position() && 20 == first.Line)
+    expect(t, "错误的文本", strings.HasPrefix(first.Error(), "line 20, column 1: content of element library"))
+
+    em := doc.RootElement().FirstChildElement("note").FirstChildElement("em")
+    expect(t, "子元素的位置", em == violations[12].Element && em.Position() == violations[12].Position)
+}
+
+func Test_Validate_大量的子元素(t *testing.T) {
+    src := `<!DOCTYPE list [<!ELEMENT list (head?,(item|note)*,item+)><!ELEMENT head EMPTY><!ELEMENT item EMPTY><!ELEMENT note EMPTY>]><list><head/>` +
+        strings.Repeat("<item/><note/>", 50000) + `<item/></list>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(src))
+    expect(t, "返回值检测", nil == err)
+
+    start := time.Now()
+    violations, err := tinydom.ValidateDTD(doc, nil, nil)
+    expect(t, "有效的文档", nil == err && 0 == len(violations))
+    expect(t, "时间与子元素的数量成正比", time.Since(start) < 2*time.Second)
+
+    doc.RootElement().InsertEndChild(tinydom.NewElement(doc, "note"))
+    violations, err = tinydom.ValidateDTD(doc, nil, nil)
+    expect(t, "最后一个元素不符合", nil == err && 1 == len(violations))
+}
+
+func Test_Validate_默认值(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(validateLibrary + `<library><book id="b1"><title>Go</title></book></library>`))
+    expect(t, "返回值检测", nil == err)
+
+    violations, err := tinydom.ValidateDTD(doc, nil, &tinydom.ValidateOptions{ApplyDefaults: true})
+    expect(t, "返回值检测", nil == err && 0 == len(violations))
+
+    book := doc.RootElement().FirstChildElement("book")
+    expect(t, "添加默认值和FIXED", "en" == book.Attribute("lang", "") && "paper" == book.Attribute("format", ""))
+    expect(t, "IMPLIED不添加", nil == book.FindAttribute("refs") && 3 == book.AttributeCount())
+}
+
+func Test_Validate_外部DTD(t *testing.T) {
+    external := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!-- notes -->\n<!ELEMENT note (to,body)>\n<!ELEMENT to (#PCDATA)>\n<!ELEMENT body (#PCDATA)>\n<!ATTLIST note id ID #REQUIRED>\n"
+    dtd, err := tinydom.ParseDTD(strings.NewReader(external))
+    expect(t, "解析外部DTD", nil == err && 6 == len(dtd.Decls) && nil != dtd.Element("note"))
+
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<!DOCTYPE note SYSTEM "note.dtd"><note id="n1"><to>Ann</to><body>Hi</body></note>`))
+    expect(t, "返回值检测", nil == err)
+
+    violations, err := tinydom.ValidateDTD(doc, dtd, nil)
+    expect(t, "有效的文档", nil == err && 0 == len(violations))
+
+    _, err = tinydom.ValidateDTD(doc, nil, nil)
+    expect(t, "没有内部子集", tinydom.ErrNoDTD == err)
+
+    created := tinydom.NewDocument()
+    created.SetRootElement(tinydom.NewElement(created, "memo"))
+    violations, err = tinydom.ValidateDTD(created, dtd, nil)
+    expect(t, "没有DOCTYPE时只检查DTD", nil == err && 1 == len(violations) && "element memo is not declared" == violations[0].Error())
+
+    doc, err = tinydom.LoadDocument(strings.NewReader(`<!DOCTYPE memo [<!ELEMENT memo EMPTY>]><note id="n1"><to>Ann</to><body>Hi</body></note>`))
+    expect(t, "返回值检测", nil == err)
+    internal, _ := doc.FirstChild().ToDirective().DocType()
+    combined := &tinydom.DTD{Decls: append(internal.Subset.Decls, dtd.Decls...)}
+    violations, err = tinydom.ValidateDTD(doc, combined, nil)
+    expect(t, "合并内部子集和外部DTD", nil == err && 1 == len(violations) && "root element note does not match DOCTYPE memo" == violations[0].Msg)
+
+    _, err = tinydom.ParseDTD(strings.NewReader("<!ELEMENT note (to>\n"))
+    parseErr, ok := err.(*tinydom.ParseError)
+    expect(t, "外部DTD的语法错误", ok && errors.Is(err, tinydom.ErrInvalidDTD) && 1 == parseErr.Line && 19 == parseErr.Column)
+
+    dtd, err = tinydom.ParseDTD(bytes.NewReader(encodeUTF16(external, false, true)))
+    expect(t, "UTF-16的外部DTD", nil == err && nil != dtd.Element("body"))
+
+    doc, _ = tinydom.LoadDocument(strings.NewReader(`<!DOCTYPE note [<!ELEMENT note>]><note/>`))
+    _, err = tinydom.ValidateDTD(doc, dtd, nil)
+    expect(t, "DOCTYPE的语法错误", errors.Is(err, tinydom.ErrInvalidDTD))
+}
@@ -0,0 +1,1897 @@
+package tinydom
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+//  XPathResultType 表示XPath表达式求值结果的类型
+type XPathResultType int
+
+const (
+    //  XPathNodeSet    节点集合
+    XPathNodeSet XPathResultType = iota
+    //  XPathString 字符串
+    XPathString
+    //  XPathNumber 数字
+    XPathNumber
+    //  XPathBoolean    布尔值
+    XPathBoolean
+)
+
+//  XPathResult 是XPath表达式的求值结果
+//
+//  Nodes和Attributes返回节点集合中的节点和属性，都按照文档顺序排列，结果不是节点集合时返回nil；
+//  String、Number、Boolean按照XPath 1.0的string()、number()、boolean()函数的规则对结果进行转换。
+type XPathResult interface {
+    Type() XPathResultType
+    Nodes() []XMLNode
+    Attributes() []XMLAttribute
+    String() string
+    Number() float64
+    Boolean() bool
+}
+
+//  XPathExpression 是编译好的XPath 1.0表达式，可以被多次求值
+//
+//  Evaluate以node为上下文节点对表达式进行求值，Select和SelectOne是Evaluate的简化形式，
+//  它们只返回节点集合中的XMLNode(属性节点被忽略)，表达式求值失败或者结果不是节点集合时返回nil。
+type XPathExpression interface {
+    Evaluate(node XMLNode) (XPathResult, error)
+    Select(node XMLNode) []XMLNode
+    SelectOne(node XMLNode) XMLNode
+    String() string
+}
+
+//  XPathError  是编译或者求值XPath表达式时返回的错误
+type XPathError struct {
+    Expr string
+    Msg  string
+}
+
+func (this *XPathError) Error() string {
+    return "xpath " + strconv.Quote(this.Expr) + ": " + this.Msg
+}
+
+//	CompileXPath	编译XPath表达式
+//
+//	名字测试按照限定名进行比较，比如"//a:item"匹配所有限定名为a:item的元素，不关心前缀绑定的名字空间；
+//	没有前缀的名字测试同样按照限定名比较，因此在使用了默认名字空间的文档中，"//feed"依然可以匹配到feed元素。
+//	如果需要按照名字空间匹配，请使用CompileXPathNS。变量引用不被支持。
+func CompileXPath(expr string) (XPathExpression, error) {
+    return CompileXPathNS(expr, nil)
+}
+
+//	CompileXPathNS	编译XPath表达式，namespaces给出了表达式中使用的前缀与名字空间的对应关系.
+//
+//	带有前缀的名字测试，如果前缀出现在namespaces中，则按照名字空间和本地名进行匹配，否则按照限定名进行匹配
+func CompileXPathNS(expr string, namespaces map[string]string) (XPathExpression, error) {
+    tokens, err := xpathScan(expr)
+    if nil != err {
+        return nil, &XPathError{Expr: expr, Msg: err.Error()}
+    }
+
+    parser := &xpathParser{tokens: tokens}
+    root, err := parser.parse()
+    if nil != err {
+        return nil, &XPathError{Expr: expr, Msg: err.Error()}
+    }
+
+    compiled := &xpathExpressionImpl{source: expr, root: root, namespaces: make(map[string]string)}
+    for prefix, uri := range namespaces {
+        compiled.namespaces[prefix] = uri
+    }
+    return compiled, nil
+}
+
+//	Select	以node为上下文节点对XPath表达式expr求值，返回结果节点集合中的节点.
+//	表达式错误或者结果不是节点集合时返回nil
+func Select(node XMLNode, expr string) []XMLNode {
+    compiled, err := CompileXPath(expr)
+    if nil != err {
+        return nil
+    }
+
+    return compiled.Select(node)
+}
+
+//	SelectOne	以node为上下文节点对XPath表达式expr求值，返回结果节点集合中按文档顺序的第一个节点，没有则返回nil
+func SelectOne(node XMLNode, expr string) XMLNode {
+    compiled, err := CompileXPath(expr)
+    if nil != err {
+        return nil
+    }
+
+    return compiled.SelectOne(node)
+}
+
+//------------------------------------------------------------------
+
+type xpathExpressionImpl struct {
+    source     string
+    root       xpathExpr
+    namespaces map[string]string
+}
+
+func (this *xpathExpressionImpl) String() string {
+    return this.source
+}
+
+func (this *xpathExpressionImpl) Evaluate(node XMLNode) (XPathResult, error) {
+    if nil == node {
+        return nil, &XPathError{Expr: this.source, Msg: "context node is nil"}
+    }
+
+    env := &xpathEnv{namespaces: this.namespaces}
+    ctx := &xpathContext{env: env, node: xpathNode{node: node}, position: 1, size: 1}
+    value, err := this.root.eval(ctx)
+    if nil != err {
+        return nil, &XPathError{Expr: this.source, Msg: err.Error()}
+    }
+
+    if nodes, ok := value.(xpathNodeSet); ok {
+        value = env.sort(nodes)
+    }
+
+    return &xpathResultImpl{value: value}, nil
+}
+
+func (this *xpathExpressionImpl) Select(node XMLNode) []XMLNode {
+    result, err := this.Evaluate(node)
+    if nil != err {
+        return nil
+    }
+
+    return result.Nodes()
+}
+
+func (this *xpathExpressionImpl) SelectOne(node XMLNode) XMLNode {
+    nodes := this.Select(node)
+    if 0 == len(nodes) {
+        return nil
+    }
+
+    return nodes[0]
+}
+
+//------------------------------------------------------------------
+
+type xpathResultImpl struct {
+    value xpathValue
+}
+
+func (this *xpathResultImpl) Type() XPathResultType {
+    switch this.value.(type) {
+    case xpathNodeSet:
+        return XPathNodeSet
+    case string:
+        return XPathString
+    case float64:
+        return XPathNumber
+    }
+
+    return XPathBoolean
+}
+
+func (this *xpathResultImpl) Nodes() []XMLNode {
+    nodes, ok := this.value.(xpathNodeSet)
+    if !ok {
+        return nil
+    }
+
+    result := make([]XMLNode, 0, len(nodes))
+    for _, item := range nodes {
+        if nil == item.attr {
+            result = append(result, item.node)
+        }
+    }
+    return result
+}
+
+func (this *xpathResultImpl) Attributes() []XMLAttribute {
+    nodes, ok := this.value.(xpathNodeSet)
+    if !ok {
+        return nil
+    }
+
+    result := make([]XMLAttribute, 0, len(nodes))
+    for _, item := range nodes {
+        if nil != item.attr {
+            result = append(result, item.attr)
+        }
+    }
+    return result
+}
+
+func (this *xpathResultImpl) String() string {
+    return xpathToString(this.value)
+}
+
+func (this *xpathResultImpl) Number() float64 {
+    return xpathToNumber(this.value)
+}
+
+func (this *xpathResultImpl) Boolean() bool {
+    return xpathToBoolean(this.value)
+}
+
+//==================================================================
+//  数据模型
+
+//  xpathNode   是XPath数据模型中的一个节点，attr不为nil时表示node元素上的一个属性节点
+type xpathNode struct {
+    node XMLNode
+    attr XMLAttribute
+}
+
+//  xpathNodeSet    是XPath的节点集合
+type xpathNodeSet []xpathNode
+
+//  xpathValue  是表达式的值，只可能是xpathNodeSet、string、float64、bool中的一种
+type xpathValue interface{}
+
+//	xpathVisible	判断node是否属于XPath的数据模型，指令节点和XML声明不属于XPath的数据模型
+func xpathVisible(node XMLNode) bool {
+    if nil != node.ToDirective() {
+        return false
+    }
+
+    if procInst := node.ToProcInst(); nil != procInst {
+        return "xml" != procInst.Target()
+    }
+
+    return true
+}
+
+//	xpathIsNamespaceDecl	xmlns属性在XPath中是名字空间节点，不属于属性轴
+func xpathIsNamespaceDecl(attr XMLAttribute) bool {
+    return ("xmlns" == attr.Name()) || ("xmlns" == attr.Prefix())
+}
+
+//	xpathStringValue	返回节点的字符串值
+func xpathStringValue(item xpathNode) string {
+    if nil != item.attr {
+        return item.attr.Value()
+    }
+
+    node := item.node
+    if procInst := node.ToProcInst(); nil != procInst {
+        return procInst.Instruction()
+    }
+
+    if (nil != node.ToElement()) || (nil != node.ToDocument()) {
+        var buf strings.Builder
+        collectText(node, &buf)
+        return buf.String()
+    }
+
+    return node.Value()
+}
+
+//  xpathEnv    是一次求值过程共享的环境
+type xpathEnv struct {
+    namespaces map[string]string
+
+    //  节点的文档顺序，需要排序时才计算
+    order map[XMLNode]int
+}
+
+//	orderOf	返回节点的文档顺序，属性节点排在所属元素之后、元素的子节点之前
+func (this *xpathEnv) orderOf(item xpathNode) (int, int) {
+    if nil == this.order {
+        this.order = make(map[XMLNode]int)
+    }
+
+    index, ok := this.order[item.node]
+    if !ok {
+        //  为节点所在的整棵树编号
+        root := item.node
+        for nil != root.Parent() {
+            root = root.Parent()
+        }
+
+        var walk func(node XMLNode)
+        walk = func(node XMLNode) {
+            this.order[node] = len(this.order)
+            for child := node.FirstChild(); nil != child; child = child.NextSibling() {
+                walk(child)
+            }
+        }
+        walk(root)
+        index = this.order[item.node]
+    }
+
+    if nil == item.attr {
+        return index, 0
+    }
+
+    elem := item.node.ToElement()
+    sub := 1
+    for attr := elem.FirstAttribute(); (nil != attr) && (attr != item.attr); attr = attr.NextAttribute() {
+        sub++
+    }
+    return index, sub
+}
+
+//	sort	将节点集合按照文档顺序排序并去掉重复的节点
+func (this *xpathEnv) sort(nodes xpathNodeSet) xpathNodeSet {
+    if len(nodes) < 2 {
+        return nodes
+    }
+
+    seen := make(map[xpathNode]bool, len(nodes))
+    result := make(xpathNodeSet, 0, len(nodes))
+    for _, item := range nodes {
+        if !seen[item] {
+            seen[item] = true
+            result = append(result, item)
+        }
+    }
+
+    sort.SliceStable(result, func(i, j int) bool {
+        a1, a2 := this.orderOf(result[i])
+        b1, b2 := this.orderOf(result[j])
+        if a1 != b1 {
+            return a1 < b1
+        }
+        return a2 < b2
+    })
+    return result
+}
+
+//  xpathContext    是表达式求值时的上下文
+type xpathContext struct {
+    env      *xpathEnv
+    node     xpathNode
+    position int
+    size     int
+}
+
+//==================================================================
+//  类型转换
+
+func xpathToString(value xpathValue) string {
+    switch v := value.(type) {
+    case xpathNodeSet:
+        if 0 == len(v) {
+            return ""
+        }
+        return xpathStringValue(v[0])
+    case string:
+        return v
+    case float64:
+        return xpathNumberToString(v)
+    case bool:
+        if v {
+            return "true"
+        }
+        return "false"
+    }
+
+    return ""
+}
+
+func xpathNumberToString(number float64) string {
+    switch {
+    case math.IsNaN(number):
+        return "NaN"
+    case math.IsInf(number, 1):
+        return "Infinity"
+    case math.IsInf(number, -1):
+        return "-Infinity"
+    case 0 == number:
+        //  包括-0
+        return "0"
+    }
+
+    return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+func xpathToNumber(value xpathValue) float64 {
+    switch v := value.(type) {
+    case xpathNodeSet:
+        return xpathStringToNumber(xpathToString(v))
+    case string:
+        return xpathStringToNumber(v)
+    case float64:
+        return v
+    case bool:
+        if v {
+            return 1
+        }
+        return 0
+    }
+
+    return math.NaN()
+}
+
+//	xpathStringToNumber	按照XPath的规则将字符串转换为数字，只接受可选的负号和十进制数字，否则结果为NaN
+func xpathStringToNumber(str string) float64 {
+    str = strings.TrimFunc(str, isXMLSpace)
+    digits := strings.TrimPrefix(str, "-")
+    if ("" == digits) || ("." == digits) {
+        return math.NaN()
+    }
+
+    dot := false
+    for _, r := range digits {
+        if '.' == r {
+            if dot {
+                return math.NaN()
+            }
+            dot = true
+        } else if (r < '0') || (r > '9') {
+            return math.NaN()
+        }
+    }
+
+    number, err := strconv.ParseFloat(str, 64)
+    if nil != err {
+        return math.NaN()
+    }
+    return number
+}
+
+func xpathToBoolean(value xpathValue) bool {
+    switch v := value.(type) {
+    case xpathNodeSet:
+        return len(v) > 0
+    case string:
+        return "" != v
+    case float64:
+        return (0 != v) && !math.IsNaN(v)
+    case bool:
+        return v
+    }
+
+    return false
+}
+
+//==================================================================
+//  词法分析
+
+type xpathTokenKind int
+
+const (
+    xpathTokenEOF xpathTokenKind = iota
+    //  ( ) [ ] . .. @ , ::
+    xpathTokenPunct
+    //  and or mod div / // | + - = != < <= > >= *
+    xpathTokenOperator
+    //  *、NCName:*、QName
+    xpathTokenNameTest
+    //  comment text processing-instruction node
+    xpathTokenNodeType
+    xpathTokenFunctionName
+    xpathTokenAxisName
+    xpathTokenLiteral
+    xpathTokenNumber
+    xpathTokenVariable
+)
+
+type xpathToken struct {
+    kind   xpathTokenKind
+    value  string
+    offset int
+}
+
+//	xpathScan	将表达式切分为记号，并按照XPath 1.0规范中的规则消除歧义
+func xpathScan(expr string) ([]xpathToken, error) {
+    var tokens []xpathToken
+
+    //  根据前一个记号判断*和NCName是否应当被解释为运算符
+    operatorExpected := func() bool {
+        if 0 == len(tokens) {
+            return false
+        }
+
+        last := tokens[len(tokens)-1]
+        switch last.kind {
+        case xpathTokenOperator:
+            return false
+        case xpathTokenPunct:
+            switch last.value {
+            case "@", "::", "(", "[", ",":
+                return false
+            }
+        }
+        return true
+    }
+
+    //  跳过空白之后的下一个字符
+    peekAfterSpace := func(offset int) (int, byte) {
+        for (offset < len(expr)) && isXMLSpace(rune(expr[offset])) {
+            offset++
+        }
+        if offset < len(expr) {
+            return offset, expr[offset]
+        }
+        return offset, 0
+    }
+
+    for offset := 0; offset < len(expr); {
+        c := expr[offset]
+        if isXMLSpace(rune(c)) {
+            offset++
+            continue
+        }
+
+        start := offset
+        emit := func(kind xpathTokenKind, value string) {
+            tokens = append(tokens, xpathToken{kind: kind, value: value, offset: start})
+        }
+
+        switch {
+        case ('(' == c) || (')' == c) || ('[' == c) || (']' == c) || ('@' == c) || (',' == c):
+            emit(xpathTokenPunct, string(c))
+            offset++
+        case strings.HasPrefix(expr[offset:], "::"):
+            emit(xpathTokenPunct, "::")
+            offset += 2
+        case strings.HasPrefix(expr[offset:], ".."):
+            emit(xpathTokenPunct, "..")
+            offset += 2
+        case ('.' == c) && ((offset+1 >= len(expr)) || (expr[offset+1] < '0') || (expr[offset+1] > '9')):
+            emit(xpathTokenPunct, ".")
+            offset++
+        case ('.' == c) || (('0' <= c) && (c <= '9')):
+            end := offset
+            for (end < len(expr)) && ((('0' <= expr[end]) && (expr[end] <= '9')) || ('.' == expr[end])) {
+                end++
+            }
+            if strings.Count(expr[offset:end], ".") > 1 {
+                return nil, fmt.Errorf("invalid number at offset %d", offset)
+            }
+            emit(xpathTokenNumber, expr[offset:end])
+            offset = end
+        case ('"' == c) || ('\'' == c):
+            end := strings.IndexByte(expr[offset+1:], c)
+            if end < 0 {
+                return nil, fmt.Errorf("unterminated literal at offset %d", offset)
+            }
+            emit(xpathTokenLiteral, expr[offset+1:offset+1+end])
+            offset += end + 2
+        case strings.HasPrefix(expr[offset:], "//"):
+            emit(xpathTokenOperator, "//")
+            offset += 2
+        case strings.HasPrefix(expr[offset:], "!="), strings.HasPrefix(expr[offset:], "<="), strings.HasPrefix(expr[offset:], ">="):
+            emit(xpathTokenOperator, expr[offset:offset+2])
+            offset += 2
+        case strings.IndexByte("/|+-=<>", c) >= 0:
+            emit(xpathTokenOperator, string(c))
+            offset++
+        case '*' == c:
+            if operatorExpected() {
+                emit(xpathTokenOperator, "*")
+            } else {
+                emit(xpathTokenNameTest, "*")
+            }
+            offset++
+        case '$' == c:
+            name, end := xpathScanQName(expr, offset+1)
+            if "" == name {
+                return nil, fmt.Errorf("invalid variable reference at offset %d", offset)
+            }
+            emit(xpathTokenVariable, name)
+            offset = end
+        default:
+            name, end := xpathScanNCName(expr, offset)
+            if "" == name {
+                return nil, fmt.Errorf("unexpected character %q at offset %d", c, offset)
+            }
+
+            if operatorExpected() {
+                switch name {
+                case "and", "or", "mod", "div":
+                    emit(xpathTokenOperator, name)
+                    offset = end
+                    continue
+                }
+                return nil, fmt.Errorf("unexpected name %q at offset %d", name, offset)
+            }
+
+            //  NCName:*
+            if strings.HasPrefix(expr[end:], ":*") {
+                emit(xpathTokenNameTest, name+":*")
+                offset = end + 2
+                continue
+            }
+
+            //  轴名称
+            if next, _ := peekAfterSpace(end); strings.HasPrefix(expr[next:], "::") {
+                emit(xpathTokenAxisName, name)
+                offset = end
+                continue
+            }
+
+            qname, qend := xpathScanQName(expr, offset)
+            if _, c := peekAfterSpace(qend); '(' == c {
+                switch qname {
+                case "comment", "text", "processing-instruction", "node":
+                    emit(xpathTokenNodeType, qname)
+                default:
+                    emit(xpathTokenFunctionName, qname)
+                }
+            } else {
+                emit(xpathTokenNameTest, qname)
+            }
+            offset = qend
+        }
+    }
+
+    return tokens, nil
+}
+
+func xpathIsNameStart(r rune) bool {
+    return ('_' == r) || (('a' <= r) && (r <= 'z')) || (('A' <= r) && (r <= 'Z')) || (r >= 0x80)
+}
+
+func xpathIsNameChar(r rune) bool {
+    return xpathIsNameStart(r) || ('-' == r) || ('.' == r) || (('0' <= r) && (r <= '9'))
+}
+
+//	xpathScanNCName	从offset开始读取一个NCName，返回名字以及名字之后的位置
+func xpathScanNCName(expr string, offset int) (string, int) {
+    end := offset
+    for end < len(expr) {
+        r, width := utf8.DecodeRuneInString(expr[end:])
+        if ((end == offset) && !xpathIsNameStart(r)) || ((end > offset) && !xpathIsNameChar(r)) {
+            break
+        }
+        end += width
+    }
+
+    return expr[offset:end], end
+}
+
+//	xpathScanQName	从offset开始读取一个QName，返回名字以及名字之后的位置
+func xpathScanQName(expr string, offset int) (string, int) {
+    prefix, end := xpathScanNCName(expr, offset)
+    if ("" == prefix) || !strings.HasPrefix(expr[end:], ":") {
+        return prefix, end
+    }
+
+    local, localEnd := xpathScanNCName(expr, end+1)
+    if "" == local {
+        return prefix, end
+    }
+    return prefix + ":" + local, localEnd
+}
+
+//==================================================================
+//  语法分析
+
+type xpathParser struct {
+    tokens []xpathToken
+    pos    int
+}
+
+func (this *xpathParser) peek() xpathToken {
+    if this.pos < len(this.tokens) {
+        return this.tokens[this.pos]
+    }
+    return xpathToken{kind: xpathTokenEOF}
+}
+
+func (this *xpathParser) next() xpathToken {
+    token := this.peek()
+    if this.pos < len(this.tokens) {
+        this.pos++
+    }
+    return token
+}
+
+func (this *xpathParser) is(kind xpathTokenKind, value string) bool {
+    token := this.peek()
+    return (token.kind == kind) && (token.value == value)
+}
+
+func (this *xpathParser) expect(kind xpathTokenKind, value string) error {
+    if !this.is(kind, value) {
+        return this.unexpected()
+    }
+    this.next()
+    return nil
+}
+
+func (this *xpathParser) unexpected() error {
+    token := this.peek()
+    if xpathTokenEOF == token.kind {
+        return fmt.Errorf("unexpected end of expression")
+    }
+    return fmt.Errorf("unexpected %q at offset %d", token.value, token.offset)
+}
+
+func (this *xpathParser) parse() (xpathExpr, error) {
+    expr, err := this.parseOr()
+    if nil != err {
+        return nil, err
+    }
+
+    if xpathTokenEOF != this.peek().kind {
+        return nil, this.unexpected()
+    }
+    return expr, nil
+}
+
+//	parseBinary	解析由operators中的运算符连接的左结合二元表达式
+func (this *xpathParser) parseBinary(operand func() (xpathExpr, error), operators ...string) (xpathExpr, error) {
+    left, err := operand()
+    if nil != err {
+        return nil, err
+    }
+
+    for {
+        token := this.peek()
+        matched := false
+        if xpathTokenOperator == token.kind {
+            for _, op := range operators {
+                if op == token.value {
+                    matched = true
+                    break
+                }
+            }
+        }
+        if !matched {
+            return left, nil
+        }
+
+        this.next()
+        right, err := operand()
+        if nil != err {
+            return nil, err
+        }
+        left = &xpathBinaryExpr{op: token.value, left: left, right: right}
+    }
+}
+
+func (this *xpathParser) parseOr() (xpathExpr, error) {
+    return this.parseBinary(this.parseAnd, "or")
+}
+
+func (this *xpathParser) parseAnd() (xpathExpr, error) {
+    return this.parseBinary(this.parseEquality, "and")
+}
+
+func (this *xpathParser) parseEquality() (xpathExpr, error) {
+    return this.parseBinary(this.parseRelational, "=", "!=")
+}
+
+func (this *xpathParser) parseRelational() (xpathExpr, error) {
+    return this.parseBinary(this.parseAdditive, "<", "<=", ">", ">=")
+}
+
+func (this *xpathParser) parseAdditive() (xpathExpr, error) {
+    return this.parseBinary(this.parseMultiplicative, "+", "-")
+}
+
+func (this *xpathParser) parseMultiplicative() (xpathExpr, error) {
+    return this.parseBinary(this.parseUnary, "*", "div", "mod")
+}
+
+func (this *xpathParser) parseUnary() (xpathExpr, error) {
+    if this.is(xpathTokenOperator, "-") {
+        this.next()
+        operand, err := this.parseUnary()
+        if nil != err {
+            return nil, err
+        }
+        return &xpathNegateExpr{operand: operand}, nil
+    }
+
+    return this.parseUnion()
+}
+
+func (this *xpathParser) parseUnion() (xpathExpr, error) {
+    return this.parseBinary(this.parsePath, "|")
+}
+
+func (this *xpathParser) parsePath() (xpathExpr, error) {
+    token := this.peek()
+    switch token.kind {
+    case xpathTokenLiteral, xpathTokenNumber, xpathTokenFunctionName, xpathTokenVariable:
+        return this.parseFilterPath()
+    case xpathTokenPunct:
+        if "(" == token.value {
+            return this.parseFilterPath()
+        }
+    }
+
+    return this.parseLocationPath()
+}
+
+func (this *xpathParser) parseFilterPath() (xpathExpr, error) {
+    primary, err := this.parsePrimary()
+    if nil != err {
+        return nil, err
+    }
+
+    predicates, err := this.parsePredicates()
+    if nil != err {
+        return nil, err
+    }
+
+    var expr xpathExpr = primary
+    if len(predicates) > 0 {
+        expr = &xpathFilterExpr{primary: primary, predicates: predicates}
+    }
+
+    if !this.is(xpathTokenOperator, "/") && !this.is(xpathTokenOperator, "//") {
+        return expr, nil
+    }
+
+    path := &xpathPathExpr{filter: expr}
+    if err := this.parseRelativeSteps(path, true); nil != err {
+        return nil, err
+    }
+    return path, nil
+}
+
+func (this *xpathParser) parsePrimary() (xpathExpr, error) {
+    token := this.next()
+    switch token.kind {
+    case xpathTokenLiteral:
+        return &xpathLiteralExpr{value: token.value}, nil
+    case xpathTokenNumber:
+        number, err := strconv.ParseFloat(token.value, 64)
+        if nil != err {
+            return nil, fmt.Errorf("invalid number %q at offset %d", token.value, token.offset)
+        }
+        return &xpathLiteralExpr{value: number}, nil
+    case xpathTokenVariable:
+        return nil, fmt.Errorf("variable $%s is not supported", token.value)
+    case xpathTokenFunctionName:
+        return this.parseFunctionCall(token)
+    }
+
+    //  ( Expr )
+    expr, err := this.parseOr()
+    if nil != err {
+        return nil, err
+    }
+    if err := this.expect(xpathTokenPunct, ")"); nil != err {
+        return nil, err
+    }
+    return expr, nil
+}
+
+func (this *xpathParser) parseFunctionCall(name xpathToken) (xpathExpr, error) {
+    function, ok := xpathFunctions[name.value]
+    if !ok {
+        return nil, fmt.Errorf("unknown function %s() at offset %d", name.value, name.offset)
+    }
+
+    if err := this.expect(xpathTokenPunct, "("); nil != err {
+        return nil, err
+    }
+
+    call := &xpathCallExpr{name: name.value, function: function}
+    if !this.is(xpathTokenPunct, ")") {
+        for {
+            arg, err := this.parseOr()
+            if nil != err {
+                return nil, err
+            }
+            call.args = append(call.args, arg)
+
+            if !this.is(xpathTokenPunct, ",") {
+                break
+            }
+            this.next()
+        }
+    }
+
+    if err := this.expect(xpathTokenPunct, ")"); nil != err {
+        return nil, err
+    }
+
+    if (len(call.args) < function.minArgs) || ((function.maxArgs >= 0) && (len(call.args) > function.maxArgs)) {
+        return nil, fmt.Errorf("wrong number of arguments for %s() at offset %d", name.value, name.offset)
+    }
+    return call, nil
+}
+
+func (this *xpathParser) parsePredicates() ([]xpathExpr, error) {
+    var predicates []xpathExpr
+    for this.is(xpathTokenPunct, "[") {
+        this.next()
+        predicate, err := this.parseOr()
+        if nil != err {
+            return nil, err
+        }
+        if err := this.expect(xpathTokenPunct, "]"); nil != err {
+            return nil, err
+        }
+        predicates = append(predicates, predicate)
+    }
+
+    return predicates, nil
+}
+
+func (this *xpathParser) parseLocationPath() (xpathExpr, error) {
+    path := new(xpathPathExpr)
+
+    if this.is(xpathTokenOperator, "/") {
+        this.next()
+        path.absolute = true
+
+        //  单独的"/"表示根节点
+        if !this.startsStep() {
+            return path, nil
+        }
+        return path, this.parseRelativeSteps(path, false)
+    }
+
+    if this.is(xpathTokenOperator, "//") {
+        path.absolute = true
+        return path, this.parseRelativeSteps(path, true)
+    }
+
+    return path, this.parseRelativeSteps(path, false)
+}
+
+//	startsStep	判断下一个记号是否是一个定位步的开始
+func (this *xpathParser) startsStep() bool {
+    token := this.peek()
+    switch token.kind {
+    case xpathTokenNameTest, xpathTokenNodeType, xpathTokenAxisName:
+        return true
+    case xpathTokenPunct:
+        return ("." == token.value) || (".." == token.value) || ("@" == token.value)
+    }
+    return false
+}
+
+//	parseRelativeSteps	解析由/或者//分隔的定位步，leadingSeparator为true时第一个定位步之前必须有分隔符
+func (this *xpathParser) parseRelativeSteps(path *xpathPathExpr, leadingSeparator bool) error {
+    for first := true; ; first = false {
+        if !first || leadingSeparator {
+            switch {
+            case this.is(xpathTokenOperator, "/"):
+                this.next()
+            case this.is(xpathTokenOperator, "//"):
+                this.next()
+                //  "//"是"/descendant-or-self::node()/"的缩写
+                path.steps = append(path.steps, &xpathStep{axis: "descendant-or-self", test: xpathNodeTest{nodeType: "node"}})
+            default:
+                return nil
+            }
+        }
+
+        step, err := this.parseStep()
+        if nil != err {
+            return err
+        }
+        path.steps = append(path.steps, step)
+
+        if !this.is(xpathTokenOperator, "/") && !this.is(xpathTokenOperator, "//") {
+            return nil
+        }
+    }
+}
+
+func (this *xpathParser) parseStep() (*xpathStep, error) {
+    if this.is(xpathTokenPunct, ".") {
+        this.next()
+        return &xpathStep{axis: "self", test: xpathNodeTest{nodeType: "node"}}, nil
+    }
+
+    if this.is(xpathTokenPunct, "..") {
+        this.next()
+        return &xpathStep{axis: "parent", test: xpathNodeTest{nodeType: "node"}}, nil
+    }
+
+    step := &xpathStep{axis: "child"}
+    if this.is(xpathTokenPunct, "@") {
+        this.next()
+        step.axis = "attribute"
+    } else if xpathTokenAxisName == this.peek().kind {
+        axis := this.next()
+        if _, ok := xpathAxes[axis.value]; !ok {
+            return nil, fmt.Errorf("unknown axis %s at offset %d", axis.value, axis.offset)
+        }
+        step.axis = axis.value
+        if err := this.expect(xpathTokenPunct, "::"); nil != err {
+            return nil, err
+        }
+    }
+
+    token := this.peek()
+    switch token.kind {
+    case xpathTokenNameTest:
+        this.next()
+        step.test.name = token.value
+    case xpathTokenNodeType:
+        this.next()
+        step.test.nodeType = token.value
+        if err := this.expect(xpathTokenPunct, "("); nil != err {
+            return nil, err
+        }
+        if ("processing-instruction" == token.value) && (xpathTokenLiteral == this.peek().kind) {
+            step.test.name = this.next().value
+        }
+        if err := this.expect(xpathTokenPunct, ")"); nil != err {
+            return nil, err
+        }
+    default:
+        return nil, this.unexpected()
+    }
+
+    predicates, err := this.parsePredicates()
+    if nil != err {
+        return nil, err
+    }
+    step.predicates = predicates
+    return step, nil
+}
+
+//==================================================================
+//  表达式求值
+
+type xpathExpr interface {
+    eval(ctx *xpathContext) (xpathValue, error)
+}
+
+//------------------------------------------------------------------
+
+type xpathLiteralExpr struct {
+    value xpathValue
+}
+
+func (this *xpathLiteralExpr) eval(ctx *xpathContext) (xpathValue, error) {
+    return this.value, nil
+}
+
+//------------------------------------------------------------------
+
+type xpathNegateExpr struct {
+    operand xpathExpr
+}
+
+func (this *xpathNegateExpr) eval(ctx *xpathContext) (xpathValue, error) {
+    value, err := this.operand.eval(ctx)
+    if nil != err {
+        return nil, err
+    }
+    return -xpathToNumber(value), nil
+}
+
+//------------------------------------------------------------------
+
+type xpathBinaryExpr struct {
+    op    string
+    left  xpathExpr
+    right xpathExpr
+}
+
+func (this *xpathBinaryExpr) eval(ctx *xpathContext) (xpathValue, error) {
+    left, err := this.left.eval(ctx)
+    if nil != err {
+        return nil, err
+    }
+
+    //  and和or是短路求值的
+    switch this.op {
+    case "and":
+        if !xpathToBoolean(left) {
+            return false, nil
+        }
+    case "or":
+        if xpathToBoolean(left) {
+            return true, nil
+        }
+    }
+
+    right, err := this.right.eval(ctx)
+    if nil != err {
+        return nil, err
+    }
+
+    switch this.op {
+    case "and", "or":
+        return xpathToBoolean(right), nil
+    case "|":
+        leftNodes, ok1 := left.(xpathNodeSet)
+        rightNodes, ok2 := right.(xpathNodeSet)
+        if !ok1 || !ok2 {
+            return nil, fmt.Errorf("operands of | must be node-sets")
+        }
+        union := make(xpathNodeSet, 0, len(leftNodes)+len(rightNodes))
+        union = append(union, leftNodes...)
+        union = append(union, rightNodes...)
+        return ctx.env.sort(union), nil
+    case "=", "!=", "<", "<=", ">", ">=":
+        return xpathCompare(this.op, left, right), nil
+    }
+
+    a := xpathToNumber(left)
+    b := xpathToNumber(right)
+    switch this.op {
+    case "+":
+        return a + b, nil
+    case "-":
+        return a - b, nil
+    case "*":
+        return a * b, nil
+    case "div":
+        return a / b, nil
+    }
+
+    //  mod
+    return math.Mod(a, b), nil
+}
+
+//	xpathCompare	按照XPath 1.0的规则比较两个值，节点集合参与比较时，只要存在一个满足条件的节点即为真
+func xpathCompare(op string, left xpathValue, right xpathValue) bool {
+    leftNodes, leftIsNodes := left.(xpathNodeSet)
+    rightNodes, rightIsNodes := right.(xpathNodeSet)
+
+    switch {
+    case leftIsNodes && rightIsNodes:
+        for _, a := range leftNodes {
+            for _, b := range rightNodes {
+                if xpathCompareAtomic(op, xpathStringValue(a), xpathStringValue(b)) {
+                    return true
+                }
+            }
+        }
+        return false
+    case leftIsNodes:
+        if b, ok := right.(bool); ok {
+            return xpathCompareAtomic(op, len(leftNodes) > 0, b)
+        }
+        for _, a := range leftNodes {
+            if xpathCompareAtomic(op, xpathConvertLike(xpathStringValue(a), right), right) {
+                return true
+            }
+        }
+        return false
+    case rightIsNodes:
+        if a, ok := left.(bool); ok {
+            return xpathCompareAtomic(op, a, len(rightNodes) > 0)
+        }
+        for _, b := range rightNodes {
+            if xpathCompareAtomic(op, left, xpathConvertLike(xpathStringValue(b), left)) {
+                return true
+            }
+        }
+        return false
+    }
+
+    return xpathCompareAtomic(op, left, right)
+}
+
+//	xpathConvertLike	将节点的字符串值转换为与other相同的类型
+func xpathConvertLike(str string, other xpathValue) xpathValue {
+    if _, ok := other.(float64); ok {
+        return xpathStringToNumber(str)
+    }
+    return str
+}
+
+//	xpathCompareAtomic	比较两个非节点集合的值
+func xpathCompareAtomic(op string, left xpathValue, right xpathValue) bool {
+    if ("=" == op) || ("!=" == op) {
+        var equal bool
+        _, leftIsBool := left.(bool)
+        _, rightIsBool := right.(bool)
+        _, leftIsNumber := left.(float64)
+        _, rightIsNumber := right.(float64)
+        switch {
+        case leftIsBool || rightIsBool:
+            equal = xpathToBoolean(left) == xpathToBoolean(right)
+        case leftIsNumber || rightIsNumber:
+            equal = xpathToNumber(left) == xpathToNumber(right)
+        default:
+            equal = xpathToString(left) == xpathToString(right)
+        }
+
+        if "=" == op {
+            return equal
+        }
+        return !equal
+    }
+
+    a := xpathToNumber(left)
+    b := xpathToNumber(right)
+    switch op {
+    case "<":
+        return a < b
+    case "<=":
+        return a <= b
+    case ">":
+        return a > b
+    }
+    return a >= b
+}
+
+//------------------------------------------------------------------
+
+type xpathFilterExpr struct {
+    primary    xpathExpr
+    predicates []xpathExpr
+}
+
+func (this *xpathFilterExpr) eval(ctx *xpathContext) (xpathValue, error) {
+    value, err := this.primary.eval(ctx)
+    if nil != err {
+        return nil, err
+    }
+
+    nodes, ok := value.(xpathNodeSet)
+    if !ok {
+        return nil, fmt.Errorf("predicates can only be applied to node-sets")
+    }
+
+    //  过滤表达式中的谓词总是按照文档顺序计算位置
+    nodes = ctx.env.sort(nodes)
+    for _, predicate := range this.predicates {
+        if nodes, err = xpathApplyPredicate(ctx, nodes, predicate); nil != err {
+            return nil, err
+        }
+    }
+    return nodes, nil
+}
+
+//	xpathApplyPredicate	使用谓词过滤节点集合，节点在nodes中的次序就是它的上下文位置
+func xpathApplyPredicate(ctx *xpathContext, nodes xpathNodeSet, predicate xpathExpr) (xpathNodeSet, error) {
+    result := make(xpathNodeSet, 0, len(nodes))
+    for i, item := range nodes {
+        sub := &xpathContext{env: ctx.env, node: item, position: i + 1, size: len(nodes)}
+        value, err := predicate.eval(sub)
+        if nil != err {
+            return nil, err
+        }
+
+        //  数字类型的谓词表示位置
+        keep := false
+        if number, ok := value.(float64); ok {
+            keep = number == float64(i+1)
+        } else {
+            keep = xpathToBoolean(value)
+        }
+
+        if keep {
+            result = append(result, item)
+        }
+    }
+
+    return result, nil
+}
+
+//------------------------------------------------------------------
+
+type xpathPathExpr struct {
+    //  filter不为nil时，路径从过滤表达式的结果开始
+    filter   xpathExpr
+    absolute bool
+    steps    []*xpathStep
+}
+
+func (this *xpathPathExpr) eval(ctx *xpathContext) (xpathValue, error) {
+    var nodes xpathNodeSet
+    switch {
+    case nil != this.filter:
+        value, err := this.filter.eval(ctx)
+        if nil != err {
+            return nil, err
+        }
+        var ok bool
+        if nodes, ok = value.(xpathNodeSet); !ok {
+            return nil, fmt.Errorf("path must start with a node-set")
+        }
+    case this.absolute:
+        root := ctx.node.node
+        for nil != root.Parent() {
+            root = root.Parent()
+        }
+        nodes = xpathNodeSet{{node: root}}
+    default:
+        nodes = xpathNodeSet{ctx.node}
+    }
+
+    for _, step := range this.steps {
+        var result xpathNodeSet
+        for _, item := range nodes {
+            selected, err := step.eval(ctx.env, item)
+            if nil != err {
+                return nil, err
+            }
+            result = append(result, selected...)
+        }
+
+        if len(nodes) > 1 {
+            result = ctx.env.sort(result)
+        }
+        nodes = result
+    }
+
+    return nodes, nil
+}
+
+//------------------------------------------------------------------
+
+//  xpathNodeTest   是定位步中的节点测试，nodeType为空时表示名字测试
+type xpathNodeTest struct {
+    name     string
+    nodeType string
+}
+
+type xpathStep struct {
+    axis       string
+    test       xpathNodeTest
+    predicates []xpathExpr
+}
+
+//	eval	从item出发沿着轴选择满足节点测试和谓词的节点，结果按照轴的方向排列
+func (this *xpathStep) eval(env *xpathEnv, item xpathNode) (xpathNodeSet, error) {
+    var nodes xpathNodeSet
+    xpathAxes[this.axis](item, func(candidate xpathNode) {
+        if this.match(env, candidate) {
+            nodes = append(nodes, candidate)
+        }
+    })
+
+    ctx := &xpathContext{env: env}
+    for _, predicate := range this.predicates {
+        var err error
+        if nodes, err = xpathApplyPredicate(ctx, nodes, predicate); nil != err {
+            return nil, err
+        }
+    }
+
+    //  逆向轴上的节点需要恢复为文档顺序
+    if xpathReverseAxes[this.axis] {
+        for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+            nodes[i], nodes[j] = nodes[j], nodes[i]
+        }
+    }
+    return nodes, nil
+}
+
+func (this *xpathStep) match(env *xpathEnv, item xpathNode) bool {
+    switch this.test.nodeType {
+    case "node":
+        return true
+    case "text":
+        return (nil == item.attr) && (nil != item.node.ToText())
+    case "comment":
+        return (nil == item.attr) && (nil != item.node.ToComment())
+    case "processing-instruction":
+        if nil != item.attr {
+            return false
+        }
+        procInst := item.node.ToProcInst()
+        return (nil != procInst) && (("" == this.test.name) || (procInst.Target() == this.test.name))
+    }
+
+    //  名字测试只匹配轴的主节点类型：属性轴上是属性，其他轴上是元素
+    var name, local, uri string
+    if "attribute" == this.axis {
+        if nil == item.attr {
+            return false
+        }
+        name = item.attr.Name()
+        local = item.attr.LocalName()
+        uri = item.attr.NamespaceURI()
+    } else {
+        if nil != item.attr {
+            return false
+        }
+        elem := item.node.ToElement()
+        if nil == elem {
+            return false
+        }
+        name = elem.Name()
+        local = elem.LocalName()
+        uri = elem.NamespaceURI()
+    }
+
+    if "*" == this.test.name {
+        return true
+    }
+
+    prefix, testLocal := splitName(this.test.name)
+    if "" != prefix {
+        if namespace, ok := env.namespaces[prefix]; ok {
+            return (namespace == uri) && (("*" == testLocal) || (testLocal == local))
+        }
+
+        if "*" == testLocal {
+            itemPrefix, _ := splitName(name)
+            return itemPrefix == prefix
+        }
+    }
+
+    return this.test.name == name
+}
+
+//------------------------------------------------------------------
+//  轴
+
+type xpathAxis func(item xpathNode, yield func(xpathNode))
+
+var xpathAxes map[string]xpathAxis
+
+//  xpathReverseAxes    逆向轴上的节点按照文档的逆序排列
+var xpathReverseAxes = map[string]bool{
+    "ancestor":          true,
+    "ancestor-or-self":  true,
+    "preceding":         true,
+    "preceding-sibling": true,
+}
+
+func init() {
+    xpathAxes = map[string]xpathAxis{
+        "self":               xpathAxisSelf,
+        "child":              xpathAxisChild,
+        "parent":             xpathAxisParent,
+        "attribute":          xpathAxisAttribute,
+        "descendant":         xpathAxisDescendant,
+        "descendant-or-self": xpathAxisDescendantOrSelf,
+        "ancestor":           xpathAxisAncestor,
+        "ancestor-or-self":   xpathAxisAncestorOrSelf,
+        "following-sibling":  xpathAxisFollowingSibling,
+        "preceding-sibling":  xpathAxisPrecedingSibling,
+        "following":          xpathAxisFollowing,
+        "preceding":          xpathAxisPreceding,
+        "namespace":          xpathAxisNamespace,
+    }
+}
+
+func xpathAxisSelf(item xpathNode, yield func(xpathNode)) {
+    yield(item)
+}
+
+func xpathAxisChild(item xpathNode, yield func(xpathNode)) {
+    if nil != item.attr {
+        return
+    }
+
+    for child := item.node.FirstChild(); nil != child; child = child.NextSibling() {
+        if xpathVisible(child) {
+            yield(xpathNode{node: child})
+        }
+    }
+}
+
+func xpathAxisParent(item xpathNode, yield func(xpathNode)) {
+    if nil != item.attr {
+        yield(xpathNode{node: item.node})
+        return
+    }
+
+    if parent := item.node.Parent(); nil != parent {
+        yield(xpathNode{node: parent})
+    }
+}
+
+func xpathAxisAttribute(item xpathNode, yield func(xpathNode)) {
+    if nil != item.attr {
+        return
+    }
+
+    elem := item.node.ToElement()
+    if nil == elem {
+        return
+    }
+
+    for attr := elem.FirstAttribute(); nil != attr; attr = attr.NextAttribute() {
+        if !xpathIsNamespaceDecl(attr) {
+            yield(xpathNode{node: item.node, attr: attr})
+        }
+    }
+}
+
+func xpathAxisDescendant(item xpathNode, yield func(xpathNode)) {
+    if nil != item.attr {
+        return
+    }
+
+    for child := item.node.FirstChild(); nil != child; child = child.NextSibling() {
+        if xpathVisible(child) {
+            yield(xpathNode{node: child})
+            xpathAxisDescendant(xpathNode{node: child}, yield)
+        }
+    }
+}
+
+func xpathAxisDescendantOrSelf(item xpathNode, yield func(xpathNode)) {
+    yield(item)
+    xpathAxisDescendant(item, yield)
+}
+
+func xpathAxisAncestor(item xpathNode, yield func(xpathNode)) {
+    var node XMLNode
+    if nil != item.attr {
+        node = item.node
+    } else {
+        node = item.node.Parent()
+    }
+
+    for ; nil != node; node = node.Parent() {
+        yield(xpathNode{node: node})
+    }
+}
+
+func xpathAxisAncestorOrSelf(item xpathNode, yield func(xpathNode)) {
+    yield(item)
+    xpathAxisAncestor(item, yield)
+}
+
+func xpathAxisFollowingSibling(item xpathNode, yield func(xpathNode)) {
+    if nil != item.attr {
+        return
+    }
+
+    for sibling := item.node.NextSibling(); nil != sibling; sibling = sibling.NextSibling() {
+        if xpathVisible(sibling) {
+            yield(xpathNode{node: sibling})
+        }
+    }
+}
+
+func xpathAxisPrecedingSibling(item xpathNode, yield func(xpathNode)) {
+    if nil != item.attr {
+        return
+    }
+
+    for sibling := item.node.PreviousSibling(); nil != sibling; sibling = sibling.PreviousSibling() {
+        if xpathVisible(sibling) {
+            yield(xpathNode{node: sibling})
+        }
+    }
+}
+
+func xpathAxisFollowing(item xpathNode, yield func(xpathNode)) {
+    node := item.node
+    if nil != item.attr {
+        //  属性之后的节点包括所属元素的所有子孙节点
+        xpathAxisDescendant(xpathNode{node: node}, yield)
+    }
+
+    for ; nil != node; node = node.Parent() {
+        for sibling := node.NextSibling(); nil != sibling; sibling = sibling.NextSibling() {
+            if xpathVisible(sibling) {
+                yield(xpathNode{node: sibling})
+                xpathAxisDescendant(xpathNode{node: sibling}, yield)
+            }
+        }
+    }
+}
+
+func xpathAxisPreceding(item xpathNode, yield func(xpathNode)) {
+    //  按照文档的逆序输出node及其子孙节点
+    var reverse func(node XMLNode)
+    reverse = func(node XMLNode) {
+        for child := node.LastChild(); nil != child; child = child.PreviousSibling() {
+            if xpathVisible(child) {
+                reverse(child)
+            }
+        }
+        yield(xpathNode{node: node})
+    }
+
+    for node := item.node; nil != node; node = node.Parent() {
+        for sibling := node.PreviousSibling(); nil != sibling; sibling = sibling.PreviousSibling() {
+            if xpathVisible(sibling) {
+                reverse(sibling)
+            }
+        }
+    }
+}
+
+//	xpathAxisNamespace	名字空间轴，tinydom不提供名字空间节点，因此总是为空
+func xpathAxisNamespace(item xpathNode, yield func(xpathNode)) {
+}
+
+//------------------------------------------------------------------
+//  函数
+
+type xpathCallExpr struct {
+    name     string
+    function *xpathFunction
+    args     []xpathExpr
+}
+
+func (this *xpathCallExpr) eval(ctx *xpathContext) (xpathValue, error) {
+    args := make([]xpathValue, len(this.args))
+    for i, arg := range this.args {
+        value, err := arg.eval(ctx)
+        if nil != err {
+            return nil, err
+        }
+        args[i] = value
+    }
+
+    return this.function.call(ctx, args)
+}
+
+type xpathFunction struct {
+    //  maxArgs为-1表示不限制参数个数
+    minArgs int
+    maxArgs int
+    call    func(ctx *xpathContext, args []xpathValue) (xpathValue, error)
+}
+
+var xpathFunctions map[string]*xpathFunction
+
+//	xpathNodeSetArg	取出节点集合类型的参数，省略时使用上下文节点
+func xpathNodeSetArg(ctx *xpathContext, name string, args []xpathValue) (xpathNodeSet, error) {
+    if 0 == len(args) {
+        return xpathNodeSet{ctx.node}, nil
+    }
+
+    nodes, ok := args[0].(xpathNodeSet)
+    if !ok {
+        return nil, fmt.Errorf("argument of %s() must be a node-set", name)
+    }
+    return ctx.env.sort(nodes), nil
+}
+
+//	xpathStringArg	取出第index个参数并转换为字符串，省略时使用上下文节点的字符串值
+func xpathStringArg(ctx *xpathContext, args []xpathValue, index int) string {
+    if index >= len(args) {
+        return xpathStringValue(ctx.node)
+    }
+    return xpathToString(args[index])
+}
+
+//	xpathNameOf	返回节点的名字，局部名以及名字空间
+func xpathNameOf(item xpathNode) (name string, local string, uri string) {
+    if nil != item.attr {
+        return item.attr.Name(), item.attr.LocalName(), item.attr.NamespaceURI()
+    }
+
+    if elem := item.node.ToElement(); nil != elem {
+        return elem.Name(), elem.LocalName(), elem.NamespaceURI()
+    }
+
+    if procInst := item.node.ToProcInst(); nil != procInst {
+        return procInst.Target(), procInst.Target(), ""
+    }
+
+    return "", "", ""
+}
+
+//	xpathRound	XPath的round()，0.5总是向正无穷方向舍入
+func xpathRound(number float64) float64 {
+    if math.IsNaN(number) || math.IsInf(number, 0) || (0 == number) {
+        return number
+    }
+
+    if (number < 0) && (number >= -0.5) {
+        return math.Copysign(0, -1)
+    }
+    return math.Floor(number + 0.5)
+}
+
+func init() {
+    stringFunction := func(minArgs int, maxArgs int, fn func(args []string) xpathValue) *xpathFunction {
+        return &xpathFunction{minArgs: minArgs, maxArgs: maxArgs, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            strs := make([]string, len(args))
+            for i, arg := range args {
+                strs[i] = xpathToString(arg)
+            }
+            return fn(strs), nil
+        }}
+    }
+
+    numberFunction := func(fn func(number float64) float64) *xpathFunction {
+        return &xpathFunction{minArgs: 1, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return fn(xpathToNumber(args[0])), nil
+        }}
+    }
+
+    nameFunction := func(name string, pick func(name string, local string, uri string) string) *xpathFunction {
+        return &xpathFunction{minArgs: 0, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            nodes, err := xpathNodeSetArg(ctx, name, args)
+            if nil != err {
+                return nil, err
+            }
+            if 0 == len(nodes) {
+                return "", nil
+            }
+            return pick(xpathNameOf(nodes[0])), nil
+        }}
+    }
+
+    xpathFunctions = map[string]*xpathFunction{
+        //  节点集合函数
+        "last": {minArgs: 0, maxArgs: 0, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return float64(ctx.size), nil
+        }},
+        "position": {minArgs: 0, maxArgs: 0, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return float64(ctx.position), nil
+        }},
+        "count": {minArgs: 1, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            nodes, ok := args[0].(xpathNodeSet)
+            if !ok {
+                return nil, fmt.Errorf("argument of count() must be a node-set")
+            }
+            return float64(len(nodes)), nil
+        }},
+        "id": {minArgs: 1, maxArgs: 1, call: xpathFunctionID},
+        "local-name": nameFunction("local-name", func(name string, local string, uri string) string {
+            return local
+        }),
+        "namespace-uri": nameFunction("namespace-uri", func(name string, local string, uri string) string {
+            return uri
+        }),
+        "name": nameFunction("name", func(name string, local string, uri string) string {
+            return name
+        }),
+
+        //  字符串函数
+        "string": {minArgs: 0, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return xpathStringArg(ctx, args, 0), nil
+        }},
+        "concat": stringFunction(2, -1, func(args []string) xpathValue {
+            return strings.Join(args, "")
+        }),
+        "starts-with": stringFunction(2, 2, func(args []string) xpathValue {
+            return strings.HasPrefix(args[0], args[1])
+        }),
+        "contains": stringFunction(2, 2, func(args []string) xpathValue {
+            return strings.Contains(args[0], args[1])
+        }),
+        "substring-before": stringFunction(2, 2, func(args []string) xpathValue {
+            if index := strings.Index(args[0], args[1]); index >= 0 {
+                return args[0][:index]
+            }
+            return ""
+        }),
+        "substring-after": stringFunction(2, 2, func(args []string) xpathValue {
+            if index := strings.Index(args[0], args[1]); index >= 0 {
+                return args[0][index+len(args[1]):]
+            }
+            return ""
+        }),
+        "substring": {minArgs: 2, maxArgs: 3, call: xpathFunctionSubstring},
+        "string-length": {minArgs: 0, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return float64(utf8.RuneCountInString(xpathStringArg(ctx, args, 0))), nil
+        }},
+        "normalize-space": {minArgs: 0, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return strings.Join(strings.FieldsFunc(xpathStringArg(ctx, args, 0), isXMLSpace), " "), nil
+        }},
+        "translate": stringFunction(3, 3, func(args []string) xpathValue {
+            from := []rune(args[1])
+            to := []rune(args[2])
+            var buf strings.Builder
+            for _, r := range args[0] {
+                index := -1
+                for i, f := range from {
+                    if f == r {
+                        index = i
+                        break
+                    }
+                }
+
+                switch {
+                case index < 0:
+                    buf.WriteRune(r)
+                case index < len(to):
+                    buf.WriteRune(to[index])
+                }
+            }
+            return buf.String()
+        }),
+
+        //  布尔函数
+        "boolean": {minArgs: 1, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return xpathToBoolean(args[0]), nil
+        }},
+        "not": {minArgs: 1, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return !xpathToBoolean(args[0]), nil
+        }},
+        "true": {minArgs: 0, maxArgs: 0, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return true, nil
+        }},
+        "false": {minArgs: 0, maxArgs: 0, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            return false, nil
+        }},
+        "lang": {minArgs: 1, maxArgs: 1, call: xpathFunctionLang},
+
+        //  数字函数
+        "number": {minArgs: 0, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            if 0 == len(args) {
+                return xpathStringToNumber(xpathStringValue(ctx.node)), nil
+            }
+            return xpathToNumber(args[0]), nil
+        }},
+        "sum": {minArgs: 1, maxArgs: 1, call: func(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+            nodes, ok := args[0].(xpathNodeSet)
+            if !ok {
+                return nil, fmt.Errorf("argument of sum() must be a node-set")
+            }
+            sum := 0.0
+            for _, item := range nodes {
+                sum += xpathStringToNumber(xpathStringValue(item))
+            }
+            return sum, nil
+        }},
+        "floor":   numberFunction(math.Floor),
+        "ceiling": numberFunction(math.Ceil),
+        "round":   numberFunction(xpathRound),
+    }
+}
+
+//	xpathFunctionSubstring	substring(string, start, length?)，字符位置从1开始，起止位置按照round()舍入
+func xpathFunctionSubstring(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+    runes := []rune(xpathToString(args[0]))
+    start := xpathRound(xpathToNumber(args[1]))
+    end := math.Inf(1)
+    if 3 == len(args) {
+        end = start + xpathRound(xpathToNumber(args[2]))
+    }
+
+    var buf strings.Builder
+    for i, r := range runes {
+        position := float64(i + 1)
+        if (position >= start) && (position < end) {
+            buf.WriteRune(r)
+        }
+    }
+    return buf.String(), nil
+}
+
+//	xpathFunctionID	id(object)，没有DTD的情况下，把名为id或者xml:id的属性当作ID
+func xpathFunctionID(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+    wanted := make(map[string]bool)
+    if nodes, ok := args[0].(xpathNodeSet); ok {
+        for _, item := range nodes {
+            for _, id := range strings.FieldsFunc(xpathStringValue(item), isXMLSpace) {
+                wanted[id] = true
+            }
+        }
+    } else {
+        for _, id := range strings.FieldsFunc(xpathToString(args[0]), isXMLSpace) {
+            wanted[id] = true
+        }
+    }
+
+    root := ctx.node.node
+    for nil != root.Parent() {
+        root = root.Parent()
+    }
+
+    var result xpathNodeSet
+    xpathAxisDescendantOrSelf(xpathNode{node: root}, func(item xpathNode) {
+        elem := item.node.ToElement()
+        if nil == elem {
+            return
+        }
+
+        for _, name := range []string{"xml:id", "id"} {
+            if attr := elem.FindAttribute(name); (nil != attr) && wanted[attr.Value()] {
+                result = append(result, item)
+                return
+            }
+        }
+    })
+    return result, nil
+}
+
+//	xpathFunctionLang	lang(string)，判断上下文节点的xml:lang是否是指定的语言或者它的子语言
+func xpathFunctionLang(ctx *xpathContext, args []xpathValue) (xpathValue, error) {
+    wanted := strings.ToLower(xpathToString(args[0]))
+
+    var node XMLNode = ctx.node.node
+    for ; nil != node; node = node.Parent() {
+        elem := node.ToElement()
+        if nil == elem {
+            continue
+        }
+
+        if attr := elem.FindAttribute("xml:lang"); nil != attr {
+            lang := strings.ToLower(attr.Value())
+            return (lang == wanted) || strings.HasPrefix(lang, wanted+"-"), nil
+        }
+    }
+
+    return false, nil
+}
@@ -0,0 +1,238 @@
+package tinydom_test
+
+import (
+    "math"
+    "strings"
+    "testing"
+    "tinydom/xml"
+)
+
+const xpathBooks = `<?xml version="1.0" encoding="UTF-8"?>
+<library xml:lang="en">
+    <!--books-->
+    <book id="b1" lang="en" price="10">
+        <title>The Moon</title>
+        <author>Tom</author>
+    </book>
+    <book id="b2" price="25.5">
+        <title>Stars</title>
+        <author>Ann</author>
+        <author>Bob</author>
+    </book>
+    <magazine xml:lang="de-AT" price="3">
+        <title>Zeit</title>
+    </magazine>
+    <?index all?>
+</library>`
+
+func evalXPath(t *testing.T, node tinydom.XMLNode, expr string) tinydom.XPathResult {
+    compiled, err := tinydom.CompileXPath(expr)
+    if nil != err {
+        t.Fatalf("compile %s: %v", expr, err)
+    }
+
+    result, err := compiled.Evaluate(node)
+    if nil != err {
+        t.Fatalf("evaluate %s: %v", expr, err)
+    }
+    return result
+}
+
+func Test_XPath_路径选择(t *testing.T) {
+    doc := loadString(t, xpathBooks)
+
+    titles := doc.Select("/library/book/title")
+    expect(t, "绝对路径", 2 == len(titles))
+    expect(t, "结果按文档顺序排列", "The Moon" == titles[0].ToElement().Text() && "Stars" == titles[1].ToElement().Text())
+
+    expect(t, "任意层级", 3 == len(doc.Select("//title")))
+    expect(t, "通配符", 3 == len(doc.Select("/library/*")))
+    expect(t, "属性谓词", "Stars" == doc.SelectOne("//book[@id='b2']/title").ToElement().Text())
+    expect(t, "位置谓词", "Bob" == doc.SelectOne("//book[2]/author[last()]").ToElement().Text())
+    expect(t, "多个谓词", "b2" == doc.SelectOne("//book[author][2]").ToElement().Attribute("id", ""))
+    expect(t, "父节点", "library" == doc.SelectOne("//title/../..").ToElement().Name())
+    expect(t, "并集", 5 == len(doc.Select("//title | //author[1]")))
+    expect(t, "注释和处理指令", nil != doc.SelectOne("/library/comment()").ToComment() && nil != doc.SelectOne("//processing-instruction('index')").ToProcInst())
+    expect(t, "文本节点", "Tom" == doc.SelectOne("//author/text()").Value())
+    expect(t, "XML声明不是处理指令", 1 == len(doc.Select("//processing-instruction()")))
+    expect(t, "没有匹配", nil == doc.SelectOne("//none") && 0 == len(doc.Select("//none")))
+    expect(t, "非法的表达式", nil == doc.Select("//book[") && nil == tinydom.SelectOne(doc, "1 +"))
+
+    book := doc.SelectOne("//book[2]")
+    expect(t, "相对路径", 2 == len(book.Select("author")))
+    expect(t, "绝对路径从根节点开始", 2 == len(book.Select("/library/book")))
+    expect(t, "上下文节点", book == book.SelectOne("."))
+
+    handle := tinydom.NewHandle(doc)
+    expect(t, "XMLHandle", "Ann" == handle.SelectOne("//book[2]").SelectOne("author").ToElement().Text())
+    expect(t, "XMLHandle没有匹配", nil == handle.SelectOne("//none").SelectOne("x").ToNode())
+    expect(t, "XMLHandle.Select", 3 == len(handle.Select("//title")))
+}
+
+func Test_XPath_轴(t *testing.T) {
+    doc := loadString(t, xpathBooks)
+    stars := doc.SelectOne("//title[.='Stars']")
+
+    names := func(nodes []tinydom.XMLNode) string {
+        var parts []string
+        for _, node := range nodes {
+            if elem := node.ToElement(); nil != elem {
+                parts = append(parts, elem.Name())
+            }
+        }
+        return strings.Join(parts, ",")
+    }
+
+    expect(t, "ancestor", "library,book" == names(stars.Select("ancestor::*")))
+    expect(t, "ancestor的位置按逆序计算", "book" == names(stars.Select("ancestor::*[1]")))
+    expect(t, "following-sibling", "author,author" == names(stars.Select("following-sibling::*")))
+    expect(t, "preceding-sibling", 0 == len(stars.Select("preceding-sibling::*")))
+    expect(t, "following", "author,author,magazine,title" == names(stars.Select("following::*")))
+    expect(t, "preceding", "book,title,author" == names(stars.Select("preceding::*")))
+    expect(t, "preceding的位置按逆序计算", "author" == names(stars.Select("preceding::*[1]")))
+    expect(t, "descendant-or-self", "book,title,author,author" == names(stars.Select("..//descendant-or-self::*")))
+    expect(t, "self", 1 == len(stars.Select("self::title")) && 0 == len(stars.Select("self::book")))
+    expect(t, "namespace轴为空", 0 == len(stars.Select("namespace::*")))
+}
+
+func Test_XPath_属性结果(t *testing.T) {
+    doc := loadString(t, xpathBooks)
+
+    result := evalXPath(t, doc, "//@price")
+    expect(t, "结果是节点集合", tinydom.XPathNodeSet == result.Type())
+    expect(t, "属性不会出现在Nodes中", 0 == len(result.Nodes()))
+    expect(t, "属性按文档顺序排列", 3 == len(result.Attributes()) && "25.5" == result.Attributes()[1].Value())
+    expect(t, "节点集合的字符串值", "10" == result.String())
+
+    result = evalXPath(t, doc, "//book[1]/@*")
+    expect(t, "属性通配符", 3 == len(result.Attributes()))
+
+    result = evalXPath(t, doc, "//book/@id/..")
+    expect(t, "属性的父节点是所属元素", 2 == len(result.Nodes()))
+}
+
+func Test_XPath_运算和函数(t *testing.T) {
+    doc := loadString(t, xpathBooks)
+
+    cases := []struct {
+        expr   string
+        result string
+    }{
+        {"count(//book)", "2"},
+        {"sum(//@price)", "38.5"},
+        {"1 + 2 * 3 - 4 div 8", "6.5"},
+        {"7 mod -3", "1"},
+        {"-(3)", "-3"},
+        {"1 div 0", "Infinity"},
+        {"0 div 0", "NaN"},
+        {"number('abc')", "NaN"},
+        {"number(' 12 ')", "12"},
+        {"string(1.50)", "1.5"},
+        {"//book[1]/@price > 5", "true"},
+        {"//book/@price = 25.5", "true"},
+        {"//book/@price != 10", "true"},
+        {"//book/title = 'Stars'", "true"},
+        {"//book/title = //magazine/title", "false"},
+        {"not(//none)", "true"},
+        {"1 = '1'", "true"},
+        {"true() = 'false'", "true"},
+        {"1 < 2 and 2 < 1 or 3 > 2", "true"},
+        {"concat('a', 'b', 'c')", "abc"},
+        {"starts-with('hello', 'he')", "true"},
+        {"contains(//book[2]/title, 'tar')", "true"},
+        {"substring-before('1999/04/01', '/')", "1999"},
+        {"substring-after('1999/04/01', '/')", "04/01"},
+        {"substring('12345', 1.5, 2.6)", "234"},
+        {"substring('12345', 0, 3)", "12"},
+        {"substring('12345', 0 div 0, 3)", ""},
+        {"substring('12345', -42, 1 div 0)", "12345"},
+        {"string-length('中文')", "2"},
+        {"normalize-space('  a \n b  ')", "a b"},
+        {"translate('--aaa--', 'abc-', 'ABC')", "AAA"},
+        {"floor(-1.5)", "-2"},
+        {"ceiling(1.2)", "2"},
+        {"round(2.5)", "3"},
+        {"round(-2.5)", "-2"},
+        {"name(//book[1]/@lang)", "lang"},
+        {"local-name(/*)", "library"},
+        {"string(//book[2]/author[2])", "Bob"},
+        {"count(id('b2 b1'))", "2"},
+        {"id('b2')/title", "Stars"},
+        {"count(//book[position() = last()])", "1"},
+        {"boolean('')", "false"},
+    }
+
+    for _, c := range cases {
+        result := evalXPath(t, doc, c.expr)
+        if c.result != result.String() {
+            t.Errorf("%s = %q, want %q", c.expr, result.String(), c.result)
+        }
+    }
+
+    result := evalXPath(t, doc, "count(//author)")
+    expect(t, "数字结果", tinydom.XPathNumber == result.Type() && 3 == result.Number())
+    expect(t, "数字结果不是节点集合", nil == result.Nodes())
+    expect(t, "布尔结果", tinydom.XPathBoolean == evalXPath(t, doc, "1 = 1").Type())
+    expect(t, "字符串结果", tinydom.XPathString == evalXPath(t, doc, "'a'").Type())
+    expect(t, "NaN", math.IsNaN(evalXPath(t, doc, "number(//title)").Number()))
+}
+
+func Test_XPath_lang函数(t *testing.T) {
+    doc := loadString(t, xpathBooks)
+
+    expect(t, "继承祖先的xml:lang", 2 == len(doc.Select("//book[lang('EN')]")))
+    expect(t, "子语言", 1 == len(doc.Select("//title[lang('de')]")))
+    expect(t, "不匹配", 0 == len(doc.Select("//magazine[lang('en')]")))
+}
+
+func Test_XPath_名字空间(t *testing.T) {
+    xml := `<feed xmlns="urn:atom" xmlns:m="urn:media"><entry><m:thumb url="a"/></entry><entry><x:thumb xmlns:x="urn:media" url="b"/></entry></feed>`
+    doc, err := tinydom.LoadDocument(strings.NewReader(xml))
+    expect(t, "返回值检测", nil == err)
+
+    expect(t, "默认按限定名匹配", 2 == len(doc.Select("/feed/entry")))
+    expect(t, "限定名", 1 == len(doc.Select("//m:thumb")))
+    expect(t, "前缀通配", 1 == len(doc.Select("//x:*")))
+
+    compiled, err := tinydom.CompileXPathNS("//media:thumb/@url", map[string]string{"media": "urn:media"})
+    expect(t, "编译成功", nil == err)
+    result, err := compiled.Evaluate(doc)
+    expect(t, "求值成功", nil == err)
+    expect(t, "按名字空间匹配", 2 == len(result.Attributes()) && "b" == result.Attributes()[1].Value())
+
+    compiled, err = tinydom.CompileXPathNS("/a:feed/a:*", map[string]string{"a": "urn:atom"})
+    expect(t, "编译成功", nil == err)
+    expect(t, "名字空间通配", 2 == len(compiled.Select(doc)))
+    expect(t, "xmlns不是属性", 0 == len(doc.SelectOne("/feed").Select("@*")))
+    expect(t, "namespace-uri", "urn:media" == evalXPath(t, doc, "namespace-uri(//m:thumb)").String())
+}
+
+func Test_XPath_编译错误(t *testing.T) {
+    for _, expr := range []string{"", "//", "book[", "foo()", "$var", "child::", "bogus::a", "'abc", "1 2", "@"} {
+        compiled, err := tinydom.CompileXPath(expr)
+        if nil != compiled || nil == err {
+            t.Errorf("%q should not compile", expr)
+        }
+        if _, ok := err.(*tinydom.XPathError); !ok {
+            t.Errorf("%q: error should be *XPathError", expr)
+        }
+    }
+
+    compiled, err := tinydom.CompileXPath("count(1)")
+    expect(t, "类型错误在求值时报告", nil == err)
+    _, err = compiled.Evaluate(tinydom.NewDocument())
+    expect(t, "求值错误", nil != err)
+    _, err = compiled.Evaluate(nil)
+    expect(t, "上下文节点为空", nil != err)
+    expect(t, "表达式文本", "count(1)" == compiled.String())
+}
+
+func Test_XPath_乘号与名字的歧义(t *testing.T) {
+    doc, err := tinydom.LoadDocument(strings.NewReader(`<r><div>6</div><mod>4</mod><and/></r>`))
+    expect(t, "返回值检测", nil == err)
+
+    expect(t, "元素名与运算符同名", "1.5" == evalXPath(t, doc, "/r/div div /r/mod").String())
+    expect(t, "*作为乘号", "24" == evalXPath(t, doc, "/r/div * /r/mod").String())
+    expect(t, "*作为通配符", "3" == evalXPath(t, doc, "count(/r/*)").String())
+    expect(t, "and元素", 1 == len(doc.Select("/r/and")))
+}